@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type BundleHandler struct {
+	repo repository.BundleRepository
+}
+
+func NewBundleHandler(repo repository.BundleRepository) *BundleHandler {
+	return &BundleHandler{repo: repo}
+}
+
+type BundleComponentRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type BundleSetRequest struct {
+	Components []BundleComponentRequest `json:"components"`
+}
+
+func (h *BundleHandler) SetComponents(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	var req BundleSetRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	b := models.Bundle{ProductID: id}
+	for _, c := range req.Components {
+		b.Components = append(b.Components, models.BundleComponent{
+			ProductID: c.ProductID,
+			Quantity:  c.Quantity,
+		})
+	}
+
+	if err := h.repo.SetComponents(r.Context(), &b); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to set bundle components", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, b)
+}
+
+// GetByID returns the bundle with the number of kits available in
+// ?warehouse_id= (the default warehouse if omitted).
+func (h *BundleHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	warehouseID := models.DefaultWarehouseID
+	if value := r.URL.Query().Get("warehouse_id"); value != "" {
+		warehouseID, err = strconv.Atoi(value)
+		if err != nil || warehouseID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_input", "invalid warehouse id", nil)
+			return
+		}
+	}
+
+	b, err := h.repo.GetByID(r.Context(), id, warehouseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "bundle not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get bundle", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, b)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CountHandler struct {
+	repo repository.CountRepository
+}
+
+func NewCountHandler(repo repository.CountRepository) *CountHandler {
+	return &CountHandler{repo: repo}
+}
+
+// CountCreateRequest lists either the products or the bins to count.
+type CountCreateRequest struct {
+	WarehouseID int   `json:"warehouse_id"`
+	ProductIDs  []int `json:"product_ids"`
+	LocationIDs []int `json:"location_ids"`
+}
+
+// CountEntryRequest is one count. A serialized product's count lists the
+// serial number of every unit found.
+type CountEntryRequest struct {
+	ProductID  int      `json:"product_id"`
+	LocationID *int     `json:"location_id"`
+	Counter    string   `json:"counter"`
+	Quantity   int      `json:"quantity"`
+	Serials    []string `json:"serials"`
+}
+
+func writeCountError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrNotFound):
+		writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+	case errors.Is(err, repository.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+	case errors.Is(err, repository.ErrDuplicate):
+		writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+	case errors.Is(err, repository.ErrNotEnough):
+		writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal_error", fallback, nil)
+	}
+}
+
+func (h *CountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CountCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if req.WarehouseID == 0 {
+		req.WarehouseID = models.DefaultWarehouseID
+	}
+
+	s := models.CountSession{
+		WarehouseID: req.WarehouseID,
+		ProductIDs:  req.ProductIDs,
+		LocationIDs: req.LocationIDs,
+	}
+
+	if err := h.repo.Create(r.Context(), &s); err != nil {
+		writeCountError(w, err, "failed to create count session")
+		return
+	}
+
+	w.Header().Set("Location", "/counts/"+strconv.Itoa(s.SessionID))
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// GetByID returns the session with every line's frozen, book and counted
+// quantities and the variance to be posted on approval.
+func (h *CountHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid count session id", nil)
+		return
+	}
+
+	s, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "count session not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get count session", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *CountHandler) GetByWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid warehouse id", nil)
+		return
+	}
+
+	sessions, err := h.repo.GetByWarehouse(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get count sessions", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (h *CountHandler) RecordCount(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid count session id", nil)
+		return
+	}
+
+	var req CountEntryRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	e := models.CountEntry{
+		ProductID:  req.ProductID,
+		LocationID: req.LocationID,
+		Counter:    req.Counter,
+		Quantity:   req.Quantity,
+		Serials:    req.Serials,
+	}
+
+	if err := h.repo.RecordCount(r.Context(), id, &e); err != nil {
+		writeCountError(w, err, "failed to record count")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, e)
+}
+
+func (h *CountHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid count session id", nil)
+		return
+	}
+
+	if err := h.repo.Approve(r.Context(), id); err != nil {
+		writeCountError(w, err, "failed to approve count session")
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *CountHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid count session id", nil)
+		return
+	}
+
+	if err := h.repo.Cancel(r.Context(), id); err != nil {
+		writeCountError(w, err, "failed to cancel count session")
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+)
+
+type CurrencyHandler struct {
+	repo repository.CurrencyRepository
+}
+
+func NewCurrencyHandler(repo repository.CurrencyRepository) *CurrencyHandler {
+	return &CurrencyHandler{repo: repo}
+}
+
+// ExchangeRateCreateRequest takes effective_from as YYYY-MM-DD; rate is
+// what one unit of currency is worth in the base currency.
+type ExchangeRateCreateRequest struct {
+	Currency      string      `json:"currency"`
+	Rate          models.Rate `json:"rate"`
+	EffectiveFrom string      `json:"effective_from"`
+}
+
+func (h *CurrencyHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	var req ExchangeRateCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	effectiveFrom, err := parseDate(req.EffectiveFrom)
+	if err != nil || effectiveFrom == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "effective_from must be YYYY-MM-DD", nil)
+		return
+	}
+
+	rate := models.ExchangeRate{
+		Currency:      req.Currency,
+		Rate:          req.Rate,
+		EffectiveFrom: *effectiveFrom,
+	}
+
+	if err := h.repo.CreateRate(r.Context(), &rate); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create exchange rate", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+// GetRates lists the rates of the currency given as ?currency=.
+func (h *CurrencyHandler) GetRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.repo.GetRates(r.Context(), r.URL.Query().Get("currency"))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get exchange rates", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// GetSalesReport covers the days from ?from= to ?to=, both YYYY-MM-DD and
+// both included.
+func (h *CurrencyHandler) GetSalesReport(w http.ResponseWriter, r *http.Request) {
+	from, err := parseDate(r.URL.Query().Get("from"))
+	if err != nil || from == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "from must be YYYY-MM-DD", nil)
+		return
+	}
+
+	to, err := parseDate(r.URL.Query().Get("to"))
+	if err != nil || to == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "to must be YYYY-MM-DD", nil)
+		return
+	}
+
+	report, err := h.repo.GetSalesReport(r.Context(), *from, *to)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get sales report", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type LocationHandler struct {
+	repo repository.LocationRepository
+}
+
+func NewLocationHandler(repo repository.LocationRepository) *LocationHandler {
+	return &LocationHandler{repo: repo}
+}
+
+type LocationCreateRequest struct {
+	WarehouseID int    `json:"warehouse_id"`
+	ParentID    *int   `json:"parent_id"`
+	Code        string `json:"code"`
+	Type        string `json:"location_type"`
+	Name        string `json:"name"`
+}
+
+type StockMoveRequest struct {
+	ProductID      int  `json:"product_id"`
+	FromLocationID *int `json:"from_location_id"`
+	ToLocationID   *int `json:"to_location_id"`
+	Quantity       int  `json:"quantity"`
+}
+
+func (h *LocationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req LocationCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	l := models.Location{
+		WarehouseID: req.WarehouseID,
+		ParentID:    req.ParentID,
+		Code:        req.Code,
+		Type:        req.Type,
+		Name:        req.Name,
+	}
+
+	if err := h.repo.Create(r.Context(), &l); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create location", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/locations/"+strconv.Itoa(l.LocationID))
+	writeJSON(w, http.StatusCreated, l)
+}
+
+func (h *LocationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid location id", nil)
+		return
+	}
+
+	l, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "location not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get location", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (h *LocationHandler) GetByCode(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "code is required", nil)
+		return
+	}
+
+	l, err := h.repo.GetByCode(r.Context(), code)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "location not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get location", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, l)
+}
+
+func (h *LocationHandler) GetByWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid warehouse id", nil)
+		return
+	}
+
+	locations, err := h.repo.GetByWarehouse(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get locations", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, locations)
+}
+
+func (h *LocationHandler) GetContents(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid location id", nil)
+		return
+	}
+
+	stock, err := h.repo.GetContents(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get location contents", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+func (h *LocationHandler) MoveStock(w http.ResponseWriter, r *http.Request) {
+	var req StockMoveRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	m := models.StockMove{
+		ProductID:      req.ProductID,
+		FromLocationID: req.FromLocationID,
+		ToLocationID:   req.ToLocationID,
+		Quantity:       req.Quantity,
+	}
+
+	if err := h.repo.MoveStock(r.Context(), &m); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotEnough):
+			writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to move stock", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m)
+}
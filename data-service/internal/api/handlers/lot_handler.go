@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type LotHandler struct {
+	repo repository.LotRepository
+}
+
+func NewLotHandler(repo repository.LotRepository) *LotHandler {
+	return &LotHandler{repo: repo}
+}
+
+// ReceiveRequest takes dates as YYYY-MM-DD. Lot fields are optional. With a
+// unit, quantity is counted in that unit (e.g. 3 boxes); unit_cost is always
+// per base unit.
+type ReceiveRequest struct {
+	ProductID      int          `json:"product_id"`
+	WarehouseID    int          `json:"warehouse_id"`
+	LotNumber      string       `json:"lot_number"`
+	ManufacturedAt string       `json:"manufactured_at"`
+	ExpiresAt      string       `json:"expires_at"`
+	Quantity       int          `json:"quantity"`
+	Unit           string       `json:"unit"`
+	ToLocationID   *int         `json:"to_location_id"`
+	Serials        []string     `json:"serials"`
+	UnitCost       *models.Cost `json:"unit_cost"`
+}
+
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (h *LotHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	var req ReceiveRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	manufacturedAt, err := parseDate(req.ManufacturedAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "manufactured_at must be YYYY-MM-DD", nil)
+		return
+	}
+
+	expiresAt, err := parseDate(req.ExpiresAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "expires_at must be YYYY-MM-DD", nil)
+		return
+	}
+
+	if req.WarehouseID == 0 {
+		req.WarehouseID = models.DefaultWarehouseID
+	}
+
+	receipt := models.Receipt{
+		ProductID:      req.ProductID,
+		WarehouseID:    req.WarehouseID,
+		LotNumber:      req.LotNumber,
+		ManufacturedAt: manufacturedAt,
+		ExpiresAt:      expiresAt,
+		ToLocationID:   req.ToLocationID,
+		Serials:        req.Serials,
+		UnitCost:       req.UnitCost,
+	}
+	if req.Unit != "" {
+		receipt.Unit = req.Unit
+		receipt.UnitQuantity = req.Quantity
+	} else {
+		receipt.Quantity = req.Quantity
+	}
+
+	if err := h.repo.Receive(r.Context(), &receipt); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to receive stock", nil)
+		}
+		return
+	}
+
+	if receipt.LotID != nil {
+		w.Header().Set("Location", "/lots/"+strconv.Itoa(*receipt.LotID))
+	}
+	writeJSON(w, http.StatusCreated, receipt)
+}
+
+func (h *LotHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid lot id", nil)
+		return
+	}
+
+	lot, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "lot not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get lot", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lot)
+}
+
+func (h *LotHandler) GetByProductID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	lots, err := h.repo.GetByProductID(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get lots", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
+
+// GetExpiring lists lots expiring within ?days= days (default 30).
+func (h *LotHandler) GetExpiring(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		d, err := strconv.Atoi(value)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_input", "days must be a non-negative integer", nil)
+			return
+		}
+		days = d
+	}
+
+	lots, err := h.repo.GetExpiring(r.Context(), time.Now().AddDate(0, 0, days))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get expiring lots", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
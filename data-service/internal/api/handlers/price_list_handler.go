@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PriceListHandler struct {
+	repo repository.PriceListRepository
+}
+
+func NewPriceListHandler(repo repository.PriceListRepository) *PriceListHandler {
+	return &PriceListHandler{repo: repo}
+}
+
+// PriceListCreateRequest takes its dates as YYYY-MM-DD; valid_to is left
+// out for a list that applies until further notice.
+type PriceListCreateRequest struct {
+	Name          string                  `json:"name"`
+	CustomerID    *int                    `json:"customer_id"`
+	CustomerGroup string                  `json:"customer_group"`
+	ValidFrom     string                  `json:"valid_from"`
+	ValidTo       string                  `json:"valid_to"`
+	Prices        []models.PriceListPrice `json:"prices"`
+}
+
+type PriceListPricesRequest struct {
+	Prices []models.PriceListPrice `json:"prices"`
+}
+
+func (h *PriceListHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req PriceListCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	validFrom, err := parseDate(req.ValidFrom)
+	if err != nil || validFrom == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "valid_from must be YYYY-MM-DD", nil)
+		return
+	}
+
+	validTo, err := parseDate(req.ValidTo)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "valid_to must be YYYY-MM-DD", nil)
+		return
+	}
+
+	pl := models.PriceList{
+		Name:          req.Name,
+		CustomerID:    req.CustomerID,
+		CustomerGroup: req.CustomerGroup,
+		ValidFrom:     *validFrom,
+		ValidTo:       validTo,
+		Prices:        req.Prices,
+	}
+
+	if err := h.repo.Create(r.Context(), &pl); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "customer not found", nil)
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create price list", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/price-lists/"+strconv.Itoa(pl.PriceListID))
+	writeJSON(w, http.StatusCreated, pl)
+}
+
+func (h *PriceListHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid price list id", nil)
+		return
+	}
+
+	pl, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "price list not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get price list", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pl)
+}
+
+func (h *PriceListHandler) GetByCustomer(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid customer id", nil)
+		return
+	}
+
+	lists, err := h.repo.GetByCustomer(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "customer not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get price lists", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lists)
+}
+
+func (h *PriceListHandler) SetPrices(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid price list id", nil)
+		return
+	}
+
+	var req PriceListPricesRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.SetPrices(r.Context(), id, req.Prices); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "price list not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to set prices", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// Quote prices ?quantity= base units of ?product_id= for the customer, in
+// ?currency= or else the base currency.
+func (h *PriceListHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid customer id", nil)
+		return
+	}
+
+	query := r.URL.Query()
+
+	productID, err := strconv.Atoi(query.Get("product_id"))
+	if err != nil || productID <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_input", "product_id must be a positive integer", nil)
+		return
+	}
+
+	quantity := 1
+	if q := query.Get("quantity"); q != "" {
+		quantity, err = strconv.Atoi(q)
+		if err != nil || quantity <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid_input", "quantity must be a positive integer", nil)
+			return
+		}
+	}
+
+	quote, err := h.repo.Quote(r.Context(), id, productID, quantity, query.Get("currency"))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "customer not found", nil)
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to quote price", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, quote)
+}
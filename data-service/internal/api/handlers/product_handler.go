@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"data-service/internal/models"
 	"data-service/internal/repository"
 	"errors"
@@ -19,19 +20,31 @@ func NewProductHandler(repo repository.ProductRepository) *ProductHandler {
 }
 
 type ProductCreateRequest struct {
-	Price       float64 `json:"price"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Quantity    int     `json:"quantity"`
-	Category    string  `json:"category"`
+	Price           models.Money         `json:"price"`
+	Name            string               `json:"name"`
+	Description     string               `json:"description"`
+	Quantity        int                  `json:"quantity"`
+	Category        string               `json:"category"`
+	Serialized      bool                 `json:"serialized"`
+	SKU             string               `json:"sku"`
+	Barcodes        []models.Barcode     `json:"barcodes"`
+	BaseUnit        string               `json:"base_unit"`
+	Units           []models.ProductUnit `json:"units"`
+	ParentID        *int                 `json:"parent_id"`
+	Attributes      map[string]string    `json:"attributes"`
+	ReorderPoint    *int                 `json:"reorder_point"`
+	ReorderQuantity int                  `json:"reorder_quantity"`
 }
 
 type ProductUpdateRequest struct {
-	Price       float64 `json:"price"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Quantity    int     `json:"quantity"`
-	Category    string  `json:"category"`
+	Price           models.Money `json:"price"`
+	Name            string       `json:"name"`
+	Description     string       `json:"description"`
+	Category        string       `json:"category"`
+	Serialized      bool         `json:"serialized"`
+	SKU             string       `json:"sku"`
+	ReorderPoint    *int         `json:"reorder_point"`
+	ReorderQuantity int          `json:"reorder_quantity"`
 }
 
 func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
@@ -59,8 +72,14 @@ func (h *ProductHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, product)
 }
 
+// nestedVariants reports whether ?variants=nested was asked for, which
+// lists parents with their variants inside instead of a flat list.
+func nestedVariants(r *http.Request) bool {
+	return r.URL.Query().Get("variants") == "nested"
+}
+
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	products, err := h.repo.GetAll(r.Context())
+	products, err := h.repo.GetAll(r.Context(), nestedVariants(r))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get products", nil)
 		return
@@ -76,7 +95,7 @@ func (h *ProductHandler) GetByCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	products, err := h.repo.GetByCategory(r.Context(), category)
+	products, err := h.repo.GetByCategory(r.Context(), category, nestedVariants(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrInvalidInput):
@@ -97,17 +116,28 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p := models.Product{
-		Name:        req.Name,
-		Price:       req.Price,
-		Description: req.Description,
-		Quantity:    req.Quantity,
-		Category:    req.Category,
+		SKU:             req.SKU,
+		Barcodes:        req.Barcodes,
+		BaseUnit:        req.BaseUnit,
+		Units:           req.Units,
+		ParentID:        req.ParentID,
+		Attributes:      req.Attributes,
+		ReorderPoint:    req.ReorderPoint,
+		ReorderQuantity: req.ReorderQuantity,
+		Name:            req.Name,
+		Price:           req.Price,
+		Description:     req.Description,
+		Quantity:        req.Quantity,
+		Category:        req.Category,
+		Serialized:      req.Serialized,
 	}
 
 	if err := h.repo.Create(r.Context(), &p); err != nil {
 		switch {
 		case errors.Is(err, repository.ErrInvalidInput):
 			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
 		default:
 			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create product", nil)
 		}
@@ -132,12 +162,15 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	p := models.Product{
-		ProductID:   id,
-		Name:        req.Name,
-		Price:       req.Price,
-		Description: req.Description,
-		Quantity:    req.Quantity,
-		Category:    req.Category,
+		ProductID:       id,
+		SKU:             req.SKU,
+		ReorderPoint:    req.ReorderPoint,
+		ReorderQuantity: req.ReorderQuantity,
+		Name:            req.Name,
+		Price:           req.Price,
+		Description:     req.Description,
+		Category:        req.Category,
+		Serialized:      req.Serialized,
 	}
 
 	if err := h.repo.Update(r.Context(), &p); err != nil {
@@ -146,6 +179,8 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
 		case errors.Is(err, repository.ErrInvalidInput):
 			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
 		default:
 			writeError(w, http.StatusInternalServerError, "internal_error", "failed to update product", nil)
 		}
@@ -179,3 +214,199 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusNoContent, nil)
 }
+
+func (h *ProductHandler) GetStockLevel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	stock, err := h.repo.GetStockLevel(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get stock level", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+func (h *ProductHandler) GetBySKU(w http.ResponseWriter, r *http.Request) {
+	h.lookup(w, r, chi.URLParam(r, "sku"), h.repo.GetBySKU)
+}
+
+// GetByBarcode resolves a scanned code to its product.
+func (h *ProductHandler) GetByBarcode(w http.ResponseWriter, r *http.Request) {
+	h.lookup(w, r, chi.URLParam(r, "code"), h.repo.GetByBarcode)
+}
+
+func (h *ProductHandler) lookup(w http.ResponseWriter, r *http.Request, key string, get func(ctx context.Context, key string) (*models.Product, error)) {
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "code is required", nil)
+		return
+	}
+
+	product, err := get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get product", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) AddBarcode(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	var b models.Barcode
+	if ok := decodeJSON(w, r, &b); !ok {
+		return
+	}
+
+	if err := h.repo.AddBarcode(r.Context(), id, &b); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to add barcode", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, b)
+}
+
+func (h *ProductHandler) RemoveBarcode(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	if err := h.repo.RemoveBarcode(r.Context(), id, chi.URLParam(r, "code")); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "barcode not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to remove barcode", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// SetUnit defines an alternate unit of measure, e.g. {"unit": "box", "factor": 24}.
+func (h *ProductHandler) SetUnit(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	var u models.ProductUnit
+	if ok := decodeJSON(w, r, &u); !ok {
+		return
+	}
+
+	if err := h.repo.SetUnit(r.Context(), id, &u); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to set unit", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (h *ProductHandler) RemoveUnit(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	if err := h.repo.RemoveUnit(r.Context(), id, chi.URLParam(r, "unit")); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "unit not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to remove unit", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ProductHandler) GetLowStock(w http.ResponseWriter, r *http.Request) {
+	products, err := h.repo.GetLowStock(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get low stock products", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, products)
+}
+
+// GetStockAlerts lists stock alerts; ?open=true leaves out resolved ones.
+func (h *ProductHandler) GetStockAlerts(w http.ResponseWriter, r *http.Request) {
+	openOnly := false
+	if value := r.URL.Query().Get("open"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_input", "open must be true or false", nil)
+			return
+		}
+		openOnly = b
+	}
+
+	alerts, err := h.repo.GetStockAlerts(r.Context(), openOnly)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get stock alerts", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, alerts)
+}
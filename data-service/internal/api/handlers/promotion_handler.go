@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PromotionHandler struct {
+	repo repository.PromotionRepository
+}
+
+func NewPromotionHandler(repo repository.PromotionRepository) *PromotionHandler {
+	return &PromotionHandler{repo: repo}
+}
+
+// PromotionCreateRequest leaves code out for an automatic promotion and
+// starts_at out for one that starts right away. Percent promotions give
+// value, fixed ones amount.
+type PromotionCreateRequest struct {
+	Code               string         `json:"code"`
+	Name               string         `json:"name"`
+	Kind               string         `json:"kind"`
+	Value              models.Percent `json:"value"`
+	Amount             *models.Money  `json:"amount"`
+	BuyQuantity        *int           `json:"buy_quantity"`
+	GetQuantity        *int           `json:"get_quantity"`
+	ProductID          *int           `json:"product_id"`
+	Category           string         `json:"category"`
+	MinOrderValue      *models.Money  `json:"min_order_value"`
+	MaxUses            *int           `json:"max_uses"`
+	MaxUsesPerCustomer *int           `json:"max_uses_per_customer"`
+	StartsAt           time.Time      `json:"starts_at"`
+	EndsAt             *time.Time     `json:"ends_at"`
+}
+
+func (h *PromotionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req PromotionCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	p := models.Promotion{
+		Code:               req.Code,
+		Name:               req.Name,
+		Kind:               req.Kind,
+		Value:              req.Value,
+		Amount:             req.Amount,
+		BuyQuantity:        req.BuyQuantity,
+		GetQuantity:        req.GetQuantity,
+		ProductID:          req.ProductID,
+		Category:           req.Category,
+		MinOrderValue:      req.MinOrderValue,
+		MaxUses:            req.MaxUses,
+		MaxUsesPerCustomer: req.MaxUsesPerCustomer,
+		StartsAt:           req.StartsAt,
+		EndsAt:             req.EndsAt,
+	}
+
+	if err := h.repo.Create(r.Context(), &p); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create promotion", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/promotions/"+strconv.Itoa(p.PromotionID))
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func (h *PromotionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid promotion id", nil)
+		return
+	}
+
+	p, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "promotion not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get promotion", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (h *PromotionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get promotions", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, promotions)
+}
+
+func (h *PromotionHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid promotion id", nil)
+		return
+	}
+
+	if err := h.repo.Deactivate(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "promotion not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to deactivate promotion", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
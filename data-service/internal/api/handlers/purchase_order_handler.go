@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PurchaseOrderHandler struct {
+	repo repository.PurchaseOrderRepository
+}
+
+func NewPurchaseOrderHandler(repo repository.PurchaseOrderRepository) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{repo: repo}
+}
+
+type PurchaseOrderLineRequest struct {
+	ProductID int          `json:"product_id"`
+	Quantity  int          `json:"quantity"`
+	UnitCost  models.Money `json:"unit_cost"`
+}
+
+type PurchaseOrderCreateRequest struct {
+	SupplierID  int                        `json:"supplier_id"`
+	WarehouseID int                        `json:"warehouse_id"`
+	Lines       []PurchaseOrderLineRequest `json:"lines"`
+}
+
+type PurchaseOrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// POReceiptRequest is one delivered line. Lot, unit and serial fields work
+// as in ReceiveRequest.
+type POReceiptRequest struct {
+	POLineID       int      `json:"po_line_id"`
+	Quantity       int      `json:"quantity"`
+	Unit           string   `json:"unit"`
+	LotNumber      string   `json:"lot_number"`
+	ManufacturedAt string   `json:"manufactured_at"`
+	ExpiresAt      string   `json:"expires_at"`
+	ToLocationID   *int     `json:"to_location_id"`
+	Serials        []string `json:"serials"`
+}
+
+type PurchaseOrderReceiveRequest struct {
+	Lines []POReceiptRequest `json:"lines"`
+}
+
+func (h *PurchaseOrderHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req PurchaseOrderCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if req.WarehouseID == 0 {
+		req.WarehouseID = models.DefaultWarehouseID
+	}
+
+	po := models.PurchaseOrder{
+		SupplierID:  req.SupplierID,
+		WarehouseID: req.WarehouseID,
+	}
+	for _, line := range req.Lines {
+		po.Lines = append(po.Lines, models.PurchaseOrderLine{
+			ProductID:       line.ProductID,
+			QuantityOrdered: line.Quantity,
+			UnitCost:        line.UnitCost,
+		})
+	}
+
+	if err := h.repo.Create(r.Context(), &po); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create purchase order", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/purchase-orders/"+strconv.Itoa(po.POID))
+	writeJSON(w, http.StatusCreated, po)
+}
+
+func (h *PurchaseOrderHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid purchase order id", nil)
+		return
+	}
+
+	po, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "purchase order not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get purchase order", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, po)
+}
+
+func (h *PurchaseOrderHandler) GetBySupplier(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid supplier id", nil)
+		return
+	}
+
+	orders, err := h.repo.GetBySupplier(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get purchase orders", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (h *PurchaseOrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid purchase order id", nil)
+		return
+	}
+
+	var req PurchaseOrderStatusRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.UpdateStatus(r.Context(), id, req.Status); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "purchase order not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to update purchase order", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *PurchaseOrderHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid purchase order id", nil)
+		return
+	}
+
+	var req PurchaseOrderReceiveRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	receipts := make([]models.Receipt, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		manufacturedAt, err := parseDate(line.ManufacturedAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_input", "manufactured_at must be YYYY-MM-DD", nil)
+			return
+		}
+
+		expiresAt, err := parseDate(line.ExpiresAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_input", "expires_at must be YYYY-MM-DD", nil)
+			return
+		}
+
+		lineID := line.POLineID
+		rc := models.Receipt{
+			POLineID:       &lineID,
+			LotNumber:      line.LotNumber,
+			ManufacturedAt: manufacturedAt,
+			ExpiresAt:      expiresAt,
+			ToLocationID:   line.ToLocationID,
+			Serials:        line.Serials,
+		}
+		if line.Unit != "" {
+			rc.Unit = line.Unit
+			rc.UnitQuantity = line.Quantity
+		} else {
+			rc.Quantity = line.Quantity
+		}
+		receipts = append(receipts, rc)
+	}
+
+	if err := h.repo.Receive(r.Context(), id, receipts); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to receive purchase order", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, receipts)
+}
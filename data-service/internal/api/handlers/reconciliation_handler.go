@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"data-service/internal/repository"
+	"net/http"
+)
+
+type ReconciliationHandler struct {
+	repo repository.OperationRepository
+}
+
+func NewReconciliationHandler(repo repository.OperationRepository) *ReconciliationHandler {
+	return &ReconciliationHandler{repo: repo}
+}
+
+// Report lists stock that disagrees with the operation ledger without
+// changing anything.
+func (h *ReconciliationHandler) Report(w http.ResponseWriter, r *http.Request) {
+	report, err := h.repo.Reconcile(r.Context(), false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to reconcile stock", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Correct reconciles and posts an adjustment operation for every drift.
+func (h *ReconciliationHandler) Correct(w http.ResponseWriter, r *http.Request) {
+	report, err := h.repo.Reconcile(r.Context(), true)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to reconcile stock", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
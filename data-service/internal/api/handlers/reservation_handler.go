@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReservationHandler struct {
+	repo repository.ReservationRepository
+}
+
+func NewReservationHandler(repo repository.ReservationRepository) *ReservationHandler {
+	return &ReservationHandler{repo: repo}
+}
+
+// ReservationCreateRequest reserves for a customer, or, with order_id, for
+// a draft order; the draft's customer and warehouse then apply.
+type ReservationCreateRequest struct {
+	ProductID   int  `json:"product_id"`
+	WarehouseID int  `json:"warehouse_id"`
+	CustomerID  int  `json:"customer_id"`
+	OrderID     *int `json:"order_id"`
+	Quantity    int  `json:"quantity"`
+	TTLSeconds  int  `json:"ttl_seconds"`
+}
+
+func (h *ReservationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ReservationCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if req.TTLSeconds <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_input", "ttl_seconds must be positive", nil)
+		return
+	}
+
+	if req.WarehouseID == 0 && req.OrderID == nil {
+		req.WarehouseID = models.DefaultWarehouseID
+	}
+
+	res := models.Reservation{
+		ProductID:   req.ProductID,
+		WarehouseID: req.WarehouseID,
+		CustomerID:  req.CustomerID,
+		OrderID:     req.OrderID,
+		Quantity:    req.Quantity,
+		ExpiresAt:   time.Now().Add(time.Duration(req.TTLSeconds) * time.Second),
+	}
+
+	if err := h.repo.Create(r.Context(), &res); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotEnough):
+			writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create reservation", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/reservations/"+strconv.Itoa(res.ReservationID))
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func (h *ReservationHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid reservation id", nil)
+		return
+	}
+
+	res, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "reservation not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get reservation", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *ReservationHandler) GetActiveByProductID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	reservations, err := h.repo.GetActiveByProductID(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get reservations", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservations)
+}
+
+func (h *ReservationHandler) Release(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid reservation id", nil)
+		return
+	}
+
+	if err := h.repo.Release(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "reservation not found", nil)
+		case errors.Is(err, repository.ErrNotActive):
+			writeError(w, http.StatusConflict, "not_active", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to release reservation", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ReturnHandler struct {
+	repo repository.ReturnRepository
+}
+
+func NewReturnHandler(repo repository.ReturnRepository) *ReturnHandler {
+	return &ReturnHandler{repo: repo}
+}
+
+type ReturnLineRequest struct {
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// ReturnCreateRequest leaves warehouse_id out to take the goods back into
+// the warehouse the order shipped from.
+type ReturnCreateRequest struct {
+	OrderID     int                 `json:"order_id"`
+	WarehouseID int                 `json:"warehouse_id"`
+	Reason      string              `json:"reason"`
+	Lines       []ReturnLineRequest `json:"lines"`
+}
+
+// ReturnReceiveRequest lists the inspection outcomes of the units that
+// arrived; an empty list means nothing arrived.
+type ReturnReceiveRequest struct {
+	Inspections []models.ReturnInspection `json:"inspections"`
+}
+
+func (h *ReturnHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ReturnCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	ret := models.Return{
+		OrderID:     req.OrderID,
+		WarehouseID: req.WarehouseID,
+		Reason:      req.Reason,
+	}
+	for _, line := range req.Lines {
+		ret.Lines = append(ret.Lines, models.ReturnLine{
+			OrderItemID: line.OrderItemID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	if err := h.repo.Create(r.Context(), &ret); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "order not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotEnough):
+			writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create return", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/returns/"+strconv.Itoa(ret.ReturnID))
+	writeJSON(w, http.StatusCreated, ret)
+}
+
+func (h *ReturnHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid return id", nil)
+		return
+	}
+
+	ret, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "return not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get return", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (h *ReturnHandler) GetByOrder(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid order id", nil)
+		return
+	}
+
+	returns, err := h.repo.GetByOrder(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get returns", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, returns)
+}
+
+func (h *ReturnHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid return id", nil)
+		return
+	}
+
+	var req ReturnReceiveRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.Receive(r.Context(), id, req.Inspections); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to receive return", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ReturnHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid return id", nil)
+		return
+	}
+
+	if err := h.repo.Cancel(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "return not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to cancel return", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
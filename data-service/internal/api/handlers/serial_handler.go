@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SerialHandler struct {
+	repo   repository.SerialRepository
+	orders repository.OrderRepository
+}
+
+func NewSerialHandler(repo repository.SerialRepository, orders repository.OrderRepository) *SerialHandler {
+	return &SerialHandler{repo: repo, orders: orders}
+}
+
+type AssignSerialsRequest struct {
+	Serials []string `json:"serials"`
+}
+
+func (h *SerialHandler) GetInStock(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	serials, err := h.repo.GetInStock(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get serials", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, serials)
+}
+
+func (h *SerialHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	history, err := h.repo.GetHistory(r.Context(), id, chi.URLParam(r, "serial"))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "serial number not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get serial history", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (h *SerialHandler) AssignToOrderItem(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid order item id", nil)
+		return
+	}
+
+	var req AssignSerialsRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.orders.AssignSerials(r.Context(), id, req.Serials); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "order item not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to assign serials", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ShipmentHandler struct {
+	repo repository.ShipmentRepository
+}
+
+func NewShipmentHandler(repo repository.ShipmentRepository) *ShipmentHandler {
+	return &ShipmentHandler{repo: repo}
+}
+
+type ShipmentLineRequest struct {
+	OrderItemID int `json:"order_item_id"`
+	Quantity    int `json:"quantity"`
+}
+
+type ShipmentCreateRequest struct {
+	OrderID        int                   `json:"order_id"`
+	Carrier        string                `json:"carrier"`
+	TrackingNumber string                `json:"tracking_number"`
+	Lines          []ShipmentLineRequest `json:"lines"`
+}
+
+// ShipmentConfirmRequest names who sent the parcel, for the order's status
+// history.
+type ShipmentConfirmRequest struct {
+	ConfirmedBy string `json:"confirmed_by"`
+}
+
+func (h *ShipmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ShipmentCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	s := models.Shipment{
+		OrderID:        req.OrderID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	}
+	for _, line := range req.Lines {
+		s.Lines = append(s.Lines, models.ShipmentLine{
+			OrderItemID: line.OrderItemID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	if err := h.repo.Create(r.Context(), &s); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "order not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidTransition):
+			writeError(w, http.StatusConflict, "invalid_transition", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create shipment", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/shipments/"+strconv.Itoa(s.ShipmentID))
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (h *ShipmentHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid shipment id", nil)
+		return
+	}
+
+	s, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "shipment not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get shipment", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *ShipmentHandler) GetByOrder(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid order id", nil)
+		return
+	}
+
+	shipments, err := h.repo.GetByOrder(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get shipments", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shipments)
+}
+
+func (h *ShipmentHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid shipment id", nil)
+		return
+	}
+
+	var req ShipmentConfirmRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.Confirm(r.Context(), id, req.ConfirmedBy); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "shipment not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidTransition):
+			writeError(w, http.StatusConflict, "invalid_transition", err.Error(), nil)
+		case errors.Is(err, repository.ErrSerialsRequired):
+			writeError(w, http.StatusConflict, "serials_required", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotEnough):
+			writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to confirm shipment", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ShipmentHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid shipment id", nil)
+		return
+	}
+
+	if err := h.repo.Cancel(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "shipment not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to cancel shipment", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StockHistoryHandler struct {
+	repo repository.OperationRepository
+}
+
+func NewStockHistoryHandler(repo repository.OperationRepository) *StockHistoryHandler {
+	return &StockHistoryHandler{repo: repo}
+}
+
+// parseAt reads ?at= as RFC 3339 or as YYYY-MM-DD, meaning the end of that
+// day. It defaults to now.
+func parseAt(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("at")
+	if value == "" {
+		return time.Now(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+func (h *StockHistoryHandler) GetProductAsOf(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	at, err := parseAt(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "at must be RFC 3339 or YYYY-MM-DD", nil)
+		return
+	}
+
+	stock, err := h.repo.GetStockAsOf(r.Context(), id, at)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get stock", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+func (h *StockHistoryHandler) GetCategoryAsOf(w http.ResponseWriter, r *http.Request) {
+	category := chi.URLParam(r, "category")
+
+	at, err := parseAt(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "at must be RFC 3339 or YYYY-MM-DD", nil)
+		return
+	}
+
+	stock, err := h.repo.GetCategoryStockAsOf(r.Context(), category, at)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get stock", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+// TakeSnapshot stores current ledger totals; call it periodically to keep
+// point-in-time queries fast.
+func (h *StockHistoryHandler) TakeSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.repo.TakeSnapshot(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to take snapshot", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, snapshot)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SupplierHandler struct {
+	repo repository.SupplierRepository
+}
+
+func NewSupplierHandler(repo repository.SupplierRepository) *SupplierHandler {
+	return &SupplierHandler{repo: repo}
+}
+
+type SupplierCreateRequest struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+func (h *SupplierHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req SupplierCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	s := models.Supplier{
+		Code:  req.Code,
+		Name:  req.Name,
+		Email: req.Email,
+		Phone: req.Phone,
+	}
+
+	if err := h.repo.Create(r.Context(), &s); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create supplier", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/suppliers/"+strconv.Itoa(s.SupplierID))
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (h *SupplierHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid supplier id", nil)
+		return
+	}
+
+	s, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "supplier not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get supplier", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *SupplierHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get suppliers", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, suppliers)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TaxHandler struct {
+	repo repository.TaxRepository
+}
+
+func NewTaxHandler(repo repository.TaxRepository) *TaxHandler {
+	return &TaxHandler{repo: repo}
+}
+
+type TaxClassRequest struct {
+	TaxClass string `json:"tax_class"`
+}
+
+// TaxRateCreateRequest takes its dates as YYYY-MM-DD; effective_to is left
+// out for a rate that applies until further notice.
+type TaxRateCreateRequest struct {
+	Jurisdiction  string         `json:"jurisdiction"`
+	TaxClass      string         `json:"tax_class"`
+	Rate          models.Percent `json:"rate"`
+	EffectiveFrom string         `json:"effective_from"`
+	EffectiveTo   string         `json:"effective_to"`
+}
+
+type TaxRateEndRequest struct {
+	EffectiveTo string `json:"effective_to"`
+}
+
+func (h *TaxHandler) SetTaxClass(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	var req TaxClassRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.SetTaxClass(r.Context(), id, req.TaxClass); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to set tax class", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *TaxHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	var req TaxRateCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	effectiveFrom, err := parseDate(req.EffectiveFrom)
+	if err != nil || effectiveFrom == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "effective_from must be YYYY-MM-DD", nil)
+		return
+	}
+
+	effectiveTo, err := parseDate(req.EffectiveTo)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "effective_to must be YYYY-MM-DD", nil)
+		return
+	}
+
+	rate := models.TaxRate{
+		Jurisdiction:  req.Jurisdiction,
+		TaxClass:      req.TaxClass,
+		Rate:          req.Rate,
+		EffectiveFrom: *effectiveFrom,
+		EffectiveTo:   effectiveTo,
+	}
+
+	if err := h.repo.CreateRate(r.Context(), &rate); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create tax rate", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+func (h *TaxHandler) EndRate(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid tax rate id", nil)
+		return
+	}
+
+	var req TaxRateEndRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	effectiveTo, err := parseDate(req.EffectiveTo)
+	if err != nil || effectiveTo == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "effective_to must be YYYY-MM-DD", nil)
+		return
+	}
+
+	if err := h.repo.EndRate(r.Context(), id, *effectiveTo); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "tax rate not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to end tax rate", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+// GetRates lists the rates of the jurisdiction given as ?jurisdiction=.
+func (h *TaxHandler) GetRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.repo.GetRates(r.Context(), r.URL.Query().Get("jurisdiction"))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get tax rates", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TransferHandler struct {
+	repo repository.TransferRepository
+}
+
+func NewTransferHandler(repo repository.TransferRepository) *TransferHandler {
+	return &TransferHandler{repo: repo}
+}
+
+// TransferLineRequest names, for a serialized product, the serial number
+// of every unit sent.
+type TransferLineRequest struct {
+	ProductID int      `json:"product_id"`
+	Quantity  int      `json:"quantity"`
+	Serials   []string `json:"serials"`
+}
+
+type TransferCreateRequest struct {
+	FromWarehouseID int                   `json:"from_warehouse_id"`
+	ToWarehouseID   int                   `json:"to_warehouse_id"`
+	Lines           []TransferLineRequest `json:"lines"`
+}
+
+// TransferReceiveRequest lists what arrived; products left out arrived
+// complete, so an empty body receives the whole transfer.
+type TransferReceiveRequest struct {
+	Lines []models.TransferReceipt `json:"lines"`
+}
+
+func (h *TransferHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req TransferCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	t := models.Transfer{
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+	}
+	for _, line := range req.Lines {
+		t.Lines = append(t.Lines, models.TransferLine{
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			Serials:   line.Serials,
+		})
+	}
+
+	if err := h.repo.Create(r.Context(), &t); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create transfer", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/transfers/"+strconv.Itoa(t.TransferID))
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (h *TransferHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid transfer id", nil)
+		return
+	}
+
+	t, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "transfer not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get transfer", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// GetByStatus lists transfers by ?status=, in transit if omitted.
+func (h *TransferHandler) GetByStatus(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.TransferInTransit
+	}
+
+	transfers, err := h.repo.GetByStatus(r.Context(), status)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get transfers", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+func (h *TransferHandler) Dispatch(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid transfer id", nil)
+		return
+	}
+
+	if err := h.repo.Dispatch(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrNotEnough):
+			writeError(w, http.StatusConflict, "not_enough", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to dispatch transfer", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *TransferHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid transfer id", nil)
+		return
+	}
+
+	var req TransferReceiveRequest
+	if r.ContentLength != 0 {
+		if ok := decodeJSON(w, r, &req); !ok {
+			return
+		}
+	}
+
+	if err := h.repo.Receive(r.Context(), id, req.Lines); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrProductNotFound):
+			writeError(w, http.StatusNotFound, "not_found", err.Error(), nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to receive transfer", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *TransferHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid transfer id", nil)
+		return
+	}
+
+	if err := h.repo.Cancel(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "transfer not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to cancel transfer", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
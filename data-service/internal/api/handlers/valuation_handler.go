@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ValuationHandler struct {
+	repo repository.ValuationRepository
+}
+
+func NewValuationHandler(repo repository.ValuationRepository) *ValuationHandler {
+	return &ValuationHandler{repo: repo}
+}
+
+type CostMethodRequest struct {
+	Method string `json:"method"`
+}
+
+func (h *ValuationHandler) SetCostMethod(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	var req CostMethodRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.SetCostMethod(r.Context(), id, req.Method); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to set cost method", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ValuationHandler) GetProductValue(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid product id", nil)
+		return
+	}
+
+	value, err := h.repo.GetProductValue(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "product not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get inventory value", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, value)
+}
+
+// GetValues lists inventory value per product, limited to ?category= if
+// given.
+func (h *ValuationHandler) GetValues(w http.ResponseWriter, r *http.Request) {
+	values, err := h.repo.GetValues(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get inventory value", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, values)
+}
+
+func (h *ValuationHandler) GetCategoryValues(w http.ResponseWriter, r *http.Request) {
+	values, err := h.repo.GetCategoryValues(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get inventory value", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, values)
+}
+
+func (h *ValuationHandler) GetOrderCOGS(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid order id", nil)
+		return
+	}
+
+	cogs, err := h.repo.GetOrderCOGS(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "order not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get cost of goods sold", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cogs)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WarehouseHandler struct {
+	repo repository.WarehouseRepository
+}
+
+func NewWarehouseHandler(repo repository.WarehouseRepository) *WarehouseHandler {
+	return &WarehouseHandler{repo: repo}
+}
+
+type WarehouseCreateRequest struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+func (h *WarehouseHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req WarehouseCreateRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	wh := models.Warehouse{
+		Code:    req.Code,
+		Name:    req.Name,
+		Address: req.Address,
+	}
+
+	if err := h.repo.Create(r.Context(), &wh); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		case errors.Is(err, repository.ErrDuplicate):
+			writeError(w, http.StatusConflict, "duplicate", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to create warehouse", nil)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/warehouses/"+strconv.Itoa(wh.WarehouseID))
+	writeJSON(w, http.StatusCreated, wh)
+}
+
+func (h *WarehouseHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid warehouse id", nil)
+		return
+	}
+
+	wh, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "warehouse not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get warehouse", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, wh)
+}
+
+func (h *WarehouseHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.repo.GetAll(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get warehouses", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, warehouses)
+}
+
+func (h *WarehouseHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid warehouse id", nil)
+		return
+	}
+
+	stock, err := h.repo.GetStock(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get warehouse stock", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"data-service/internal/models"
+	"data-service/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WaveHandler struct {
+	repo repository.WaveRepository
+}
+
+func NewWaveHandler(repo repository.WaveRepository) *WaveHandler {
+	return &WaveHandler{repo: repo}
+}
+
+type PickConfirmRequest struct {
+	QuantityPicked int    `json:"quantity_picked"`
+	PickedBy       string `json:"picked_by"`
+}
+
+// Plan takes a wave rule and answers with the waves it created, which is
+// an empty list when no open order matched.
+func (h *WaveHandler) Plan(w http.ResponseWriter, r *http.Request) {
+	var rule models.WaveRule
+	if ok := decodeJSON(w, r, &rule); !ok {
+		return
+	}
+
+	waves, err := h.repo.Plan(r.Context(), &rule)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "warehouse not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to plan waves", nil)
+		}
+		return
+	}
+
+	if waves == nil {
+		waves = []models.Wave{}
+	}
+
+	writeJSON(w, http.StatusCreated, waves)
+}
+
+func (h *WaveHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid wave id", nil)
+		return
+	}
+
+	wave, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "wave not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to get wave", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, wave)
+}
+
+// GetByWarehouse lists a warehouse's waves; ?status= narrows the list.
+func (h *WaveHandler) GetByWarehouse(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid warehouse id", nil)
+		return
+	}
+
+	waves, err := h.repo.GetByWarehouse(r.Context(), id, r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to get waves", nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, waves)
+}
+
+func (h *WaveHandler) ConfirmPick(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid pick line id", nil)
+		return
+	}
+
+	var req PickConfirmRequest
+	if ok := decodeJSON(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.repo.ConfirmPick(r.Context(), id, req.QuantityPicked, req.PickedBy); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "pick line not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to confirm pick", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *WaveHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid wave id", nil)
+		return
+	}
+
+	if err := h.repo.Cancel(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "wave not found", nil)
+		case errors.Is(err, repository.ErrInvalidInput):
+			writeError(w, http.StatusBadRequest, "invalid_input", err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to cancel wave", nil)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
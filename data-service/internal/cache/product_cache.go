@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -80,13 +81,13 @@ func (c *CachedProductRepository) invalidateProductCache(ctx context.Context, pr
 		log.Printf("Failed to delete product cache %s: %v", productKey, err)
 	}
 
-	if err := c.redis.Del(ctx, "products:all").Err(); err != nil {
+	if err := c.redis.Del(ctx, "products:all", "products:all:nested").Err(); err != nil {
 		log.Printf("Failed to delete products:all cache: %v", err)
 	}
 
 	if category != "" {
 		categoryKey := fmt.Sprintf("products:category:%s", category)
-		if err := c.redis.Del(ctx, categoryKey).Err(); err != nil {
+		if err := c.redis.Del(ctx, categoryKey, categoryKey+":nested").Err(); err != nil {
 			log.Printf("Failed to delete category cache %s: %v", categoryKey, err)
 		}
 
@@ -97,7 +98,7 @@ func (c *CachedProductRepository) invalidateProductCache(ctx context.Context, pr
 func (c *CachedProductRepository) invalidateCategoryCache(ctx context.Context, category string) {
 	if category != "" {
 		categoryKey := fmt.Sprintf("products:category:%s", category)
-		err := c.redis.Del(ctx, categoryKey).Err()
+		err := c.redis.Del(ctx, categoryKey, categoryKey+":nested").Err()
 		if err != nil {
 			log.Printf("Failed to delete category cache %s: %v", categoryKey, err)
 		}
@@ -113,6 +114,8 @@ func (c *CachedProductRepository) Update(ctx context.Context, product *models.Pr
 		return err
 	}
 	c.invalidateProductCache(ctx, product.ProductID, oldProduct.Category)
+	c.invalidateLookup(ctx, skuKey(oldProduct.SKU))
+	c.invalidateFamily(ctx, oldProduct)
 
 	if oldProduct.Category != product.Category {
 		c.invalidateCategoryCache(ctx, product.Category)
@@ -122,17 +125,26 @@ func (c *CachedProductRepository) Update(ctx context.Context, product *models.Pr
 }
 
 func (c *CachedProductRepository) Create(ctx context.Context, product *models.Product) error {
-	if err := c.redis.Del(ctx, "products:all").Err(); err != nil {
+	if err := c.redis.Del(ctx, "products:all", "products:all:nested").Err(); err != nil {
 		log.Printf("Failed to delete product cache: %v", err)
 	}
 
 	if product.Category != "" {
 		categoryKey := fmt.Sprintf("products:category:%s", product.Category)
-		if err := c.redis.Del(ctx, categoryKey).Err(); err != nil {
+		if err := c.redis.Del(ctx, categoryKey, categoryKey+":nested").Err(); err != nil {
 			log.Printf("failed to delete category cache: %v", err)
 		}
 	}
 
+	// A new variant changes its parent's variant list and takes the
+	// parent's category.
+	if product.ParentID != nil {
+		parent, err := c.realRepo.GetByID(ctx, *product.ParentID)
+		if err == nil {
+			c.invalidateProductCache(ctx, parent.ProductID, parent.Category)
+		}
+	}
+
 	return c.realRepo.Create(ctx, product)
 }
 
@@ -144,12 +156,17 @@ func (c *CachedProductRepository) Delete(ctx context.Context, id int) error {
 	}
 
 	c.invalidateProductCache(ctx, id, product.Category)
+	c.invalidateLookup(ctx, skuKey(product.SKU))
+	c.invalidateFamily(ctx, product)
+	for _, b := range product.Barcodes {
+		c.invalidateLookup(ctx, barcodeKey(b.Code))
+	}
 
 	return c.realRepo.Delete(ctx, id)
 }
 
-func (c *CachedProductRepository) GetAll(ctx context.Context) ([]models.Product, error) {
-	key := "products:all"
+func (c *CachedProductRepository) GetAll(ctx context.Context, withVariants bool) ([]models.Product, error) {
+	key := listKey("products:all", withVariants)
 
 	data, err := c.redis.Get(ctx, key).Bytes()
 
@@ -163,7 +180,7 @@ func (c *CachedProductRepository) GetAll(ctx context.Context) ([]models.Product,
 		log.Printf("Redis error: %v (continuing with DB)", err)
 	}
 
-	products, err := c.realRepo.GetAll(ctx)
+	products, err := c.realRepo.GetAll(ctx, withVariants)
 	if err != nil {
 		return nil, err
 	}
@@ -178,8 +195,8 @@ func (c *CachedProductRepository) GetAll(ctx context.Context) ([]models.Product,
 	return products, nil
 }
 
-func (c *CachedProductRepository) GetByCategory(ctx context.Context, category string) ([]models.Product, error) {
-	key := fmt.Sprintf("products:category:%s", category)
+func (c *CachedProductRepository) GetByCategory(ctx context.Context, category string, withVariants bool) ([]models.Product, error) {
+	key := listKey(fmt.Sprintf("products:category:%s", category), withVariants)
 
 	data, err := c.redis.Get(ctx, key).Bytes()
 
@@ -193,7 +210,7 @@ func (c *CachedProductRepository) GetByCategory(ctx context.Context, category st
 		log.Printf("Redis error: %v (continuing with DB)", err)
 	}
 
-	products, err := c.realRepo.GetByCategory(ctx, category)
+	products, err := c.realRepo.GetByCategory(ctx, category, withVariants)
 	if err != nil {
 		return nil, err
 	}
@@ -207,12 +224,127 @@ func (c *CachedProductRepository) GetByCategory(ctx context.Context, category st
 	return products, nil
 }
 
-func (c *CachedProductRepository) UpdateQuantity(ctx context.Context, id int, change int) error {
+func (c *CachedProductRepository) UpdateQuantity(ctx context.Context, id int, warehouseID int, change int) error {
 	product, err := c.realRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	product.Quantity += change
-	return c.Update(ctx, product)
+	c.invalidateProductCache(ctx, id, product.Category)
+	c.invalidateFamily(ctx, product)
+
+	return c.realRepo.UpdateQuantity(ctx, id, warehouseID, change)
+}
+
+// GetStockLevel is not cached: reservations expire on their own and a stale
+// available quantity would let orders oversell.
+func (c *CachedProductRepository) GetStockLevel(ctx context.Context, id int) (*models.StockLevel, error) {
+	return c.realRepo.GetStockLevel(ctx, id)
+}
+
+func listKey(key string, withVariants bool) string {
+	if withVariants {
+		return key + ":nested"
+	}
+	return key
+}
+
+// invalidateFamily drops the cached parent of a variant, whose aggregated
+// stock includes it, and the cached variants of a parent.
+func (c *CachedProductRepository) invalidateFamily(ctx context.Context, product *models.Product) {
+	if product.ParentID != nil {
+		c.invalidateProductCache(ctx, *product.ParentID, "")
+	}
+	for _, v := range product.Variants {
+		c.invalidateProductCache(ctx, v.ProductID, "")
+	}
+}
+
+func skuKey(sku string) string {
+	return fmt.Sprintf("product:sku:%s", strings.ToUpper(strings.TrimSpace(sku)))
+}
+
+func barcodeKey(code string) string {
+	return fmt.Sprintf("product:barcode:%s", strings.TrimSpace(code))
+}
+
+func (c *CachedProductRepository) invalidateLookup(ctx context.Context, key string) {
+	if err := c.redis.Del(ctx, key).Err(); err != nil {
+		log.Printf("Failed to delete lookup cache %s: %v", key, err)
+	}
+}
+
+// getByLookup resolves a scanner lookup through a cached key -> product ID
+// mapping, so the product itself is only cached once under product:%d.
+func (c *CachedProductRepository) getByLookup(ctx context.Context, key string, lookup func() (*models.Product, error)) (*models.Product, error) {
+	id, err := c.redis.Get(ctx, key).Int()
+
+	switch {
+	case err == nil:
+		return c.GetByID(ctx, id)
+
+	case errors.Is(err, redis.Nil):
+
+	default:
+		log.Printf("Redis error (continuing with DB): %v", err)
+	}
+
+	product, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.redis.Set(ctx, key, product.ProductID, c.ttl).Err(); err != nil {
+		log.Printf("failed to cache lookup %s: %v", key, err)
+	}
+
+	return product, nil
+}
+
+func (c *CachedProductRepository) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	return c.getByLookup(ctx, skuKey(sku), func() (*models.Product, error) {
+		return c.realRepo.GetBySKU(ctx, sku)
+	})
+}
+
+func (c *CachedProductRepository) GetByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	return c.getByLookup(ctx, barcodeKey(barcode), func() (*models.Product, error) {
+		return c.realRepo.GetByBarcode(ctx, barcode)
+	})
+}
+
+func (c *CachedProductRepository) AddBarcode(ctx context.Context, productID int, barcode *models.Barcode) error {
+	c.invalidateProductCache(ctx, productID, "")
+	c.invalidateLookup(ctx, barcodeKey(barcode.Code))
+
+	return c.realRepo.AddBarcode(ctx, productID, barcode)
+}
+
+func (c *CachedProductRepository) RemoveBarcode(ctx context.Context, productID int, barcode string) error {
+	c.invalidateProductCache(ctx, productID, "")
+	c.invalidateLookup(ctx, barcodeKey(barcode))
+
+	return c.realRepo.RemoveBarcode(ctx, productID, barcode)
+}
+
+func (c *CachedProductRepository) SetUnit(ctx context.Context, productID int, unit *models.ProductUnit) error {
+	c.invalidateProductCache(ctx, productID, "")
+
+	return c.realRepo.SetUnit(ctx, productID, unit)
+}
+
+func (c *CachedProductRepository) RemoveUnit(ctx context.Context, productID int, unit string) error {
+	c.invalidateProductCache(ctx, productID, "")
+
+	return c.realRepo.RemoveUnit(ctx, productID, unit)
+}
+
+// GetLowStock and GetStockAlerts are not cached: they exist to catch stock
+// running out and must reflect the latest orders.
+func (c *CachedProductRepository) GetLowStock(ctx context.Context) ([]models.Product, error) {
+	return c.realRepo.GetLowStock(ctx)
+}
+
+func (c *CachedProductRepository) GetStockAlerts(ctx context.Context, openOnly bool) ([]models.StockAlert, error) {
+	return c.realRepo.GetStockAlerts(ctx, openOnly)
 }
@@ -17,6 +17,19 @@ type Config struct {
 	RedisURL      string
 	RedisPassword string
 	RedisDB       int
+
+	// StockTiming is when order stock leaves the warehouse: "order" (on
+	// placing the order) or "shipment" (on confirming each shipment).
+	StockTiming string
+
+	// PricesIncludeTax tells whether catalogue prices are gross or net.
+	// TaxJurisdiction is where orders that name none are taxed; empty
+	// leaves them untaxed.
+	PricesIncludeTax bool
+	TaxJurisdiction  string
+
+	// BaseCurrency is the currency catalogue prices and reports are in.
+	BaseCurrency string
 }
 
 func LoadConfig() (*Config, error) {
@@ -35,6 +48,11 @@ func LoadConfig() (*Config, error) {
 		RedisURL:      getEnv("REDIS_URL", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvAsInt("REDIS_DB", 0),
+		StockTiming:   getEnv("STOCK_TIMING", "order"),
+
+		PricesIncludeTax: getEnvAsBool("PRICES_INCLUDE_TAX", false),
+		TaxJurisdiction:  getEnv("TAX_JURISDICTION", ""),
+		BaseCurrency:     getEnv("BASE_CURRENCY", "USD"),
 	}, nil
 
 }
@@ -48,6 +66,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,8 @@
+ALTER TABLE operations DROP COLUMN reservation_id;
+
+DELETE FROM operations WHERE operation_type NOT IN ('incoming', 'outgoing');
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing'));
+
+DROP TABLE reservations;
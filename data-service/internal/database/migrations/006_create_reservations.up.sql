@@ -0,0 +1,22 @@
+CREATE TABLE reservations(
+    reservation_id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    customer_id INTEGER NOT NULL,
+    order_id INTEGER,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'released', 'expired', 'converted')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ,
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    FOREIGN KEY (customer_id) REFERENCES customers(customer_id),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id)
+);
+
+CREATE INDEX idx_reservations_active ON reservations(product_id, expires_at) WHERE status = 'active';
+
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing', 'adjustment', 'reserve', 'release'));
+
+ALTER TABLE operations ADD COLUMN reservation_id INTEGER REFERENCES reservations(reservation_id);
@@ -0,0 +1,13 @@
+DROP INDEX idx_reservations_active;
+CREATE INDEX idx_reservations_active ON reservations(product_id, expires_at) WHERE status = 'active';
+
+ALTER TABLE reservations DROP COLUMN warehouse_id;
+ALTER TABLE orders DROP COLUMN warehouse_id;
+ALTER TABLE operations DROP COLUMN warehouse_id;
+
+UPDATE products p SET quantity = COALESCE((
+    SELECT SUM(ws.quantity) FROM warehouse_stock ws WHERE ws.product_id = p.product_id
+), 0);
+
+DROP TABLE warehouse_stock;
+DROP TABLE warehouses;
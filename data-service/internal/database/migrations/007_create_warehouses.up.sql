@@ -0,0 +1,35 @@
+CREATE TABLE warehouses(
+    warehouse_id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(150) NOT NULL,
+    address TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- The default warehouse is the first row and always gets warehouse_id 1.
+INSERT INTO warehouses (code, name) VALUES ('DEFAULT', 'Default warehouse');
+
+CREATE TABLE warehouse_stock(
+    warehouse_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (warehouse_id, product_id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+SELECT 1, product_id, quantity, COALESCE(updated_at, NOW()) FROM products WHERE quantity > 0;
+
+ALTER TABLE operations ADD COLUMN warehouse_id INTEGER NOT NULL DEFAULT 1 REFERENCES warehouses(warehouse_id);
+ALTER TABLE operations ALTER COLUMN warehouse_id DROP DEFAULT;
+
+ALTER TABLE orders ADD COLUMN warehouse_id INTEGER NOT NULL DEFAULT 1 REFERENCES warehouses(warehouse_id);
+ALTER TABLE orders ALTER COLUMN warehouse_id DROP DEFAULT;
+
+ALTER TABLE reservations ADD COLUMN warehouse_id INTEGER NOT NULL DEFAULT 1 REFERENCES warehouses(warehouse_id);
+ALTER TABLE reservations ALTER COLUMN warehouse_id DROP DEFAULT;
+
+DROP INDEX idx_reservations_active;
+CREATE INDEX idx_reservations_active ON reservations(warehouse_id, product_id, expires_at) WHERE status = 'active';
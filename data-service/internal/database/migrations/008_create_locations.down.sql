@@ -0,0 +1,10 @@
+DELETE FROM operations WHERE operation_type = 'move';
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing', 'adjustment', 'reserve', 'release'));
+
+ALTER TABLE operations DROP COLUMN to_location_id;
+ALTER TABLE operations DROP COLUMN from_location_id;
+
+DROP TABLE location_stock;
+DROP TABLE locations;
@@ -0,0 +1,33 @@
+CREATE TABLE locations(
+    location_id SERIAL PRIMARY KEY,
+    warehouse_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    location_type VARCHAR(10) NOT NULL CHECK (location_type IN ('zone', 'aisle', 'rack', 'bin')),
+    name VARCHAR(150),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id),
+    FOREIGN KEY (parent_id) REFERENCES locations(location_id),
+    CHECK ((location_type = 'zone') = (parent_id IS NULL))
+);
+
+CREATE INDEX idx_locations_parent ON locations(parent_id);
+
+CREATE TABLE location_stock(
+    location_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (location_id, product_id),
+    FOREIGN KEY (location_id) REFERENCES locations(location_id),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_location_stock_product ON location_stock(product_id);
+
+ALTER TABLE operations ADD COLUMN from_location_id INTEGER REFERENCES locations(location_id);
+ALTER TABLE operations ADD COLUMN to_location_id INTEGER REFERENCES locations(location_id);
+
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing', 'adjustment', 'reserve', 'release', 'move'));
@@ -0,0 +1,4 @@
+ALTER TABLE operations DROP COLUMN lot_id;
+
+DROP TABLE order_item_lots;
+DROP TABLE lots;
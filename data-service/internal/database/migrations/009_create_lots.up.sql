@@ -0,0 +1,27 @@
+CREATE TABLE lots(
+    lot_id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    lot_number VARCHAR(100) NOT NULL,
+    manufactured_at DATE,
+    expires_at DATE,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id),
+    UNIQUE (product_id, warehouse_id, lot_number),
+    CHECK (expires_at IS NULL OR manufactured_at IS NULL OR expires_at > manufactured_at)
+);
+
+CREATE INDEX idx_lots_fefo ON lots(warehouse_id, product_id, expires_at) WHERE quantity > 0;
+
+CREATE TABLE order_item_lots(
+    order_item_id INTEGER NOT NULL,
+    lot_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_item_id, lot_id),
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    FOREIGN KEY (lot_id) REFERENCES lots(lot_id)
+);
+
+ALTER TABLE operations ADD COLUMN lot_id INTEGER REFERENCES lots(lot_id);
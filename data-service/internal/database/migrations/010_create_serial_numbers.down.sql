@@ -0,0 +1,4 @@
+DROP TABLE operation_serials;
+DROP TABLE serial_numbers;
+
+ALTER TABLE products DROP COLUMN is_serialized;
@@ -0,0 +1,31 @@
+ALTER TABLE products ADD COLUMN is_serialized BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE serial_numbers(
+    serial_id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    serial_number VARCHAR(100) NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    lot_id INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'in_stock' CHECK (status IN ('in_stock', 'sold', 'scrapped')),
+    order_item_id INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id),
+    FOREIGN KEY (lot_id) REFERENCES lots(lot_id),
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id),
+    UNIQUE (product_id, serial_number)
+);
+
+CREATE INDEX idx_serial_numbers_order_item ON serial_numbers(order_item_id);
+
+-- Links ledger rows to the individual units they moved, so a unit's
+-- history can be read back from operations.
+CREATE TABLE operation_serials(
+    operation_id INTEGER NOT NULL,
+    serial_id INTEGER NOT NULL,
+    PRIMARY KEY (operation_id, serial_id),
+    FOREIGN KEY (operation_id) REFERENCES operations(operation_id),
+    FOREIGN KEY (serial_id) REFERENCES serial_numbers(serial_id)
+);
+
+CREATE INDEX idx_operation_serials_serial ON operation_serials(serial_id);
@@ -0,0 +1,4 @@
+DROP TABLE product_barcodes;
+
+ALTER TABLE products DROP CONSTRAINT products_sku_key;
+ALTER TABLE products DROP COLUMN sku;
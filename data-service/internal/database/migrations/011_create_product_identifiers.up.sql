@@ -0,0 +1,14 @@
+ALTER TABLE products ADD COLUMN sku VARCHAR(64);
+UPDATE products SET sku = 'SKU-' || LPAD(product_id::text, 6, '0');
+ALTER TABLE products ALTER COLUMN sku SET NOT NULL;
+ALTER TABLE products ADD CONSTRAINT products_sku_key UNIQUE (sku);
+
+CREATE TABLE product_barcodes(
+    barcode VARCHAR(64) PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    symbology VARCHAR(10) NOT NULL CHECK (symbology IN ('ean13', 'upca', 'code128')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_barcodes_product ON product_barcodes(product_id);
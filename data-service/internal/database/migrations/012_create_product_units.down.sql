@@ -0,0 +1,11 @@
+ALTER TABLE operations
+    DROP COLUMN unit_quant,
+    DROP COLUMN unit;
+
+ALTER TABLE order_items
+    DROP COLUMN unit_quantity,
+    DROP COLUMN unit;
+
+DROP TABLE product_units;
+
+ALTER TABLE products DROP COLUMN base_unit;
@@ -0,0 +1,17 @@
+ALTER TABLE products ADD COLUMN base_unit VARCHAR(16) NOT NULL DEFAULT 'pcs';
+
+CREATE TABLE product_units(
+    product_id INTEGER NOT NULL,
+    unit VARCHAR(16) NOT NULL,
+    factor INTEGER NOT NULL CHECK (factor > 0),
+    PRIMARY KEY (product_id, unit),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+ALTER TABLE order_items
+    ADD COLUMN unit VARCHAR(16),
+    ADD COLUMN unit_quantity INTEGER;
+
+ALTER TABLE operations
+    ADD COLUMN unit VARCHAR(16),
+    ADD COLUMN unit_quant INTEGER;
@@ -0,0 +1,5 @@
+DROP INDEX idx_products_variant_attributes;
+
+ALTER TABLE products
+    DROP COLUMN attributes,
+    DROP COLUMN parent_id;
@@ -0,0 +1,5 @@
+ALTER TABLE products
+    ADD COLUMN parent_id INTEGER REFERENCES products(product_id),
+    ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE UNIQUE INDEX idx_products_variant_attributes ON products(parent_id, attributes) WHERE parent_id IS NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE bundle_components;
@@ -0,0 +1,11 @@
+CREATE TABLE bundle_components(
+    bundle_id INTEGER NOT NULL,
+    component_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id),
+    FOREIGN KEY (bundle_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (component_id) REFERENCES products(product_id),
+    CHECK (bundle_id <> component_id)
+);
+
+CREATE INDEX idx_bundle_components_component ON bundle_components(component_id);
@@ -0,0 +1,5 @@
+DROP TABLE stock_alerts;
+
+ALTER TABLE products
+    DROP COLUMN reorder_quantity,
+    DROP COLUMN reorder_point;
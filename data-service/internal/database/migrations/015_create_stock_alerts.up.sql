@@ -0,0 +1,18 @@
+ALTER TABLE products
+    ADD COLUMN reorder_point INTEGER CHECK (reorder_point >= 0),
+    ADD COLUMN reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
+
+CREATE TABLE stock_alerts(
+    alert_id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    reorder_point INTEGER NOT NULL,
+    reorder_quantity INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+-- At most one open alert per product: a new one is only raised after the
+-- previous crossing has been resolved by restocking.
+CREATE UNIQUE INDEX idx_stock_alerts_open ON stock_alerts(product_id) WHERE resolved_at IS NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE operations DROP COLUMN po_line_id;
+
+DROP TABLE purchase_order_lines;
+DROP TABLE purchase_orders;
+DROP TABLE suppliers;
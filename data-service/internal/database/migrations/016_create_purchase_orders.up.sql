@@ -0,0 +1,36 @@
+CREATE TABLE suppliers(
+    supplier_id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    phone VARCHAR(50),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE purchase_orders(
+    po_id SERIAL PRIMARY KEY,
+    supplier_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'closed')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (supplier_id) REFERENCES suppliers(supplier_id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id)
+);
+
+CREATE INDEX idx_purchase_orders_supplier ON purchase_orders(supplier_id);
+
+CREATE TABLE purchase_order_lines(
+    po_line_id SERIAL PRIMARY KEY,
+    po_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity_ordered INTEGER NOT NULL CHECK (quantity_ordered > 0),
+    quantity_received INTEGER NOT NULL DEFAULT 0 CHECK (quantity_received >= 0),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    FOREIGN KEY (po_id) REFERENCES purchase_orders(po_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    UNIQUE (po_id, product_id)
+);
+
+ALTER TABLE operations ADD COLUMN po_line_id INTEGER REFERENCES purchase_order_lines(po_line_id);
@@ -0,0 +1,5 @@
+DROP TABLE count_entry_serials;
+DROP TABLE count_entries;
+DROP TABLE count_lines;
+DROP TABLE count_session_locations;
+DROP TABLE count_sessions;
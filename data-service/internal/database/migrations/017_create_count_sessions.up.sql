@@ -0,0 +1,60 @@
+CREATE TABLE count_sessions(
+    session_id SERIAL PRIMARY KEY,
+    warehouse_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'approved', 'cancelled')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    closed_at TIMESTAMPTZ,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id)
+);
+
+-- Bins frozen by a session counted by location. Products found in them
+-- that were not there at freeze time are added as lines while counting.
+CREATE TABLE count_session_locations(
+    session_id INTEGER NOT NULL,
+    location_id INTEGER NOT NULL,
+    PRIMARY KEY (session_id, location_id),
+    FOREIGN KEY (session_id) REFERENCES count_sessions(session_id) ON DELETE CASCADE,
+    FOREIGN KEY (location_id) REFERENCES locations(location_id)
+);
+
+-- A line with no location counts the product's whole warehouse stock.
+CREATE TABLE count_lines(
+    count_line_id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    location_id INTEGER,
+    frozen_quantity INTEGER NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES count_sessions(session_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    FOREIGN KEY (location_id) REFERENCES locations(location_id)
+);
+
+CREATE UNIQUE INDEX idx_count_lines_item ON count_lines(session_id, product_id, COALESCE(location_id, 0));
+
+-- book_quantity is the system stock at the moment of counting, so that
+-- movements during the session do not show up as variance.
+CREATE TABLE count_entries(
+    entry_id SERIAL PRIMARY KEY,
+    count_line_id INTEGER NOT NULL,
+    counter VARCHAR(100) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    book_quantity INTEGER NOT NULL,
+    counted_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (count_line_id) REFERENCES count_lines(count_line_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_count_entries_line ON count_entries(count_line_id);
+
+-- Serialized products are counted unit by unit. Each entry keeps the
+-- serials its counter found next to the ones the books held in the
+-- warehouse at that moment; where the two differ is the entry's variance.
+CREATE TABLE count_entry_serials(
+    entry_id INTEGER NOT NULL,
+    serial_number VARCHAR(100) NOT NULL,
+    counted BOOLEAN NOT NULL,
+    on_book BOOLEAN NOT NULL,
+    PRIMARY KEY (entry_id, serial_number),
+    FOREIGN KEY (entry_id) REFERENCES count_entries(entry_id) ON DELETE CASCADE,
+    CHECK (counted OR on_book)
+);
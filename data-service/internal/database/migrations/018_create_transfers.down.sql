@@ -0,0 +1,19 @@
+DELETE FROM operations WHERE operation_type IN ('transfer_out', 'transfer_in', 'transit_loss');
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing', 'adjustment', 'reserve', 'release', 'move'));
+
+ALTER TABLE operations DROP COLUMN transfer_id;
+
+DROP TABLE transfer_line_serials;
+
+UPDATE serial_numbers SET status = 'in_stock' WHERE status = 'in_transit';
+UPDATE serial_numbers SET status = 'scrapped' WHERE status = 'lost';
+
+ALTER TABLE serial_numbers DROP CONSTRAINT serial_numbers_status_check;
+ALTER TABLE serial_numbers ADD CONSTRAINT serial_numbers_status_check
+    CHECK (status IN ('in_stock', 'sold', 'scrapped'));
+
+DROP TABLE transfer_line_lots;
+DROP TABLE transfer_lines;
+DROP TABLE transfers;
@@ -0,0 +1,68 @@
+CREATE TABLE transfers(
+    transfer_id SERIAL PRIMARY KEY,
+    from_warehouse_id INTEGER NOT NULL,
+    to_warehouse_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'in_transit', 'received', 'cancelled')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    FOREIGN KEY (from_warehouse_id) REFERENCES warehouses(warehouse_id),
+    FOREIGN KEY (to_warehouse_id) REFERENCES warehouses(warehouse_id),
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
+
+CREATE INDEX idx_transfers_status ON transfers(status);
+
+-- quantity_received stays NULL until the transfer arrives; any shortfall
+-- to quantity is the discrepancy found on receipt. A line can never
+-- receive more than was dispatched.
+CREATE TABLE transfer_lines(
+    transfer_line_id SERIAL PRIMARY KEY,
+    transfer_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    quantity_received INTEGER CHECK (quantity_received >= 0),
+    FOREIGN KEY (transfer_id) REFERENCES transfers(transfer_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    UNIQUE (transfer_id, product_id),
+    CHECK (quantity_received <= quantity)
+);
+
+-- Lots the goods left the source warehouse from, so they can be filed
+-- under the same lot numbers at the destination.
+CREATE TABLE transfer_line_lots(
+    transfer_line_id INTEGER NOT NULL,
+    lot_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (transfer_line_id, lot_id),
+    FOREIGN KEY (transfer_line_id) REFERENCES transfer_lines(transfer_line_id) ON DELETE CASCADE,
+    FOREIGN KEY (lot_id) REFERENCES lots(lot_id)
+);
+
+-- Serialized goods travel between warehouses as named units: they are
+-- in_transit from dispatch until they arrive, or lost if they never do.
+ALTER TABLE serial_numbers DROP CONSTRAINT serial_numbers_status_check;
+ALTER TABLE serial_numbers ADD CONSTRAINT serial_numbers_status_check
+    CHECK (status IN ('in_stock', 'in_transit', 'sold', 'scrapped', 'lost'));
+
+-- The units a transfer line of a serialized product sends.
+CREATE TABLE transfer_line_serials(
+    transfer_line_id INTEGER NOT NULL,
+    serial_id INTEGER NOT NULL,
+    PRIMARY KEY (transfer_line_id, serial_id),
+    FOREIGN KEY (transfer_line_id) REFERENCES transfer_lines(transfer_line_id) ON DELETE CASCADE,
+    FOREIGN KEY (serial_id) REFERENCES serial_numbers(serial_id)
+);
+
+CREATE INDEX idx_transfer_line_serials_serial ON transfer_line_serials(serial_id);
+
+ALTER TABLE operations ADD COLUMN transfer_id INTEGER REFERENCES transfers(transfer_id);
+
+-- Goods dispatched but never received are written off as transit_loss at
+-- the destination. They already left stock on dispatch, so the loss only
+-- records their quantity and cost.
+ALTER TABLE operations DROP CONSTRAINT operations_operation_type_check;
+ALTER TABLE operations ADD CONSTRAINT operations_operation_type_check
+    CHECK (operation_type IN ('incoming', 'outgoing', 'adjustment', 'reserve', 'release', 'move',
+        'transfer_out', 'transfer_in', 'transit_loss'));
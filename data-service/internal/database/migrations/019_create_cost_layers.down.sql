@@ -0,0 +1,9 @@
+DROP TABLE cost_layers;
+
+ALTER TABLE operations
+    DROP COLUMN cost_amount,
+    DROP COLUMN unit_cost;
+
+ALTER TABLE products
+    DROP COLUMN average_cost,
+    DROP COLUMN cost_method;
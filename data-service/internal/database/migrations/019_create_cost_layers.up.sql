@@ -0,0 +1,22 @@
+ALTER TABLE products
+    ADD COLUMN cost_method VARCHAR(10) NOT NULL DEFAULT 'fifo' CHECK (cost_method IN ('fifo', 'average')),
+    ADD COLUMN average_cost DECIMAL(14,4) NOT NULL DEFAULT 0 CHECK (average_cost >= 0);
+
+-- unit_cost is per base unit; cost_amount is the signed change in stock
+-- value, so outgoing operations carry their cost of goods sold.
+ALTER TABLE operations
+    ADD COLUMN unit_cost DECIMAL(14,4),
+    ADD COLUMN cost_amount DECIMAL(14,4);
+
+-- One layer per costed stock addition, consumed oldest first.
+CREATE TABLE cost_layers(
+    layer_id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    remaining INTEGER NOT NULL CHECK (remaining >= 0 AND remaining <= quantity),
+    unit_cost DECIMAL(14,4) NOT NULL CHECK (unit_cost >= 0),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_cost_layers_open ON cost_layers(product_id, layer_id) WHERE remaining > 0;
@@ -0,0 +1,5 @@
+DROP INDEX idx_operations_created;
+DROP INDEX idx_operations_product_created;
+
+DROP TABLE stock_snapshot_lines;
+DROP TABLE stock_snapshots;
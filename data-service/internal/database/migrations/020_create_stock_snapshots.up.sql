@@ -0,0 +1,22 @@
+-- Ledger totals per product and warehouse at taken_at. A point-in-time
+-- query starts from the latest snapshot before the requested moment and
+-- only sums the operations after it.
+CREATE TABLE stock_snapshots(
+    snapshot_id SERIAL PRIMARY KEY,
+    taken_at TIMESTAMPTZ NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE stock_snapshot_lines(
+    snapshot_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    PRIMARY KEY (snapshot_id, product_id, warehouse_id),
+    FOREIGN KEY (snapshot_id) REFERENCES stock_snapshots(snapshot_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id)
+);
+
+CREATE INDEX idx_operations_product_created ON operations(product_id, created_at);
+CREATE INDEX idx_operations_created ON operations(created_at);
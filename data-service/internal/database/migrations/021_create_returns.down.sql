@@ -0,0 +1,3 @@
+DROP TABLE return_inspections;
+DROP TABLE return_lines;
+DROP TABLE returns;
@@ -0,0 +1,47 @@
+-- Return authorizations. Goods go back into warehouse_id, which defaults to
+-- the warehouse the order shipped from.
+CREATE TABLE returns(
+    return_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'authorized'
+        CHECK (status IN ('authorized', 'received', 'cancelled')),
+    reason TEXT,
+    refund_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    received_at TIMESTAMPTZ,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id),
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id)
+);
+
+CREATE INDEX idx_returns_order ON returns(order_id);
+
+-- quantity is what was authorized, in the product's base unit;
+-- quantity_received stays NULL until the goods arrive.
+CREATE TABLE return_lines(
+    return_line_id SERIAL PRIMARY KEY,
+    return_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    quantity_received INTEGER CHECK (quantity_received >= 0 AND quantity_received <= quantity),
+    refund_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (return_id) REFERENCES returns(return_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id),
+    UNIQUE (return_id, order_item_id)
+);
+
+CREATE INDEX idx_return_lines_order_item ON return_lines(order_item_id);
+
+-- What inspection decided for the units of a line that arrived. Only
+-- restocked units go back into stock.
+CREATE TABLE return_inspections(
+    inspection_id SERIAL PRIMARY KEY,
+    return_line_id INTEGER NOT NULL,
+    outcome VARCHAR(20) NOT NULL CHECK (outcome IN ('restock', 'damaged', 'scrap')),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    to_location_id INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (return_line_id) REFERENCES return_lines(return_line_id) ON DELETE CASCADE,
+    FOREIGN KEY (to_location_id) REFERENCES locations(location_id),
+    CHECK (outcome = 'restock' OR to_location_id IS NULL)
+);
@@ -0,0 +1,14 @@
+-- Every status an order went through. from_status is NULL for the row
+-- written when the order is created; orders older than this table have no
+-- history.
+CREATE TABLE order_status_history(
+    history_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    from_status VARCHAR(155),
+    to_status VARCHAR(155) NOT NULL,
+    changed_by VARCHAR(255),
+    changed_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_order_status_history_order ON order_status_history(order_id, changed_at);
@@ -0,0 +1,13 @@
+DROP VIEW committed_stock;
+DROP TABLE shipment_lines;
+DROP TABLE shipments;
+
+UPDATE serial_numbers SET status = 'sold' WHERE status = 'allocated';
+
+ALTER TABLE serial_numbers DROP CONSTRAINT serial_numbers_status_check;
+ALTER TABLE serial_numbers ADD CONSTRAINT serial_numbers_status_check
+    CHECK (status IN ('in_stock', 'in_transit', 'sold', 'scrapped', 'lost'));
+
+ALTER TABLE order_items DROP CONSTRAINT order_items_quantity_shipped_check;
+ALTER TABLE order_items DROP COLUMN quantity_shipped;
+ALTER TABLE orders DROP COLUMN stock_timing;
@@ -0,0 +1,59 @@
+-- stock_timing records when an order's stock leaves the warehouse: when the
+-- order is placed, or when each shipment is confirmed. It is fixed per
+-- order so changing the deployment setting does not affect open orders.
+ALTER TABLE orders ADD COLUMN stock_timing VARCHAR(20) NOT NULL DEFAULT 'order'
+    CHECK (stock_timing IN ('order', 'shipment'));
+
+ALTER TABLE order_items ADD COLUMN quantity_shipped INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD CONSTRAINT order_items_quantity_shipped_check
+    CHECK (quantity_shipped >= 0 AND quantity_shipped <= quantity);
+
+UPDATE order_items oi SET quantity_shipped = oi.quantity
+FROM orders o
+WHERE o.order_id = oi.order_id AND o.status = 'shipped';
+
+-- Serials assigned to an order that takes its stock on shipment are
+-- allocated until the line ships; only then are they sold.
+ALTER TABLE serial_numbers DROP CONSTRAINT serial_numbers_status_check;
+ALTER TABLE serial_numbers ADD CONSTRAINT serial_numbers_status_check
+    CHECK (status IN ('in_stock', 'allocated', 'in_transit', 'sold', 'scrapped', 'lost'));
+
+CREATE TABLE shipments(
+    shipment_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'confirmed', 'cancelled')),
+    carrier VARCHAR(100),
+    tracking_number VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    confirmed_at TIMESTAMPTZ,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id)
+);
+
+CREATE INDEX idx_shipments_order ON shipments(order_id);
+
+-- Quantities are in the order line's units, so a bundle line ships as
+-- whole bundles.
+CREATE TABLE shipment_lines(
+    shipment_line_id SERIAL PRIMARY KEY,
+    shipment_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    FOREIGN KEY (shipment_id) REFERENCES shipments(shipment_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id),
+    UNIQUE (shipment_id, order_item_id)
+);
+
+-- Stock promised to open orders that only take it when they ship, per
+-- warehouse and stocked product (bundle lines count as their components).
+CREATE VIEW committed_stock AS
+SELECT
+    o.warehouse_id,
+    COALESCE(bc.component_id, oi.product_id) AS product_id,
+    (oi.quantity - oi.quantity_shipped) * COALESCE(bc.quantity, 1) AS quantity
+FROM order_items oi
+JOIN orders o ON o.order_id = oi.order_id
+LEFT JOIN bundle_components bc ON bc.bundle_id = oi.product_id
+WHERE o.stock_timing = 'shipment'
+    AND o.status IN ('created', 'paid', 'partially_shipped')
+    AND oi.quantity > oi.quantity_shipped;
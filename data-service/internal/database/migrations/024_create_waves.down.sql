@@ -0,0 +1,8 @@
+DROP TABLE pick_allocations;
+DROP TABLE pick_lines;
+DROP TABLE wave_orders;
+DROP TABLE waves;
+
+ALTER TABLE orders DROP COLUMN needs_attention;
+ALTER TABLE orders DROP COLUMN ship_by;
+ALTER TABLE orders DROP COLUMN carrier;
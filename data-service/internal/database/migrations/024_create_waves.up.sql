@@ -0,0 +1,72 @@
+-- carrier and ship_by decide which wave an order is picked in;
+-- needs_attention is raised when a pick for the order comes up short.
+ALTER TABLE orders ADD COLUMN carrier VARCHAR(100);
+ALTER TABLE orders ADD COLUMN ship_by TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN needs_attention BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- A wave is one round of picking for the open orders of one carrier and,
+-- where all of their picks lie in one, one zone.
+CREATE TABLE waves(
+    wave_id SERIAL PRIMARY KEY,
+    warehouse_id INTEGER NOT NULL,
+    carrier VARCHAR(100),
+    zone_id INTEGER,
+    cutoff TIMESTAMPTZ,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'completed', 'cancelled')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id),
+    FOREIGN KEY (zone_id) REFERENCES locations(location_id)
+);
+
+CREATE INDEX idx_waves_status ON waves(warehouse_id, status);
+
+CREATE TABLE wave_orders(
+    wave_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    PRIMARY KEY (wave_id, order_id),
+    FOREIGN KEY (wave_id) REFERENCES waves(wave_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(order_id)
+);
+
+CREATE INDEX idx_wave_orders_order ON wave_orders(order_id);
+
+-- One line per product and bin across the wave's orders. A NULL location
+-- is stock that has not been put away into a bin yet. quantity_picked
+-- stays NULL until the picker confirms the line.
+CREATE TABLE pick_lines(
+    pick_line_id SERIAL PRIMARY KEY,
+    wave_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    location_id INTEGER,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    quantity_picked INTEGER,
+    picked_by VARCHAR(100),
+    picked_at TIMESTAMPTZ,
+    FOREIGN KEY (wave_id) REFERENCES waves(wave_id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    FOREIGN KEY (location_id) REFERENCES locations(location_id),
+    CHECK (quantity_picked >= 0 AND quantity_picked <= quantity)
+);
+
+CREATE UNIQUE INDEX idx_pick_lines_bin ON pick_lines(wave_id, product_id, COALESCE(location_id, 0));
+
+-- How a pick line splits over the order lines it serves. shipped_before is
+-- how much of the order line (in the picked product's units) had shipped
+-- when the wave was planned. Whatever has shipped since came out of the
+-- wave's picks, so the rest of quantity_picked is still waiting in the
+-- warehouse to ship.
+CREATE TABLE pick_allocations(
+    pick_line_id INTEGER NOT NULL,
+    order_item_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    quantity_picked INTEGER,
+    shipped_before INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (pick_line_id, order_item_id),
+    FOREIGN KEY (pick_line_id) REFERENCES pick_lines(pick_line_id) ON DELETE CASCADE,
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id),
+    CHECK (quantity_picked >= 0 AND quantity_picked <= quantity)
+);
+
+CREATE INDEX idx_pick_allocations_item ON pick_allocations(order_item_id);
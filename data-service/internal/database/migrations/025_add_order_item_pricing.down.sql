@@ -0,0 +1,5 @@
+ALTER TABLE order_items DROP CONSTRAINT order_items_override_check;
+ALTER TABLE order_items
+    DROP COLUMN override_by,
+    DROP COLUMN override_reason,
+    DROP COLUMN list_price;
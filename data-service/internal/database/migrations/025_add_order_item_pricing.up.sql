@@ -0,0 +1,14 @@
+-- price is what the line is charged per base unit. list_price snapshots the
+-- catalogue price when the order was placed; a line charged anything else
+-- carries the reason for the override and the ID of the manager who
+-- authorized it.
+ALTER TABLE order_items
+    ADD COLUMN list_price DECIMAL(10,2),
+    ADD COLUMN override_reason TEXT,
+    ADD COLUMN override_by INT;
+
+UPDATE order_items SET list_price = price;
+
+ALTER TABLE order_items ALTER COLUMN list_price SET NOT NULL;
+ALTER TABLE order_items ADD CONSTRAINT order_items_override_check
+    CHECK ((override_reason IS NULL) = (override_by IS NULL) AND override_by > 0);
@@ -0,0 +1,6 @@
+DROP TABLE order_discounts;
+
+ALTER TABLE orders DROP COLUMN promo_code;
+ALTER TABLE orders DROP COLUMN discount_amount;
+
+DROP TABLE promotions;
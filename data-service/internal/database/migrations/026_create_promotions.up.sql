@@ -0,0 +1,47 @@
+-- A promotion without a code applies automatically. value is a percentage
+-- for 'percent' and an amount for 'fixed'; 'buy_x_get_y' gives get_quantity
+-- units free for every buy_quantity units paid. product_id or category
+-- narrow it to some lines, min_order_value to large enough orders.
+CREATE TABLE promotions(
+    promotion_id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE,
+    name VARCHAR(150) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('percent', 'fixed', 'buy_x_get_y')),
+    value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (value >= 0),
+    buy_quantity INTEGER CHECK (buy_quantity > 0),
+    get_quantity INTEGER CHECK (get_quantity > 0),
+    product_id INTEGER,
+    category VARCHAR(200),
+    min_order_value DECIMAL(10,2) CHECK (min_order_value > 0),
+    max_uses INTEGER CHECK (max_uses > 0),
+    max_uses_per_customer INTEGER CHECK (max_uses_per_customer > 0),
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMPTZ,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    FOREIGN KEY (product_id) REFERENCES products(product_id),
+    CHECK (kind <> 'percent' OR value <= 100),
+    CHECK ((kind = 'buy_x_get_y') = (buy_quantity IS NOT NULL AND get_quantity IS NOT NULL)),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+-- total_amount is what the customer pays: the lines less discount_amount.
+ALTER TABLE orders ADD COLUMN discount_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN promo_code VARCHAR(50);
+
+-- One row per promotion and order line it discounted; order_item_id is
+-- NULL for discounts on the order as a whole.
+CREATE TABLE order_discounts(
+    order_discount_id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    promotion_id INTEGER NOT NULL,
+    order_item_id INTEGER,
+    description VARCHAR(255) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY (promotion_id) REFERENCES promotions(promotion_id),
+    FOREIGN KEY (order_item_id) REFERENCES order_items(order_item_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_order_discounts_order ON order_discounts(order_id);
+CREATE INDEX idx_order_discounts_promotion ON order_discounts(promotion_id);
@@ -0,0 +1,15 @@
+ALTER TABLE order_items
+    DROP COLUMN gross_amount,
+    DROP COLUMN tax_amount,
+    DROP COLUMN tax_rate,
+    DROP COLUMN net_amount;
+
+ALTER TABLE orders
+    DROP COLUMN tax_amount,
+    DROP COLUMN net_amount,
+    DROP COLUMN prices_include_tax,
+    DROP COLUMN tax_jurisdiction;
+
+DROP TABLE tax_rates;
+
+ALTER TABLE products DROP COLUMN tax_class;
@@ -0,0 +1,41 @@
+ALTER TABLE products ADD COLUMN tax_class VARCHAR(50) NOT NULL DEFAULT 'standard';
+
+-- rate is a percentage. A rate applies from effective_from up to the day
+-- before effective_to; NULL means until further notice.
+CREATE TABLE tax_rates(
+    tax_rate_id SERIAL PRIMARY KEY,
+    jurisdiction VARCHAR(50) NOT NULL,
+    tax_class VARCHAR(50) NOT NULL,
+    rate DECIMAL(7,4) NOT NULL CHECK (rate >= 0),
+    effective_from DATE NOT NULL,
+    effective_to DATE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (jurisdiction, tax_class, effective_from),
+    CHECK (effective_to IS NULL OR effective_to > effective_from)
+);
+
+-- An order keeps the jurisdiction it was taxed in and whether its prices
+-- included tax; total_amount is the gross amount.
+ALTER TABLE orders
+    ADD COLUMN tax_jurisdiction VARCHAR(50),
+    ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN net_amount DECIMAL(12,2),
+    ADD COLUMN tax_amount DECIMAL(12,2) NOT NULL DEFAULT 0;
+
+-- Line amounts are after discounts; tax_rate is the percentage applied.
+ALTER TABLE order_items
+    ADD COLUMN net_amount DECIMAL(12,2),
+    ADD COLUMN tax_rate DECIMAL(7,4) NOT NULL DEFAULT 0,
+    ADD COLUMN tax_amount DECIMAL(12,2) NOT NULL DEFAULT 0,
+    ADD COLUMN gross_amount DECIMAL(12,2);
+
+UPDATE order_items oi SET net_amount = oi.price * oi.quantity - COALESCE((
+    SELECT SUM(od.amount) FROM order_discounts od
+    WHERE od.order_item_id = oi.order_item_id
+), 0);
+UPDATE order_items SET gross_amount = net_amount;
+UPDATE orders SET net_amount = total_amount;
+
+ALTER TABLE order_items ALTER COLUMN net_amount SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN gross_amount SET NOT NULL;
+ALTER TABLE orders ALTER COLUMN net_amount SET NOT NULL;
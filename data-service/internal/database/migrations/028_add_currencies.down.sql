@@ -0,0 +1,5 @@
+ALTER TABLE orders
+    DROP COLUMN exchange_rate,
+    DROP COLUMN currency;
+
+DROP TABLE exchange_rates;
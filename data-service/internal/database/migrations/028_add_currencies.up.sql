@@ -0,0 +1,16 @@
+-- rate is what one unit of currency is worth in the base currency; it
+-- applies from effective_from until the currency's next rate.
+CREATE TABLE exchange_rates(
+    exchange_rate_id SERIAL PRIMARY KEY,
+    currency CHAR(3) NOT NULL,
+    rate DECIMAL(18,8) NOT NULL CHECK (rate > 0),
+    effective_from DATE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (currency, effective_from)
+);
+
+-- An order keeps its currency and the rate it was placed at. Orders from
+-- before currencies were recorded have none and are in the base currency.
+ALTER TABLE orders
+    ADD COLUMN currency CHAR(3),
+    ADD COLUMN exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1 CHECK (exchange_rate > 0);
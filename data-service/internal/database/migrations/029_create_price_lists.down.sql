@@ -0,0 +1,6 @@
+ALTER TABLE order_items DROP COLUMN price_list_id;
+
+DROP TABLE price_list_prices;
+DROP TABLE price_lists;
+
+ALTER TABLE customers DROP COLUMN customer_group;
@@ -0,0 +1,31 @@
+ALTER TABLE customers ADD COLUMN customer_group VARCHAR(50);
+
+-- A price list is for one customer or for a customer group. It applies
+-- from valid_from up to the day before valid_to; NULL means until further
+-- notice.
+CREATE TABLE price_lists(
+    price_list_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    customer_id INT REFERENCES customers(customer_id) ON DELETE CASCADE,
+    customer_group VARCHAR(50),
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK ((customer_id IS NULL) <> (customer_group IS NULL)),
+    CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX idx_price_lists_customer ON price_lists(customer_id);
+CREATE INDEX idx_price_lists_group ON price_lists(customer_group);
+
+-- price is in the base currency and applies to orders of at least
+-- min_quantity base units of the product.
+CREATE TABLE price_list_prices(
+    price_list_id INT NOT NULL REFERENCES price_lists(price_list_id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(product_id),
+    min_quantity INT NOT NULL DEFAULT 1 CHECK (min_quantity > 0),
+    price DECIMAL(10,2) NOT NULL CHECK (price > 0),
+    PRIMARY KEY (price_list_id, product_id, min_quantity)
+);
+
+ALTER TABLE order_items ADD COLUMN price_list_id INT REFERENCES price_lists(price_list_id);
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// StockAlert is raised when a product's stock falls to or below its reorder
+// point and resolved once stock is back above it.
+type StockAlert struct {
+	AlertID         int        `json:"alert_id"`
+	ProductID       int        `json:"product_id"`
+	Quantity        int        `json:"quantity"`
+	ReorderPoint    int        `json:"reorder_point"`
+	ReorderQuantity int        `json:"reorder_quantity"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
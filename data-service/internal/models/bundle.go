@@ -0,0 +1,19 @@
+package models
+
+// Bundle is a kit sold as one product but stocked only as its components.
+// Available is the number of complete kits the components allow in
+// WarehouseID.
+type Bundle struct {
+	ProductID   int               `json:"product_id"`
+	WarehouseID int               `json:"warehouse_id"`
+	Components  []BundleComponent `json:"components"`
+	Available   int               `json:"available"`
+}
+
+// BundleComponent is Quantity units of a product per kit. Available is the
+// component's own available stock.
+type BundleComponent struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+	Available int `json:"available"`
+}
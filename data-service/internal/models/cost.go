@@ -0,0 +1,64 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Cost is an exact cost in ten-thousandths of a currency unit, which is
+// what the DECIMAL(14,4) cost columns hold. Unit costs need the extra
+// places: a case bought for 10.00 and split into three is 3.3333 a unit.
+type Cost int64
+
+// ParseCost reads a decimal cost such as "3.3333". More than four decimal
+// places is an error rather than being rounded away.
+func ParseCost(s string) (Cost, error) {
+	units, err := parseDecimal(s, 4)
+	return Cost(units), err
+}
+
+func (c Cost) String() string {
+	return formatDecimal(int64(c), 4)
+}
+
+// Mul is the cost of quantity units at c each.
+func (c Cost) Mul(quantity int) Cost {
+	return c * Cost(quantity)
+}
+
+// Per is the cost of one of quantity units costing c together, rounded
+// half away from zero.
+func (c Cost) Per(quantity int) Cost {
+	return Cost(divRound(big.NewInt(int64(c)), big.NewInt(1), big.NewInt(int64(quantity))).Int64())
+}
+
+// Money rounds the cost to the cent half away from zero.
+func (c Cost) Money() Money {
+	return Money(divRound(big.NewInt(int64(c)), big.NewInt(1), big.NewInt(100)).Int64())
+}
+
+func (c Cost) MarshalJSON() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalJSON takes the cost as a number or as a string.
+func (c *Cost) UnmarshalJSON(data []byte) error {
+	return unmarshalDecimal(data, 4, (*int64)(c))
+}
+
+// ScanNumeric reads a numeric column, rounding computed values with more
+// than four decimal places.
+func (c *Cost) ScanNumeric(v pgtype.Numeric) error {
+	units, err := scanDecimal(v, 4)
+	if err != nil {
+		return err
+	}
+
+	*c = Cost(units)
+	return nil
+}
+
+func (c Cost) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(c)), Exp: -4, Valid: true}, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+const (
+	CountOpen      = "open"
+	CountApproved  = "approved"
+	CountCancelled = "cancelled"
+)
+
+// CountSession is a stock-take of either a set of products or a set of bins
+// in one warehouse. Creating it freezes the book quantities of everything
+// in scope; approving it posts the counted variances as adjustments.
+type CountSession struct {
+	SessionID   int        `json:"session_id"`
+	WarehouseID int        `json:"warehouse_id"`
+	Status      string     `json:"status"`
+	ProductIDs  []int      `json:"product_ids,omitempty"`
+	LocationIDs []int      `json:"location_ids,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+
+	Lines []CountLine `json:"lines,omitempty"`
+}
+
+// CountLine is one product to count, in one bin or, without LocationID, in
+// the whole warehouse. FrozenQuantity is the book stock when the session
+// was created and BookQuantity the book stock now. The latest entry is the
+// line's count; Variance is that count against the book stock at the
+// moment it was taken.
+type CountLine struct {
+	LineID          int  `json:"count_line_id"`
+	ProductID       int  `json:"product_id"`
+	LocationID      *int `json:"location_id,omitempty"`
+	FrozenQuantity  int  `json:"frozen_quantity"`
+	BookQuantity    int  `json:"book_quantity"`
+	CountedQuantity *int `json:"counted_quantity,omitempty"`
+	Variance        *int `json:"variance,omitempty"`
+
+	Entries []CountEntry `json:"entries,omitempty"`
+}
+
+// CountEntry is one counter's count of a line. Recounts add entries rather
+// than replacing them. A count of a serialized product names every unit
+// counted in Serials; FoundSerials were counted but not on the books and
+// MissingSerials were on the books but not counted.
+type CountEntry struct {
+	EntryID        int       `json:"entry_id"`
+	LineID         int       `json:"count_line_id"`
+	ProductID      int       `json:"product_id"`
+	LocationID     *int      `json:"location_id,omitempty"`
+	Counter        string    `json:"counter"`
+	Quantity       int       `json:"quantity"`
+	BookQuantity   int       `json:"book_quantity"`
+	CountedAt      time.Time `json:"counted_at"`
+	Serials        []string  `json:"serials,omitempty"`
+	FoundSerials   []string  `json:"found_serials,omitempty"`
+	MissingSerials []string  `json:"missing_serials,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// DefaultBaseCurrency is the currency reports are in when no other is
+// configured.
+const DefaultBaseCurrency = "USD"
+
+// ExchangeRate is what one unit of Currency is worth in the base currency,
+// from EffectiveFrom until the currency's next rate.
+type ExchangeRate struct {
+	ExchangeRateID int       `json:"exchange_rate_id"`
+	Currency       string    `json:"currency"`
+	Rate           Rate      `json:"rate"`
+	EffectiveFrom  time.Time `json:"effective_from"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SalesReport sums the orders placed from From up to and including To,
+// cancelled ones and drafts left out. Its amounts are in BaseCurrency;
+// Currencies breaks them down by the currency the orders were placed in.
+type SalesReport struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	BaseCurrency string          `json:"base_currency"`
+	Orders       int             `json:"orders"`
+	NetAmount    Money           `json:"net_amount"`
+	TaxAmount    Money           `json:"tax_amount"`
+	TotalAmount  Money           `json:"total_amount"`
+	Currencies   []CurrencySales `json:"currencies"`
+}
+
+// CurrencySales gives the amounts of one currency's orders both in that
+// currency and converted at the rates the orders were placed at.
+type CurrencySales struct {
+	Currency        string `json:"currency"`
+	Orders          int    `json:"orders"`
+	NetAmount       Money  `json:"net_amount"`
+	TaxAmount       Money  `json:"tax_amount"`
+	TotalAmount     Money  `json:"total_amount"`
+	BaseNetAmount   Money  `json:"base_net_amount"`
+	BaseTaxAmount   Money  `json:"base_tax_amount"`
+	BaseTotalAmount Money  `json:"base_total_amount"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	LocationZone  = "zone"
+	LocationAisle = "aisle"
+	LocationRack  = "rack"
+	LocationBin   = "bin"
+)
+
+// Location is one node of a warehouse's zone/aisle/rack/bin hierarchy.
+// Stock is only ever held in bins.
+type Location struct {
+	LocationID  int       `json:"location_id"`
+	WarehouseID int       `json:"warehouse_id"`
+	ParentID    *int      `json:"parent_id,omitempty"`
+	Code        string    `json:"code"`
+	Type        string    `json:"location_type"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LocationStock struct {
+	LocationID int       `json:"location_id"`
+	ProductID  int       `json:"product_id"`
+	Quantity   int       `json:"quantity"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// StockMove relocates stock inside one warehouse. A nil FromLocationID puts
+// away stock that has no bin yet; a nil ToLocationID takes it out of its bin.
+type StockMove struct {
+	ProductID      int  `json:"product_id"`
+	FromLocationID *int `json:"from_location_id,omitempty"`
+	ToLocationID   *int `json:"to_location_id,omitempty"`
+	Quantity       int  `json:"quantity"`
+}
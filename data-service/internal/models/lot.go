@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Lot is a batch of one product received into one warehouse. ExpiresAt is
+// nil for goods that do not perish.
+type Lot struct {
+	LotID          int        `json:"lot_id"`
+	ProductID      int        `json:"product_id"`
+	WarehouseID    int        `json:"warehouse_id"`
+	LotNumber      string     `json:"lot_number"`
+	ManufacturedAt *time.Time `json:"manufactured_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Quantity       int        `json:"quantity"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Expired reports whether the lot can no longer be sold on the given day.
+func (l *Lot) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
+
+// Receipt is an incoming delivery of one product. With a lot number it is
+// filed under that lot, appending if the lot already exists in the
+// warehouse. Serialized products list one serial number per unit. With a
+// Unit, UnitQuantity is converted into Quantity in the base unit. UnitCost
+// is per base unit; without it the goods are valued at the current average.
+// With an OrderID the goods are a customer return and any serial numbers
+// are units sold on that order.
+type Receipt struct {
+	ProductID      int        `json:"product_id"`
+	WarehouseID    int        `json:"warehouse_id"`
+	LotNumber      string     `json:"lot_number,omitempty"`
+	ManufacturedAt *time.Time `json:"manufactured_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Quantity       int        `json:"quantity"`
+	Unit           string     `json:"unit,omitempty"`
+	UnitQuantity   int        `json:"unit_quantity,omitempty"`
+	ToLocationID   *int       `json:"to_location_id,omitempty"`
+	Serials        []string   `json:"serials,omitempty"`
+	POLineID       *int       `json:"po_line_id,omitempty"`
+	TransferID     *int       `json:"transfer_id,omitempty"`
+	OrderID        *int       `json:"order_id,omitempty"`
+	UnitCost       *Cost      `json:"unit_cost,omitempty"`
+
+	LotID       *int `json:"lot_id,omitempty"`
+	OperationID int  `json:"operation_id"`
+}
+
+type OrderItemLot struct {
+	LotID    int `json:"lot_id"`
+	Quantity int `json:"quantity"`
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Money is an exact amount in cents, the hundredths of a currency unit,
+// which is what the DECIMAL(…,2) money columns hold. It reads and writes
+// those columns and JSON numbers without going through floating point.
+type Money int64
+
+// ParseMoney reads a decimal amount such as "12.34" or "-0.5". More than
+// two decimal places is an error rather than being rounded away.
+func ParseMoney(s string) (Money, error) {
+	cents, err := parseDecimal(s, 2)
+	return Money(cents), err
+}
+
+// parseDecimal reads a decimal number into units of 10^-places.
+func parseDecimal(s string, places int) (int64, error) {
+	text := strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > places {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, places)
+	}
+	frac += strings.Repeat("0", places-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	for _, digits := range []string{whole, frac} {
+		if strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		units = -units
+	}
+
+	return units, nil
+}
+
+func (m Money) String() string {
+	return formatDecimal(int64(m), 2)
+}
+
+// formatDecimal writes units of 10^-places as a decimal number.
+func formatDecimal(units int64, places int) string {
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+
+	abs := new(big.Int).Abs(big.NewInt(units)).String()
+	if len(abs) <= places {
+		abs = strings.Repeat("0", places+1-len(abs)) + abs
+	}
+
+	return sign + abs[:len(abs)-places] + "." + abs[len(abs)-places:]
+}
+
+// Mul is the amount of quantity units at m each.
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Share is part/whole of m, rounded to the cent half away from zero.
+func (m Money) Share(part, whole int64) Money {
+	return Money(divRound(big.NewInt(int64(m)), big.NewInt(part), big.NewInt(whole)).Int64())
+}
+
+// Percent is percent of m, rounded to the cent half away from zero.
+func (m Money) Percent(percent Percent) Money {
+	return m.Share(int64(percent), int64(HundredPercent))
+}
+
+// WithoutPercent is the amount that grows to m when percent is added to
+// it, as when taking tax out of a gross price.
+func (m Money) WithoutPercent(percent Percent) Money {
+	return m.Share(int64(HundredPercent), int64(HundredPercent+percent))
+}
+
+// AtRate converts a base currency amount into a currency worth rate each,
+// rounded to the cent half away from zero.
+func (m Money) AtRate(rate Rate) Money {
+	return m.Share(rateScale, int64(rate))
+}
+
+// divRound is a*b/c rounded half away from zero.
+func divRound(a, b, c *big.Int) *big.Int {
+	num := new(big.Int).Mul(a, b)
+	den := new(big.Int).Set(c)
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return quo
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON takes the amount as a number or as a string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return unmarshalDecimal(data, 2, (*int64)(m))
+}
+
+// unmarshalDecimal reads a JSON number or string into units of
+// 10^-places, leaving dst alone for null.
+func unmarshalDecimal(data []byte, places int, dst *int64) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		text = string(data[1 : len(data)-1])
+	}
+	if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("amount %s must be written out in decimals", text)
+	}
+
+	parsed, err := parseDecimal(text, places)
+	if err != nil {
+		return err
+	}
+
+	*dst = parsed
+	return nil
+}
+
+// ScanNumeric reads a numeric column. Values with more than two decimal
+// places, such as computed ones, are rounded to the cent half away from
+// zero.
+func (m *Money) ScanNumeric(v pgtype.Numeric) error {
+	cents, err := scanDecimal(v, 2)
+	if err != nil {
+		return err
+	}
+
+	*m = Money(cents)
+	return nil
+}
+
+// scanDecimal reads a numeric into units of 10^-places, rounding extra
+// decimals half away from zero.
+func scanDecimal(v pgtype.Numeric, places int) (int64, error) {
+	if !v.Valid {
+		return 0, errors.New("cannot scan NULL into a decimal amount")
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return 0, errors.New("cannot scan a non-finite numeric into a decimal amount")
+	}
+
+	units := new(big.Int)
+	if v.Int != nil {
+		units.Set(v.Int)
+	}
+	exp := v.Exp + int32(places)
+	switch {
+	case exp > 0:
+		units.Mul(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+	case exp < 0:
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil)
+		units = divRound(units, big.NewInt(1), div)
+	}
+
+	if !units.IsInt64() {
+		return 0, fmt.Errorf("numeric %s is out of range", units)
+	}
+
+	return units.Int64(), nil
+}
+
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(m)), Exp: -2, Valid: true}, nil
+}
+
+// Cost is the same amount in ten-thousandths, for adding it to costs.
+func (m Money) Cost() Cost {
+	return Cost(m) * 100
+}
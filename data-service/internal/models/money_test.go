@@ -0,0 +1,226 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, c int64
+		want    int64
+	}{
+		{10, 1, 3, 3},
+		{20, 1, 3, 7},
+		{5, 1, 2, 3},
+		{-5, 1, 2, -3},
+		{5, 1, -2, -3},
+		{-5, 1, -2, 3},
+		{7, 1, 2, 4},
+		{-7, 1, 2, -4},
+		{149, 1, 100, 1},
+		{150, 1, 100, 2},
+		{-149, 1, 100, -1},
+		{-150, 1, 100, -2},
+		{1000, 3, 7, 429},
+		{0, 5, 3, 0},
+	}
+
+	for _, tt := range tests {
+		got := divRound(big.NewInt(tt.a), big.NewInt(tt.b), big.NewInt(tt.c)).Int64()
+		if got != tt.want {
+			t.Errorf("divRound(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		places  int
+		want    int64
+		wantErr bool
+	}{
+		{"12.34", 2, 1234, false},
+		{"12", 2, 1200, false},
+		{"12.3", 2, 1230, false},
+		{"-0.5", 2, -50, false},
+		{"+1.05", 2, 105, false},
+		{".5", 2, 50, false},
+		{"5.", 2, 500, false},
+		{" 7.25 ", 4, 72500, false},
+		{"3.3333", 4, 33333, false},
+		{"1.08250000", 8, 108250000, false},
+		{"0", 2, 0, false},
+		{"1.234", 2, 0, true},
+		{"", 2, 0, true},
+		{".", 2, 0, true},
+		{"-", 2, 0, true},
+		{"1.2.3", 2, 0, true},
+		{"1e3", 2, 0, true},
+		{"--1", 2, 0, true},
+		{"abc", 2, 0, true},
+		{"99999999999999999999", 2, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDecimal(tt.in, tt.places)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDecimal(%q, %d) = %d, want an error", tt.in, tt.places, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDecimal(%q, %d) failed: %v", tt.in, tt.places, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDecimal(%q, %d) = %d, want %d", tt.in, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestScanDecimal(t *testing.T) {
+	numeric := func(n int64, exp int32) pgtype.Numeric {
+		return pgtype.Numeric{Int: big.NewInt(n), Exp: exp, Valid: true}
+	}
+
+	tests := []struct {
+		name    string
+		in      pgtype.Numeric
+		places  int
+		want    int64
+		wantErr bool
+	}{
+		{"exact", numeric(1234, -2), 2, 1234, false},
+		{"fewer places", numeric(5, -1), 2, 50, false},
+		{"positive exponent", numeric(12, 3), 2, 1200000, false},
+		{"rounds up", numeric(12345, -3), 2, 1235, false},
+		{"rounds down", numeric(12344, -3), 2, 1234, false},
+		{"rounds negative away from zero", numeric(-12345, -3), 2, -1235, false},
+		{"cost places", numeric(333333, -5), 4, 33333, false},
+		{"rate places", numeric(108250000, -8), 8, 108250000, false},
+		{"zero", pgtype.Numeric{Valid: true}, 2, 0, false},
+		{"null", pgtype.Numeric{}, 2, 0, true},
+		{"nan", pgtype.Numeric{NaN: true, Valid: true}, 2, 0, true},
+		{"infinity", pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}, 2, 0, true},
+		{"out of range", numeric(1, 30), 2, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := scanDecimal(tt.in, tt.places)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: scanDecimal = %d, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: scanDecimal failed: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: scanDecimal = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyShare(t *testing.T) {
+	tests := []struct {
+		m           Money
+		part, whole int64
+		want        Money
+	}{
+		{1000, 1, 3, 333},
+		{1000, 2, 3, 667},
+		{1, 1, 2, 1},
+		{-1, 1, 2, -1},
+		{3, 1, 2, 2},
+		{-3, 1, 2, -2},
+		{999, 1, 1, 999},
+		{0, 1, 3, 0},
+		{1000, 0, 3, 0},
+		{1000, 3, 2, 1500},
+		{-1000, 1, -3, 333},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Share(tt.part, tt.whole); got != tt.want {
+			t.Errorf("%s.Share(%d, %d) = %s, want %s", tt.m, tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestCostPer(t *testing.T) {
+	tests := []struct {
+		c        Cost
+		quantity int
+		want     Cost
+	}{
+		{10000, 3, 3333},
+		{20000, 3, 6667},
+		{-20000, 3, -6667},
+		{10000, 1, 10000},
+	}
+
+	for _, tt := range tests {
+		if got := tt.c.Per(tt.quantity); got != tt.want {
+			t.Errorf("%s.Per(%d) = %s, want %s", tt.c, tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		m       Money
+		percent string
+		with    Money
+		without Money
+	}{
+		{10000, "20", 2000, 8333},
+		{1999, "7.25", 145, 1864},
+		{1, "50", 1, 1},
+		{-1000, "10", -100, -909},
+		{1234, "0", 0, 1234},
+	}
+
+	for _, tt := range tests {
+		percent, err := ParsePercent(tt.percent)
+		if err != nil {
+			t.Fatalf("ParsePercent(%q) failed: %v", tt.percent, err)
+		}
+		if got := tt.m.Percent(percent); got != tt.with {
+			t.Errorf("%s.Percent(%s) = %s, want %s", tt.m, percent, got, tt.with)
+		}
+		if got := tt.m.WithoutPercent(percent); got != tt.without {
+			t.Errorf("%s.WithoutPercent(%s) = %s, want %s", tt.m, percent, got, tt.without)
+		}
+	}
+}
+
+func TestMoneyAtRate(t *testing.T) {
+	tests := []struct {
+		m    Money
+		rate string
+		want Money
+	}{
+		{10000, "1", 10000},
+		{10000, "1.25", 8000},
+		{1000, "3", 333},
+		{2000, "3", 667},
+		{100, "0.00000001", 10000000000},
+	}
+
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q) failed: %v", tt.rate, err)
+		}
+		if got := tt.m.AtRate(rate); got != tt.want {
+			t.Errorf("%s.AtRate(%s) = %s, want %s", tt.m, rate, got, tt.want)
+		}
+	}
+}
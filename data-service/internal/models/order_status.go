@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// A draft order is priced but holds no stock until it is placed, which
+// moves it to created; stock can be reserved for it in the meantime.
+const (
+	OrderDraft            = "draft"
+	OrderCreated          = "created"
+	OrderPaid             = "paid"
+	OrderPartiallyShipped = "partially_shipped"
+	OrderShipped          = "shipped"
+	OrderCancelled        = "cancelled"
+)
+
+// When an order's stock leaves the warehouse: as soon as the order is
+// placed, or as each of its shipments is confirmed.
+const (
+	StockOnOrder    = "order"
+	StockOnShipment = "shipment"
+)
+
+// OrderStatusChange is one step in an order's history. FromStatus is empty
+// for the order's creation.
+type OrderStatusChange struct {
+	HistoryID  int       `json:"history_id"`
+	OrderID    int       `json:"order_id"`
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  string    `json:"changed_by,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Percent is an exact percentage in ten-thousandths of a per cent, which
+// is what the DECIMAL(7,4) tax rate columns hold.
+type Percent int64
+
+// percentScale is one per cent in Percent units.
+const percentScale = 10000
+
+// HundredPercent is the whole of an amount.
+const HundredPercent Percent = 100 * percentScale
+
+// ParsePercent reads a decimal percentage such as "20" or "7.25". More
+// than four decimal places is an error rather than being rounded away.
+func ParsePercent(s string) (Percent, error) {
+	units, err := parseDecimal(s, 4)
+	return Percent(units), err
+}
+
+func (p Percent) String() string {
+	return formatDecimal(int64(p), 4)
+}
+
+// Hundredths reports whether p has at most two decimal places, as the
+// DECIMAL(…,2) percentage columns hold.
+func (p Percent) Hundredths() bool {
+	return p%(percentScale/100) == 0
+}
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON takes the percentage as a number or as a string.
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	return unmarshalDecimal(data, 4, (*int64)(p))
+}
+
+// ScanNumeric reads a numeric column, rounding computed values with more
+// than four decimal places.
+func (p *Percent) ScanNumeric(v pgtype.Numeric) error {
+	units, err := scanDecimal(v, 4)
+	if err != nil {
+		return err
+	}
+
+	*p = Percent(units)
+	return nil
+}
+
+func (p Percent) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(p)), Exp: -4, Valid: true}, nil
+}
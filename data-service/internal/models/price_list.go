@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// PriceList gives negotiated prices to one customer, or to every customer
+// in CustomerGroup, from ValidFrom up to the day before ValidTo; a nil
+// ValidTo means until further notice.
+type PriceList struct {
+	PriceListID   int              `json:"price_list_id"`
+	Name          string           `json:"name"`
+	CustomerID    *int             `json:"customer_id,omitempty"`
+	CustomerGroup string           `json:"customer_group,omitempty"`
+	ValidFrom     time.Time        `json:"valid_from"`
+	ValidTo       *time.Time       `json:"valid_to,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Prices        []PriceListPrice `json:"prices"`
+}
+
+// PriceListPrice is a product's unit price, in the base currency, for
+// orders of at least MinQuantity base units. A product can have one price
+// per quantity break.
+type PriceListPrice struct {
+	ProductID   int   `json:"product_id"`
+	MinQuantity int   `json:"min_quantity"`
+	Price       Money `json:"price"`
+}
+
+// PriceQuote is the unit price a customer would be charged today for a
+// quantity of a product, in Currency. PriceListID is the list the price
+// came from, or nil for the catalogue price.
+type PriceQuote struct {
+	CustomerID  int    `json:"customer_id"`
+	ProductID   int    `json:"product_id"`
+	Quantity    int    `json:"quantity"`
+	Currency    string `json:"currency"`
+	ListPrice   Money  `json:"list_price"`
+	Price       Money  `json:"price"`
+	PriceListID *int   `json:"price_list_id,omitempty"`
+	Amount      Money  `json:"amount"`
+}
@@ -4,13 +4,31 @@ import "time"
 
 type Product struct {
 	ProductID   int       `json:"product_id"`
-	Price       float64   `json:"price"`
+	SKU         string    `json:"sku"`
+	Price       Money     `json:"price"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Quantity    int       `json:"quantity"`
+	BaseUnit    string    `json:"base_unit"`
 	Category    string    `json:"category"`
+	Serialized  bool      `json:"serialized"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	Stock    []WarehouseStock `json:"stock,omitempty"`
+	Barcodes []Barcode        `json:"barcodes,omitempty"`
+	Units    []ProductUnit    `json:"units,omitempty"`
+
+	// A variant (one size/color of a parent product) has ParentID and
+	// Attributes set. A parent holds no stock itself; its Quantity and
+	// Stock are the sum over its Variants.
+	ParentID   *int              `json:"parent_id,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Variants   []Product         `json:"variants,omitempty"`
+
+	// ReorderPoint is nil when the product is not watched for low stock.
+	ReorderPoint    *int `json:"reorder_point,omitempty"`
+	ReorderQuantity int  `json:"reorder_quantity,omitempty"`
 }
 
 type Customer struct {
@@ -20,29 +38,103 @@ type Customer struct {
 	Address      string    `json:"address"`
 	Email        string    `json:"email" validate:"required,email"`
 	RegisteredAt time.Time `json:"registered_at"`
+
+	// Group is the customer group whose price lists the customer gets.
+	Group string `json:"group,omitempty"`
 }
 
 type Order struct {
 	OrderID     int       `json:"order_id"`
-	TotalAmount float64   `json:"total_amount"`
+	TotalAmount Money     `json:"total_amount"`
 	Status      string    `json:"status"`
 	CustomerID  int       `json:"customer_id"`
+	WarehouseID int       `json:"warehouse_id"`
+	StockTiming string    `json:"stock_timing"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Carrier and ShipBy drive wave planning; NeedsAttention is set when a
+	// pick for the order came up short.
+	Carrier        string     `json:"carrier,omitempty"`
+	ShipBy         *time.Time `json:"ship_by,omitempty"`
+	NeedsAttention bool       `json:"needs_attention"`
+
+	// DiscountAmount is taken off the lines before tax; Discounts break it
+	// down by promotion.
+	DiscountAmount Money           `json:"discount_amount"`
+	PromoCode      string          `json:"promo_code,omitempty"`
+	Discounts      []OrderDiscount `json:"discounts,omitempty"`
+
+	// The order is taxed in TaxJurisdiction, or not at all when it is
+	// empty. TotalAmount is the gross amount: NetAmount plus TaxAmount.
+	// PricesIncludeTax tells whether the line prices were gross or net.
+	TaxJurisdiction  string `json:"tax_jurisdiction,omitempty"`
+	PricesIncludeTax bool   `json:"prices_include_tax"`
+	NetAmount        Money  `json:"net_amount"`
+	TaxAmount        Money  `json:"tax_amount"`
+
+	// Amounts are in Currency. ExchangeRate is what one unit of it was
+	// worth in the base currency when the order was placed; it is 1 for
+	// orders in the base currency.
+	Currency     string `json:"currency"`
+	ExchangeRate Rate   `json:"exchange_rate"`
 }
 
 type OrderItem struct {
-	OrderItemID int     `json:"order_item_id"`
-	OrderID     int     `json:"order_id"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
-	ProductID   int     `json:"product_id"`
+	OrderItemID     int `json:"order_item_id"`
+	OrderID         int `json:"order_id"`
+	Quantity        int `json:"quantity"`
+	QuantityShipped int `json:"quantity_shipped"`
+	ProductID       int `json:"product_id"`
+
+	// Price is charged per base unit. It is resolved when the order is
+	// placed from the price list the customer gets, PriceListID, or else
+	// from the catalogue, and ListPrice keeps the catalogue price of that
+	// moment; any price given by the caller is ignored unless it comes as
+	// an Override.
+	Price       Money          `json:"price"`
+	ListPrice   Money          `json:"list_price"`
+	PriceListID *int           `json:"price_list_id,omitempty"`
+	Override    *PriceOverride `json:"price_override,omitempty"`
+
+	// Line amounts after discounts; TaxRate is the percentage applied.
+	NetAmount   Money   `json:"net_amount"`
+	TaxRate     Percent `json:"tax_rate"`
+	TaxAmount   Money   `json:"tax_amount"`
+	GrossAmount Money   `json:"gross_amount"`
+
+	// Unit and UnitQuantity record what was ordered when it was not the
+	// base unit; Quantity is always in the base unit.
+	Unit         string `json:"unit,omitempty"`
+	UnitQuantity int    `json:"unit_quantity,omitempty"`
+
+	Lots []OrderItemLot `json:"lots,omitempty"`
+}
+
+// PriceOverride charges an order line another price than the catalogue's.
+// It is recorded with a reason and the ID of the manager who authorized
+// it.
+type PriceOverride struct {
+	Price        Money  `json:"price"`
+	Reason       string `json:"reason"`
+	AuthorizedBy int    `json:"authorized_by"`
 }
 
 type Operation struct {
-	OperationID   int       `json:"operation_id"`
-	ProductID     int       `json:"product_id"`
-	OrderID       *int      `json:"order_id,omitempty"`
-	OperationType string    `json:"operation_type"`
-	ChangeQuant   int       `json:"change_quant"`
-	CreatedAt     time.Time `json:"created_at"`
+	OperationID    int       `json:"operation_id"`
+	ProductID      int       `json:"product_id"`
+	WarehouseID    int       `json:"warehouse_id"`
+	OrderID        *int      `json:"order_id,omitempty"`
+	OperationType  string    `json:"operation_type"`
+	ChangeQuant    int       `json:"change_quant"`
+	ReservationID  *int      `json:"reservation_id,omitempty"`
+	FromLocationID *int      `json:"from_location_id,omitempty"`
+	ToLocationID   *int      `json:"to_location_id,omitempty"`
+	LotID          *int      `json:"lot_id,omitempty"`
+	POLineID       *int      `json:"po_line_id,omitempty"`
+	TransferID     *int      `json:"transfer_id,omitempty"`
+	UnitCost       *Cost     `json:"unit_cost,omitempty"`
+	CostAmount     *Cost     `json:"cost_amount,omitempty"`
+	Unit           string    `json:"unit,omitempty"`
+	UnitQuant      int       `json:"unit_quant,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
 }
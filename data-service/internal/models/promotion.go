@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	PromotionPercent  = "percent"
+	PromotionFixed    = "fixed"
+	PromotionBuyXGetY = "buy_x_get_y"
+)
+
+// Promotion is a discount orders get automatically or, when it has a Code,
+// by quoting that code. Percent promotions take Value per cent off and
+// fixed ones take Amount off; buy-X-get-Y promotions give GetQuantity units
+// free for every BuyQuantity paid. ProductID or Category narrow a
+// promotion to some lines; without them it applies to the whole order.
+// Amounts are in the base currency and converted for orders in others.
+type Promotion struct {
+	PromotionID        int        `json:"promotion_id"`
+	Code               string     `json:"code,omitempty"`
+	Name               string     `json:"name"`
+	Kind               string     `json:"kind"`
+	Value              Percent    `json:"value"`
+	Amount             *Money     `json:"amount,omitempty"`
+	BuyQuantity        *int       `json:"buy_quantity,omitempty"`
+	GetQuantity        *int       `json:"get_quantity,omitempty"`
+	ProductID          *int       `json:"product_id,omitempty"`
+	Category           string     `json:"category,omitempty"`
+	MinOrderValue      *Money     `json:"min_order_value,omitempty"`
+	MaxUses            *int       `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer *int       `json:"max_uses_per_customer,omitempty"`
+	StartsAt           time.Time  `json:"starts_at"`
+	EndsAt             *time.Time `json:"ends_at,omitempty"`
+	Active             bool       `json:"active"`
+	CreatedAt          time.Time  `json:"created_at"`
+
+	// Uses counts the orders that got the promotion and were not
+	// cancelled.
+	Uses int `json:"uses"`
+}
+
+// OrderDiscount is what one promotion took off an order line, or off the
+// whole order when OrderItemID is nil.
+type OrderDiscount struct {
+	DiscountID  int    `json:"order_discount_id"`
+	OrderID     int    `json:"order_id"`
+	PromotionID int    `json:"promotion_id"`
+	OrderItemID *int   `json:"order_item_id,omitempty"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+}
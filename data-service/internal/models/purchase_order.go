@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderClosed            = "closed"
+)
+
+type Supplier struct {
+	SupplierID int       `json:"supplier_id"`
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email,omitempty"`
+	Phone      string    `json:"phone,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PurchaseOrder is stock ordered from a supplier for delivery to one
+// warehouse. Its status follows the lines as goods are received.
+type PurchaseOrder struct {
+	POID        int       `json:"po_id"`
+	SupplierID  int       `json:"supplier_id"`
+	WarehouseID int       `json:"warehouse_id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Lines []PurchaseOrderLine `json:"lines"`
+}
+
+// PurchaseOrderLine quantities are in the product's base unit.
+// QuantityReceived may exceed QuantityOrdered when a supplier over-delivers.
+type PurchaseOrderLine struct {
+	LineID           int   `json:"po_line_id"`
+	POID             int   `json:"po_id"`
+	ProductID        int   `json:"product_id"`
+	QuantityOrdered  int   `json:"quantity_ordered"`
+	QuantityReceived int   `json:"quantity_received"`
+	UnitCost         Money `json:"unit_cost"`
+}
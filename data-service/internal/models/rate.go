@@ -0,0 +1,53 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Rate is an exact exchange rate in hundred-millionths, which is what the
+// DECIMAL(18,8) rate columns hold.
+type Rate int64
+
+// rateScale is one in Rate units.
+const rateScale = 100000000
+
+// OneRate is the rate of the base currency against itself.
+const OneRate Rate = rateScale
+
+// ParseRate reads a decimal rate such as "1.0825". More than eight decimal
+// places is an error rather than being rounded away.
+func ParseRate(s string) (Rate, error) {
+	units, err := parseDecimal(s, 8)
+	return Rate(units), err
+}
+
+func (r Rate) String() string {
+	return formatDecimal(int64(r), 8)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON takes the rate as a number or as a string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	return unmarshalDecimal(data, 8, (*int64)(r))
+}
+
+// ScanNumeric reads a numeric column, rounding computed values with more
+// than eight decimal places.
+func (r *Rate) ScanNumeric(v pgtype.Numeric) error {
+	units, err := scanDecimal(v, 8)
+	if err != nil {
+		return err
+	}
+
+	*r = Rate(units)
+	return nil
+}
+
+func (r Rate) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(r)), Exp: -8, Valid: true}, nil
+}
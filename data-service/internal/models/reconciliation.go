@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ReconciliationReport lists every product whose stock disagrees with the
+// sum of its stock operations. With Corrected set, an adjustment operation
+// was posted for each warehouse drift to bring the ledger in line.
+type ReconciliationReport struct {
+	CheckedAt time.Time    `json:"checked_at"`
+	Corrected bool         `json:"corrected"`
+	Drifts    []StockDrift `json:"drifts"`
+}
+
+// StockDrift compares products.quantity with the ledger. Difference is
+// Quantity minus LedgerQuantity. Warehouses lists the sites that drifted;
+// a product can have none when only its stored total is off.
+type StockDrift struct {
+	ProductID      int              `json:"product_id"`
+	Quantity       int              `json:"quantity"`
+	LedgerQuantity int              `json:"ledger_quantity"`
+	Difference     int              `json:"difference"`
+	Warehouses     []WarehouseDrift `json:"warehouses,omitempty"`
+}
+
+type WarehouseDrift struct {
+	WarehouseID    int  `json:"warehouse_id"`
+	Quantity       int  `json:"quantity"`
+	LedgerQuantity int  `json:"ledger_quantity"`
+	Difference     int  `json:"difference"`
+	OperationID    *int `json:"operation_id,omitempty"`
+}
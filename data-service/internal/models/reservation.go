@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	ReservationActive    = "active"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+	ReservationConverted = "converted"
+)
+
+// Reservation holds stock for a customer. OrderID is the draft order it is
+// held for, or the order that converted it.
+type Reservation struct {
+	ReservationID int        `json:"reservation_id"`
+	ProductID     int        `json:"product_id"`
+	WarehouseID   int        `json:"warehouse_id"`
+	CustomerID    int        `json:"customer_id"`
+	OrderID       *int       `json:"order_id,omitempty"`
+	Quantity      int        `json:"quantity"`
+	Status        string     `json:"status"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+}
+
+// StockLevel splits product stock into what is physically on hand,
+// what is held by active reservations, what is committed to open orders
+// that take their stock on shipment, what sits in expired lots and what
+// can still be sold. Stock in transit between warehouses is not on hand.
+type StockLevel struct {
+	ProductID int `json:"product_id"`
+	OnHand    int `json:"on_hand"`
+	Reserved  int `json:"reserved"`
+	Committed int `json:"committed"`
+	Expired   int `json:"expired"`
+	Available int `json:"available"`
+	InTransit int `json:"in_transit"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+const (
+	ReturnAuthorized = "authorized"
+	ReturnReceived   = "received"
+	ReturnCancelled  = "cancelled"
+)
+
+// Inspection outcomes for returned units.
+const (
+	ReturnRestock = "restock"
+	ReturnDamaged = "damaged"
+	ReturnScrap   = "scrap"
+)
+
+// Return authorizes a customer to send back goods shipped on an order.
+// WarehouseID is where the goods come back to.
+type Return struct {
+	ReturnID     int        `json:"return_id"`
+	OrderID      int        `json:"order_id"`
+	WarehouseID  int        `json:"warehouse_id"`
+	Status       string     `json:"status"`
+	Reason       string     `json:"reason,omitempty"`
+	RefundAmount Money      `json:"refund_amount"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReceivedAt   *time.Time `json:"received_at,omitempty"`
+
+	Lines []ReturnLine `json:"lines,omitempty"`
+}
+
+// ReturnLine quantities are in units of the order line, so a bundle line
+// is returned as whole bundles.
+type ReturnLine struct {
+	LineID           int   `json:"return_line_id"`
+	ReturnID         int   `json:"return_id"`
+	OrderItemID      int   `json:"order_item_id"`
+	ProductID        int   `json:"product_id"`
+	Quantity         int   `json:"quantity"`
+	QuantityReceived *int  `json:"quantity_received,omitempty"`
+	RefundAmount     Money `json:"refund_amount"`
+
+	Inspections []ReturnInspection `json:"inspections,omitempty"`
+}
+
+// ReturnInspection is the outcome for some of a line's units. Serialized
+// products list one serial number per unit.
+type ReturnInspection struct {
+	InspectionID int       `json:"inspection_id"`
+	ReturnLineID int       `json:"return_line_id"`
+	Outcome      string    `json:"outcome"`
+	Quantity     int       `json:"quantity"`
+	ToLocationID *int      `json:"to_location_id,omitempty"`
+	Serials      []string  `json:"serials,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
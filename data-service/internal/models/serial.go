@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// A serial assigned to an order that takes its stock on shipment is
+// allocated until its line ships, and sold from then on. A serial on a
+// dispatched transfer is in transit until it arrives, or lost if it never
+// does.
+const (
+	SerialInStock   = "in_stock"
+	SerialAllocated = "allocated"
+	SerialInTransit = "in_transit"
+	SerialSold      = "sold"
+	SerialScrapped  = "scrapped"
+	SerialLost      = "lost"
+)
+
+type SerialNumber struct {
+	SerialID     int       `json:"serial_id"`
+	ProductID    int       `json:"product_id"`
+	SerialNumber string    `json:"serial_number"`
+	WarehouseID  int       `json:"warehouse_id"`
+	LotID        *int      `json:"lot_id,omitempty"`
+	Status       string    `json:"status"`
+	OrderItemID  *int      `json:"order_item_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SerialHistory is every ledger operation that moved one unit, oldest first.
+type SerialHistory struct {
+	Serial     SerialNumber `json:"serial"`
+	Operations []Operation  `json:"operations"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	ShipmentDraft     = "draft"
+	ShipmentConfirmed = "confirmed"
+	ShipmentCancelled = "cancelled"
+)
+
+// Shipment is one parcel of an order. Confirming it marks its lines as
+// shipped and, for orders that take their stock on shipment, takes the
+// goods out of the warehouse.
+type Shipment struct {
+	ShipmentID     int        `json:"shipment_id"`
+	OrderID        int        `json:"order_id"`
+	Status         string     `json:"status"`
+	Carrier        string     `json:"carrier,omitempty"`
+	TrackingNumber string     `json:"tracking_number,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConfirmedAt    *time.Time `json:"confirmed_at,omitempty"`
+
+	Lines []ShipmentLine `json:"lines,omitempty"`
+}
+
+// ShipmentLine quantities are in units of the order line.
+type ShipmentLine struct {
+	LineID      int `json:"shipment_line_id"`
+	ShipmentID  int `json:"shipment_id"`
+	OrderItemID int `json:"order_item_id"`
+	ProductID   int `json:"product_id"`
+	Quantity    int `json:"quantity"`
+}
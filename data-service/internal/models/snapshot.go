@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// StockSnapshot records ledger totals at TakenAt so that point-in-time
+// queries do not have to replay the whole operation history.
+type StockSnapshot struct {
+	SnapshotID int       `json:"snapshot_id"`
+	TakenAt    time.Time `json:"taken_at"`
+	Lines      int       `json:"lines"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// StockAsOf is a product's stock at a past moment, rebuilt from the
+// operation ledger.
+type StockAsOf struct {
+	ProductID  int                 `json:"product_id"`
+	At         time.Time           `json:"at"`
+	Quantity   int                 `json:"quantity"`
+	Warehouses []WarehouseQuantity `json:"warehouses,omitempty"`
+}
+
+type WarehouseQuantity struct {
+	WarehouseID int `json:"warehouse_id"`
+	Quantity    int `json:"quantity"`
+}
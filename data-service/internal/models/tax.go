@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DefaultTaxClass is the tax class products start out in.
+const DefaultTaxClass = "standard"
+
+// TaxRate is the percentage a jurisdiction taxes one tax class at, from
+// EffectiveFrom up to the day before EffectiveTo; a nil EffectiveTo means
+// until further notice.
+type TaxRate struct {
+	TaxRateID     int        `json:"tax_rate_id"`
+	Jurisdiction  string     `json:"jurisdiction"`
+	TaxClass      string     `json:"tax_class"`
+	Rate          Percent    `json:"rate"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	TransferDraft     = "draft"
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// Transfer moves stock between two warehouses in two steps. Dispatching
+// takes the goods out of the source; until they are received at the
+// destination they are in transit and count towards neither warehouse.
+type Transfer struct {
+	TransferID      int        `json:"transfer_id"`
+	FromWarehouseID int        `json:"from_warehouse_id"`
+	ToWarehouseID   int        `json:"to_warehouse_id"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DispatchedAt    *time.Time `json:"dispatched_at,omitempty"`
+	ReceivedAt      *time.Time `json:"received_at,omitempty"`
+
+	Lines []TransferLine `json:"lines,omitempty"`
+}
+
+// TransferLine quantities are in the product's base unit. Discrepancy is
+// QuantityReceived minus Quantity: zero, or negative for goods lost on the
+// way. Lines of serialized products name every unit they send in Serials.
+type TransferLine struct {
+	LineID           int      `json:"transfer_line_id"`
+	TransferID       int      `json:"transfer_id"`
+	ProductID        int      `json:"product_id"`
+	Quantity         int      `json:"quantity"`
+	Serials          []string `json:"serials,omitempty"`
+	QuantityReceived *int     `json:"quantity_received,omitempty"`
+	Discrepancy      *int     `json:"discrepancy,omitempty"`
+}
+
+// TransferReceipt is what arrived of one product, optionally put away into
+// a bin at the destination. For a serialized product that arrived short,
+// Serials names the units that did arrive.
+type TransferReceipt struct {
+	ProductID    int      `json:"product_id"`
+	Quantity     int      `json:"quantity"`
+	Serials      []string `json:"serials,omitempty"`
+	ToLocationID *int     `json:"to_location_id,omitempty"`
+}
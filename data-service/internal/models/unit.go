@@ -0,0 +1,11 @@
+package models
+
+// DefaultBaseUnit is used for products created without a base unit.
+const DefaultBaseUnit = "pcs"
+
+// ProductUnit is an alternate unit a product is handled in, e.g. a box of
+// 24 pieces has Factor 24. Stock is always kept in the base unit.
+type ProductUnit struct {
+	Unit   string `json:"unit"`
+	Factor int    `json:"factor"`
+}
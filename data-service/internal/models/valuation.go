@@ -0,0 +1,37 @@
+package models
+
+const (
+	CostFIFO    = "fifo"
+	CostAverage = "average"
+)
+
+// ProductValuation is the value of a product's stock on hand under its
+// cost method, rounded to the cent. UnitCost is the exact value per unit,
+// or the last average cost when nothing is in stock.
+type ProductValuation struct {
+	ProductID  int    `json:"product_id"`
+	Category   string `json:"category"`
+	CostMethod string `json:"cost_method"`
+	Quantity   int    `json:"quantity"`
+	UnitCost   Cost   `json:"unit_cost"`
+	Value      Money  `json:"value"`
+}
+
+type CategoryValuation struct {
+	Category string `json:"category"`
+	Quantity int    `json:"quantity"`
+	Value    Money  `json:"value"`
+}
+
+// OrderCOGS is the cost of the goods an order shipped, per product.
+type OrderCOGS struct {
+	OrderID  int           `json:"order_id"`
+	Products []ProductCOGS `json:"products"`
+	Total    Money         `json:"total"`
+}
+
+type ProductCOGS struct {
+	ProductID int   `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+	Cost      Money `json:"cost"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// DefaultWarehouseID is the site that pre-warehouse stock was migrated into.
+const DefaultWarehouseID = 1
+
+type Warehouse struct {
+	WarehouseID int       `json:"warehouse_id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Address     string    `json:"address"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WarehouseStock struct {
+	WarehouseID int       `json:"warehouse_id"`
+	ProductID   int       `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+const (
+	WaveOpen      = "open"
+	WaveCompleted = "completed"
+	WaveCancelled = "cancelled"
+)
+
+// WaveRule selects the open orders of a warehouse to plan into waves. Orders
+// are grouped into one wave per carrier and zone; an order whose picks span
+// several zones goes into a wave without one. Carrier and ZoneID narrow the
+// selection to one group, Cutoff to orders due to ship by then, and
+// MaxOrders splits large groups.
+type WaveRule struct {
+	WarehouseID int        `json:"warehouse_id"`
+	Carrier     string     `json:"carrier,omitempty"`
+	ZoneID      *int       `json:"zone_id,omitempty"`
+	Cutoff      *time.Time `json:"cutoff,omitempty"`
+	MaxOrders   int        `json:"max_orders,omitempty"`
+}
+
+type Wave struct {
+	WaveID      int        `json:"wave_id"`
+	WarehouseID int        `json:"warehouse_id"`
+	Carrier     string     `json:"carrier,omitempty"`
+	ZoneID      *int       `json:"zone_id,omitempty"`
+	Cutoff      *time.Time `json:"cutoff,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	OrderIDs []int      `json:"order_ids,omitempty"`
+	Lines    []PickLine `json:"lines,omitempty"`
+}
+
+// PickLine is everything a wave takes of one product from one bin. A nil
+// LocationID is stock that has no bin yet.
+type PickLine struct {
+	LineID         int        `json:"pick_line_id"`
+	WaveID         int        `json:"wave_id"`
+	ProductID      int        `json:"product_id"`
+	LocationID     *int       `json:"location_id,omitempty"`
+	LocationCode   string     `json:"location_code,omitempty"`
+	Quantity       int        `json:"quantity"`
+	QuantityPicked *int       `json:"quantity_picked,omitempty"`
+	PickedBy       string     `json:"picked_by,omitempty"`
+	PickedAt       *time.Time `json:"picked_at,omitempty"`
+
+	Allocations []PickAllocation `json:"allocations,omitempty"`
+}
+
+// PickAllocation is the part of a pick line meant for one order line, in
+// units of the picked product.
+type PickAllocation struct {
+	OrderItemID    int  `json:"order_item_id"`
+	OrderID        int  `json:"order_id"`
+	Quantity       int  `json:"quantity"`
+	QuantityPicked *int `json:"quantity_picked,omitempty"`
+}
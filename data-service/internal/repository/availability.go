@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// stockFigures are the stock of a product, in one warehouse or in all of
+// them, and the parts of it that cannot be taken.
+type stockFigures struct {
+	onHand    int
+	reserved  int
+	committed int
+	expired   int
+}
+
+// available is the stock an order could still take: what is on hand, less
+// active reservations, stock committed to open orders that have not
+// shipped yet and stock in expired lots.
+func (f stockFigures) available() int {
+	return f.onHand - f.reserved - f.committed - f.expired
+}
+
+// lockProducts locks product rows in ID order. It runs as a statement of
+// its own: a statement that waited for the locks still reads from the
+// snapshot taken before it waited, so stock must be counted in a later
+// statement to see what the previous holder of the locks committed.
+func lockProducts(ctx context.Context, tx pgx.Tx, productIDs []int) error {
+	_, err := tx.Exec(ctx, `SELECT 1 FROM products WHERE product_id = ANY($1::int[]) ORDER BY product_id FOR UPDATE`, productIDs)
+	if err != nil {
+		return fmt.Errorf("failed to lock products: %w", err)
+	}
+
+	return nil
+}
+
+// availableStock counts the stock figures of products in a warehouse, or
+// in all warehouses if warehouseID is 0. Reservations of exceptCustomer
+// that are held for no order or for exceptOrder are left out, as that
+// customer's order may use them; 0 leaves none out. Products that do not
+// exist are missing from the result. To check stock before taking it, lock
+// the products with lockProducts first.
+func availableStock(ctx context.Context, q querier, warehouseID int, productIDs []int, exceptCustomer, exceptOrder int) (map[int]stockFigures, error) {
+	sql := `SELECT
+		p.product_id,
+		COALESCE((
+			SELECT SUM(ws.quantity) FROM warehouse_stock ws
+			WHERE ws.product_id = p.product_id
+			AND ($2::int = 0 OR ws.warehouse_id = $2)
+		), 0),
+		COALESCE((
+			SELECT SUM(r.quantity) FROM reservations r
+			WHERE r.product_id = p.product_id
+			AND ($2::int = 0 OR r.warehouse_id = $2)
+			AND r.status = 'active'
+			AND r.expires_at > NOW()
+			AND NOT (r.customer_id = $3 AND (r.order_id IS NULL OR r.order_id = $4))
+		), 0),
+		COALESCE((
+			SELECT SUM(c.quantity) FROM committed_stock c
+			WHERE c.product_id = p.product_id
+			AND ($2::int = 0 OR c.warehouse_id = $2)
+		), 0),
+		COALESCE((
+			SELECT SUM(l.quantity) FROM lots l
+			WHERE l.product_id = p.product_id
+			AND ($2::int = 0 OR l.warehouse_id = $2)
+			AND l.expires_at <= CURRENT_DATE
+		), 0)
+		FROM products p
+		WHERE p.product_id = ANY($1::int[])
+	`
+
+	rows, err := q.Query(ctx, sql, productIDs, warehouseID, exceptCustomer, exceptOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product stock: %w", err)
+	}
+
+	defer rows.Close()
+
+	stock := make(map[int]stockFigures, len(productIDs))
+
+	for rows.Next() {
+		var productID int
+		var f stockFigures
+		if err := rows.Scan(&productID, &f.onHand, &f.reserved, &f.committed, &f.expired); err != nil {
+			return nil, fmt.Errorf("failed to scan product stock: %w", err)
+		}
+		stock[productID] = f
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return stock, nil
+}
+
+// checkFreeStock checks a warehouse can give up quantity of a locked
+// product without touching stock held by reservations or committed to open
+// orders. Stock in expired lots counts only if withExpired is set, as for
+// write-offs.
+func checkFreeStock(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int, withExpired bool) error {
+	stock, err := availableStock(ctx, tx, warehouseID, []int{productID}, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	figures := stock[productID]
+	available := figures.available()
+	if withExpired {
+		available += figures.expired
+	}
+	if available < quantity {
+		return fmt.Errorf("%w: available %d, requested %d", ErrNotEnough, max(available, 0), quantity)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"data-service/internal/models"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var skuRe = regexp.MustCompile(`^[A-Z0-9][A-Z0-9._-]{0,63}$`)
+
+func normalizeSKU(sku string) (string, error) {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if !skuRe.MatchString(sku) {
+		return "", fmt.Errorf("%w: invalid SKU '%s'", ErrInvalidInput, sku)
+	}
+	return sku, nil
+}
+
+// validateBarcode checks a barcode against its symbology. EAN-13 and UPC-A
+// carry a GS1 mod-10 check digit; Code128 carries its checksum in the
+// printed symbol only, so just the character set and length are checked.
+func validateBarcode(b *models.Barcode) error {
+	b.Code = strings.TrimSpace(b.Code)
+
+	switch b.Symbology {
+	case models.SymbologyEAN13:
+		if len(b.Code) != 13 || !gs1CheckDigitValid(b.Code) {
+			return fmt.Errorf("%w: invalid EAN-13 barcode '%s'", ErrInvalidInput, b.Code)
+		}
+	case models.SymbologyUPCA:
+		if len(b.Code) != 12 || !gs1CheckDigitValid(b.Code) {
+			return fmt.Errorf("%w: invalid UPC-A barcode '%s'", ErrInvalidInput, b.Code)
+		}
+	case models.SymbologyCode128:
+		if b.Code == "" || len(b.Code) > 48 {
+			return fmt.Errorf("%w: Code128 barcode must be 1-48 characters", ErrInvalidInput)
+		}
+		for _, c := range b.Code {
+			if c < 32 || c > 126 {
+				return fmt.Errorf("%w: Code128 barcode contains unsupported character %q", ErrInvalidInput, c)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown barcode symbology '%s'", ErrInvalidInput, b.Symbology)
+	}
+
+	return nil
+}
+
+// gs1CheckDigitValid verifies the trailing mod-10 check digit used by EAN
+// and UPC codes: digits are weighted 3 and 1 alternately from the right.
+func gs1CheckDigitValid(code string) bool {
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	last := code[len(code)-1]
+	if last < '0' || last > '9' {
+		return false
+	}
+
+	return (10-sum%10)%10 == int(last-'0')
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type bundleRepo struct {
+	db *pgx.Conn
+}
+
+func NewBundleRepository(db *pgx.Conn) BundleRepository {
+	return &bundleRepo{db: db}
+}
+
+// SetComponents replaces a bundle's definition. An empty component list
+// turns the product back into an ordinary one. Bundles hold no stock of
+// their own and cannot be nested, varied, serialized or given a reorder
+// point.
+func (r *bundleRepo) SetComponents(ctx context.Context, b *models.Bundle) error {
+	if b == nil {
+		return fmt.Errorf("%w: bundle cannot be nil", ErrInvalidInput)
+	}
+	if b.ProductID <= 0 {
+		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+
+	seen := make(map[int]bool, len(b.Components))
+	for _, c := range b.Components {
+		if c.ProductID <= 0 {
+			return fmt.Errorf("%w: component product ID must be positive", ErrInvalidInput)
+		}
+		if c.ProductID == b.ProductID {
+			return fmt.Errorf("%w: a bundle cannot contain itself", ErrInvalidInput)
+		}
+		if c.Quantity <= 0 {
+			return fmt.Errorf("%w: component quantity must be positive", ErrInvalidInput)
+		}
+		if seen[c.ProductID] {
+			return fmt.Errorf("%w: component %d listed twice", ErrInvalidInput, c.ProductID)
+		}
+		seen[c.ProductID] = true
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT
+		p.quantity,
+		p.parent_id IS NOT NULL OR EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id),
+		p.is_serialized,
+		EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.component_id = p.product_id),
+		p.reorder_point IS NOT NULL
+		FROM products p WHERE p.product_id = $1
+		FOR UPDATE OF p
+	`
+
+	var quantity int
+	var varied, serialized, isComponent, reordered bool
+
+	err = tx.QueryRow(ctx, sql, b.ProductID).Scan(&quantity, &varied, &serialized, &isComponent, &reordered)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock product %d: %w", b.ProductID, err)
+	}
+
+	switch {
+	case quantity != 0:
+		return fmt.Errorf("%w: product %d holds stock of its own", ErrInvalidInput, b.ProductID)
+	case varied:
+		return fmt.Errorf("%w: product %d has variants or is one", ErrInvalidInput, b.ProductID)
+	case serialized:
+		return fmt.Errorf("%w: product %d is serialized", ErrInvalidInput, b.ProductID)
+	case isComponent && len(b.Components) > 0:
+		return fmt.Errorf("%w: product %d is a component of another bundle", ErrInvalidInput, b.ProductID)
+	case reordered && len(b.Components) > 0:
+		return fmt.Errorf("%w: product %d has a reorder point", ErrInvalidInput, b.ProductID)
+	}
+
+	for _, c := range b.Components {
+		if err := checkComponent(ctx, tx, c.ProductID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM bundle_components WHERE bundle_id = $1`, b.ProductID); err != nil {
+		return fmt.Errorf("failed to clear components of bundle %d: %w", b.ProductID, err)
+	}
+
+	for _, c := range b.Components {
+		_, err := tx.Exec(ctx, `INSERT INTO bundle_components (bundle_id, component_id, quantity) VALUES ($1, $2, $3)`,
+			b.ProductID, c.ProductID, c.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to add component %d to bundle %d: %w", c.ProductID, b.ProductID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkComponent rejects products that cannot be shipped as part of a kit.
+// Serialized units would need serial numbers per kit line and parents have
+// no stock of their own.
+func checkComponent(ctx context.Context, tx pgx.Tx, productID int) error {
+	sql := `SELECT
+		p.is_serialized,
+		EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id),
+		EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.product_id)
+		FROM products p WHERE p.product_id = $1
+	`
+
+	var serialized, hasVariants, isBundle bool
+
+	err := tx.QueryRow(ctx, sql, productID).Scan(&serialized, &hasVariants, &isBundle)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: component %d not found", ErrInvalidInput, productID)
+		}
+		return fmt.Errorf("failed to get component %d: %w", productID, err)
+	}
+
+	switch {
+	case serialized:
+		return fmt.Errorf("%w: serialized product %d cannot be bundled", ErrInvalidInput, productID)
+	case hasVariants:
+		return fmt.Errorf("%w: product %d has variants, bundle one of them instead", ErrInvalidInput, productID)
+	case isBundle:
+		return fmt.Errorf("%w: product %d is a bundle itself", ErrInvalidInput, productID)
+	}
+
+	return nil
+}
+
+// GetByID returns a bundle's components and how many kits they make up in
+// the warehouse, counting only stock that is not reserved, committed to
+// open orders or expired.
+func (r *bundleRepo) GetByID(ctx context.Context, productID, warehouseID int) (*models.Bundle, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if warehouseID <= 0 {
+		return nil, fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		bc.component_id,
+		bc.quantity,
+		COALESCE(ws.quantity, 0)
+		- COALESCE((
+			SELECT SUM(r.quantity) FROM reservations r
+			WHERE r.product_id = bc.component_id
+			AND r.warehouse_id = $2
+			AND r.status = 'active'
+			AND r.expires_at > NOW()
+		), 0)
+		- COALESCE((
+			SELECT SUM(c.quantity) FROM committed_stock c
+			WHERE c.product_id = bc.component_id
+			AND c.warehouse_id = $2
+		), 0)
+		- COALESCE((
+			SELECT SUM(l.quantity) FROM lots l
+			WHERE l.product_id = bc.component_id
+			AND l.warehouse_id = $2
+			AND l.expires_at <= CURRENT_DATE
+		), 0)
+		FROM bundle_components bc
+		LEFT JOIN warehouse_stock ws ON ws.product_id = bc.component_id AND ws.warehouse_id = $2
+		WHERE bc.bundle_id = $1
+		ORDER BY bc.component_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, productID, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get components of bundle %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	bundle := models.Bundle{ProductID: productID, WarehouseID: warehouseID}
+
+	for rows.Next() {
+		var c models.BundleComponent
+		if err := rows.Scan(&c.ProductID, &c.Quantity, &c.Available); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle components: %w", err)
+		}
+		c.Available = max(c.Available, 0)
+
+		kits := c.Available / c.Quantity
+		if len(bundle.Components) == 0 || kits < bundle.Available {
+			bundle.Available = kits
+		}
+		bundle.Components = append(bundle.Components, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if len(bundle.Components) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &bundle, nil
+}
+
+// bundleComponents returns the definitions of those products that are
+// bundles, keyed by bundle ID.
+func bundleComponents(ctx context.Context, tx pgx.Tx, productIDs []int) (map[int][]models.BundleComponent, error) {
+	sql := `SELECT bundle_id, component_id, quantity
+		FROM bundle_components
+		WHERE bundle_id = ANY($1::int[])
+		ORDER BY bundle_id, component_id
+	`
+
+	rows, err := tx.Query(ctx, sql, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle components: %w", err)
+	}
+
+	defer rows.Close()
+
+	bundles := make(map[int][]models.BundleComponent)
+
+	for rows.Next() {
+		var bundleID int
+		var c models.BundleComponent
+		if err := rows.Scan(&bundleID, &c.ProductID, &c.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle components: %w", err)
+		}
+		bundles[bundleID] = append(bundles[bundleID], c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return bundles, nil
+}
+
+// dispatchBundle ships a bundle line by dispatching each component under
+// the bundle's order item, so lots and outgoing operations are recorded
+// against the line the customer ordered.
+func dispatchBundle(ctx context.Context, tx pgx.Tx, warehouseID int, item *models.OrderItem, components []models.BundleComponent) error {
+	item.Lots = nil
+
+	for _, c := range components {
+		part := models.OrderItem{
+			OrderItemID: item.OrderItemID,
+			OrderID:     item.OrderID,
+			ProductID:   c.ProductID,
+			Quantity:    item.Quantity * c.Quantity,
+		}
+		if err := dispatchItem(ctx, tx, warehouseID, &part); err != nil {
+			return err
+		}
+		item.Lots = append(item.Lots, part.Lots...)
+	}
+
+	return nil
+}
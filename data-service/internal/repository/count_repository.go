@@ -0,0 +1,1045 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type countRepo struct {
+	db *pgx.Conn
+}
+
+func NewCountRepository(db *pgx.Conn) CountRepository {
+	return &countRepo{db: db}
+}
+
+// Create opens a count session and freezes its scope: one line per listed
+// product, or one line per product held in each listed bin, each with the
+// book quantity at this moment. Stock keeps moving while the count is open.
+// Serial numbers are not kept by bin, so serialized products are counted
+// across the warehouse and left out of bin counts.
+func (r *countRepo) Create(ctx context.Context, s *models.CountSession) error {
+	if s == nil {
+		return fmt.Errorf("%w: count session cannot be nil", ErrInvalidInput)
+	}
+	if s.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if (len(s.ProductIDs) == 0) == (len(s.LocationIDs) == 0) {
+		return fmt.Errorf("%w: count either products or locations", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	insert := `INSERT INTO count_sessions (warehouse_id, status, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING session_id
+	`
+
+	s.Status = models.CountOpen
+	s.CreatedAt = time.Now()
+	s.ClosedAt = nil
+	s.Lines = nil
+
+	err = tx.QueryRow(ctx, insert, s.WarehouseID, s.Status, s.CreatedAt).Scan(&s.SessionID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%w: warehouse %d", ErrNotFound, s.WarehouseID)
+		}
+		return fmt.Errorf("failed to create count session: %w", err)
+	}
+
+	seen := make(map[int]bool)
+
+	for _, productID := range s.ProductIDs {
+		if productID <= 0 {
+			return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+		}
+		if seen[productID] {
+			return fmt.Errorf("%w: product %d listed twice", ErrInvalidInput, productID)
+		}
+		seen[productID] = true
+
+		line, err := addCountLine(ctx, tx, s, productID, nil)
+		if err != nil {
+			return err
+		}
+		s.Lines = append(s.Lines, *line)
+	}
+
+	for _, locationID := range s.LocationIDs {
+		if seen[locationID] {
+			return fmt.Errorf("%w: location %d listed twice", ErrInvalidInput, locationID)
+		}
+		seen[locationID] = true
+
+		warehouseID, err := binWarehouseID(ctx, tx, locationID)
+		if err != nil {
+			return err
+		}
+		if warehouseID != s.WarehouseID {
+			return fmt.Errorf("%w: location %d is not in warehouse %d", ErrInvalidInput, locationID, s.WarehouseID)
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO count_session_locations (session_id, location_id) VALUES ($1, $2)`,
+			s.SessionID, locationID)
+		if err != nil {
+			return fmt.Errorf("failed to add location %d to count: %w", locationID, err)
+		}
+
+		productIDs, err := locationProductIDs(ctx, tx, locationID)
+		if err != nil {
+			return err
+		}
+
+		for _, productID := range productIDs {
+			id := locationID
+			line, err := addCountLine(ctx, tx, s, productID, &id)
+			if err != nil {
+				return err
+			}
+			s.Lines = append(s.Lines, *line)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func locationProductIDs(ctx context.Context, tx pgx.Tx, locationID int) ([]int, error) {
+	rows, err := tx.Query(ctx, `SELECT ls.product_id FROM location_stock ls
+		JOIN products p ON p.product_id = ls.product_id
+		WHERE ls.location_id = $1 AND ls.quantity > 0 AND NOT p.is_serialized
+		ORDER BY ls.product_id`, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contents of location %d: %w", locationID, err)
+	}
+
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+func scanIDs(rows pgx.Rows) ([]int, error) {
+	var ids []int
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return ids, nil
+}
+
+// addCountLine freezes one product, in one bin or in the whole warehouse,
+// into an open session. The product row is locked first so the frozen
+// quantity cannot race a stock change, and a product already being counted
+// in an overlapping open session is refused: approving both would post the
+// same variance twice.
+func addCountLine(ctx context.Context, tx pgx.Tx, s *models.CountSession, productID int, locationID *int) (*models.CountLine, error) {
+	var serialized, hasVariants, isBundle bool
+
+	err := tx.QueryRow(ctx, `SELECT
+		is_serialized,
+		EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.product_id),
+		EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = products.product_id)
+		FROM products WHERE product_id = $1 FOR UPDATE`, productID).Scan(&serialized, &hasVariants, &isBundle)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: product %d", ErrProductNotFound, productID)
+		}
+		return nil, fmt.Errorf("failed to lock product %d: %w", productID, err)
+	}
+	switch {
+	case serialized && locationID != nil:
+		return nil, fmt.Errorf("%w: serialized product %d is counted across the warehouse, not by bin", ErrInvalidInput, productID)
+	case hasVariants:
+		return nil, fmt.Errorf("%w: product %d has variants, count the variants", ErrInvalidInput, productID)
+	case isBundle:
+		return nil, fmt.Errorf("%w: product %d is a bundle, count the components", ErrInvalidInput, productID)
+	}
+
+	overlap := `SELECT EXISTS (
+		SELECT 1 FROM count_lines cl
+		JOIN count_sessions cs ON cs.session_id = cl.session_id
+		WHERE cs.status = 'open'
+			AND cs.warehouse_id = $1
+			AND cs.session_id <> $2
+			AND cl.product_id = $3
+			AND (cl.location_id IS NULL OR $4::int IS NULL OR cl.location_id = $4)
+	)`
+
+	var busy bool
+	if err := tx.QueryRow(ctx, overlap, s.WarehouseID, s.SessionID, productID, locationID).Scan(&busy); err != nil {
+		return nil, fmt.Errorf("failed to check open counts of product %d: %w", productID, err)
+	}
+	if busy {
+		return nil, fmt.Errorf("%w: product %d is already being counted", ErrDuplicate, productID)
+	}
+
+	book, err := bookQuantity(ctx, tx, s.WarehouseID, productID, locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	line := models.CountLine{
+		ProductID:      productID,
+		LocationID:     locationID,
+		FrozenQuantity: book,
+		BookQuantity:   book,
+	}
+
+	insert := `INSERT INTO count_lines (session_id, product_id, location_id, frozen_quantity)
+		VALUES ($1, $2, $3, $4)
+		RETURNING count_line_id
+	`
+
+	err = tx.QueryRow(ctx, insert, s.SessionID, productID, locationID, book).Scan(&line.LineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create count line for product %d: %w", productID, err)
+	}
+
+	return &line, nil
+}
+
+// bookQuantity is the system stock of a product in one bin or, with no
+// location, in the whole warehouse.
+func bookQuantity(ctx context.Context, q rowQuerier, warehouseID, productID int, locationID *int) (int, error) {
+	var sql string
+	var args []any
+
+	if locationID != nil {
+		sql = `SELECT COALESCE((
+			SELECT quantity FROM location_stock WHERE location_id = $1 AND product_id = $2
+		), 0)`
+		args = []any{*locationID, productID}
+	} else {
+		sql = `SELECT COALESCE((
+			SELECT quantity FROM warehouse_stock WHERE warehouse_id = $1 AND product_id = $2
+		), 0)`
+		args = []any{warehouseID, productID}
+	}
+
+	var quantity int
+	if err := q.QueryRow(ctx, sql, args...).Scan(&quantity); err != nil {
+		return 0, fmt.Errorf("failed to get book quantity of product %d: %w", productID, err)
+	}
+
+	return quantity, nil
+}
+
+func (r *countRepo) GetByID(ctx context.Context, id int) (*models.CountSession, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			session_id,
+			warehouse_id,
+			status,
+			created_at,
+			closed_at
+		FROM count_sessions WHERE session_id = $1
+		`
+
+	var s models.CountSession
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&s.SessionID,
+		&s.WarehouseID,
+		&s.Status,
+		&s.CreatedAt,
+		&s.ClosedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get count session by id %d: %w", id, err)
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT location_id FROM count_session_locations
+		WHERE session_id = $1 ORDER BY location_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations of count session %d: %w", id, err)
+	}
+
+	s.LocationIDs, err = scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadCountLines(ctx, &s); err != nil {
+		return nil, err
+	}
+
+	if len(s.LocationIDs) == 0 {
+		for _, line := range s.Lines {
+			s.ProductIDs = append(s.ProductIDs, line.ProductID)
+		}
+	}
+
+	return &s, nil
+}
+
+// loadCountLines fills a session's lines with their current book quantity
+// and all entries, oldest first, and takes the count and variance from the
+// latest entry. Entries of serialized products carry their serials.
+func (r *countRepo) loadCountLines(ctx context.Context, s *models.CountSession) error {
+	sql := `SELECT
+		cl.count_line_id,
+		cl.product_id,
+		cl.location_id,
+		cl.frozen_quantity,
+		CASE WHEN cl.location_id IS NULL
+			THEN COALESCE(ws.quantity, 0)
+			ELSE COALESCE(ls.quantity, 0)
+		END
+		FROM count_lines cl
+		LEFT JOIN warehouse_stock ws ON ws.warehouse_id = $2 AND ws.product_id = cl.product_id
+		LEFT JOIN location_stock ls ON ls.location_id = cl.location_id AND ls.product_id = cl.product_id
+		WHERE cl.session_id = $1
+		ORDER BY cl.count_line_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, s.SessionID, s.WarehouseID)
+	if err != nil {
+		return fmt.Errorf("failed to get lines of count session %d: %w", s.SessionID, err)
+	}
+
+	byID := make(map[int]int)
+
+	for rows.Next() {
+		var line models.CountLine
+
+		err := rows.Scan(&line.LineID,
+			&line.ProductID,
+			&line.LocationID,
+			&line.FrozenQuantity,
+			&line.BookQuantity,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan count lines: %w", err)
+		}
+		byID[line.LineID] = len(s.Lines)
+		s.Lines = append(s.Lines, line)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	sql = `SELECT
+		ce.entry_id,
+		ce.count_line_id,
+		cl.product_id,
+		cl.location_id,
+		ce.counter,
+		ce.quantity,
+		ce.book_quantity,
+		ce.counted_at
+		FROM count_entries ce
+		JOIN count_lines cl ON cl.count_line_id = ce.count_line_id
+		WHERE cl.session_id = $1
+		ORDER BY ce.counted_at, ce.entry_id
+	`
+
+	rows, err = r.db.Query(ctx, sql, s.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get entries of count session %d: %w", s.SessionID, err)
+	}
+
+	type entryIndex struct{ line, entry int }
+	entries := make(map[int]entryIndex)
+
+	for rows.Next() {
+		var e models.CountEntry
+
+		err := rows.Scan(&e.EntryID,
+			&e.LineID,
+			&e.ProductID,
+			&e.LocationID,
+			&e.Counter,
+			&e.Quantity,
+			&e.BookQuantity,
+			&e.CountedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan count entries: %w", err)
+		}
+
+		line := &s.Lines[byID[e.LineID]]
+		counted, variance := e.Quantity, e.Quantity-e.BookQuantity
+		line.CountedQuantity = &counted
+		line.Variance = &variance
+		entries[e.EntryID] = entryIndex{line: byID[e.LineID], entry: len(line.Entries)}
+		line.Entries = append(line.Entries, e)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	sql = `SELECT ces.entry_id, ces.serial_number, ces.counted, ces.on_book
+		FROM count_entry_serials ces
+		JOIN count_entries ce ON ce.entry_id = ces.entry_id
+		JOIN count_lines cl ON cl.count_line_id = ce.count_line_id
+		WHERE cl.session_id = $1
+		ORDER BY ces.entry_id, ces.serial_number
+	`
+
+	rows, err = r.db.Query(ctx, sql, s.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get counted serials of count session %d: %w", s.SessionID, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int
+		var serial string
+		var counted, onBook bool
+
+		if err := rows.Scan(&entryID, &serial, &counted, &onBook); err != nil {
+			return fmt.Errorf("failed to scan counted serials: %w", err)
+		}
+
+		i := entries[entryID]
+		e := &s.Lines[i.line].Entries[i.entry]
+		if counted {
+			e.Serials = append(e.Serials, serial)
+		}
+		switch {
+		case counted && !onBook:
+			e.FoundSerials = append(e.FoundSerials, serial)
+		case !counted:
+			e.MissingSerials = append(e.MissingSerials, serial)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return nil
+}
+
+// GetByWarehouse lists a warehouse's count sessions without their lines.
+func (r *countRepo) GetByWarehouse(ctx context.Context, warehouseID int) ([]models.CountSession, error) {
+	if warehouseID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			session_id,
+			warehouse_id,
+			status,
+			created_at,
+			closed_at
+		FROM count_sessions
+		WHERE warehouse_id = $1
+		ORDER BY session_id DESC
+		`
+
+	rows, err := r.db.Query(ctx, sql, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get count sessions of warehouse %d: %w", warehouseID, err)
+	}
+
+	defer rows.Close()
+
+	var sessions []models.CountSession
+
+	for rows.Next() {
+		var s models.CountSession
+
+		err := rows.Scan(&s.SessionID,
+			&s.WarehouseID,
+			&s.Status,
+			&s.CreatedAt,
+			&s.ClosedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan count sessions: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// lockOpenSession locks a count session and checks it is still open.
+func lockOpenSession(ctx context.Context, tx pgx.Tx, sessionID int) (int, error) {
+	var warehouseID int
+	var status string
+
+	err := tx.QueryRow(ctx, `SELECT warehouse_id, status FROM count_sessions WHERE session_id = $1 FOR UPDATE`, sessionID).
+		Scan(&warehouseID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to lock count session %d: %w", sessionID, err)
+	}
+	if status != models.CountOpen {
+		return 0, fmt.Errorf("%w: count session %d is %s", ErrInvalidInput, sessionID, status)
+	}
+
+	return warehouseID, nil
+}
+
+// RecordCount stores one counter's count of a product, together with the
+// book quantity at that moment. A product found in a frozen bin that was
+// empty of it at freeze time gets a new line. A count of a serialized
+// product names every unit found, and the units on the books at that
+// moment are kept beside them.
+func (r *countRepo) RecordCount(ctx context.Context, sessionID int, e *models.CountEntry) error {
+	if sessionID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if e == nil {
+		return fmt.Errorf("%w: count cannot be nil", ErrInvalidInput)
+	}
+	if e.ProductID <= 0 {
+		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	e.Counter = strings.TrimSpace(e.Counter)
+	if e.Counter == "" {
+		return fmt.Errorf("%w: counter required", ErrInvalidInput)
+	}
+	if e.Quantity < 0 {
+		return fmt.Errorf("%w: counted quantity cannot be negative", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Entries only need the session to stay open, so several counters can
+	// record at once.
+	var warehouseID int
+	var status string
+
+	err = tx.QueryRow(ctx, `SELECT warehouse_id, status FROM count_sessions WHERE session_id = $1 FOR SHARE`, sessionID).
+		Scan(&warehouseID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock count session %d: %w", sessionID, err)
+	}
+	if status != models.CountOpen {
+		return fmt.Errorf("%w: count session %d is %s", ErrInvalidInput, sessionID, status)
+	}
+
+	// Serialise with stock changes so the book quantity matches the moment
+	// of counting.
+	var serialized bool
+	err = tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1 FOR UPDATE`, e.ProductID).Scan(&serialized)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: product %d is not part of count session %d", ErrInvalidInput, e.ProductID, sessionID)
+		}
+		return fmt.Errorf("failed to lock product %d: %w", e.ProductID, err)
+	}
+
+	if !serialized {
+		if len(e.Serials) > 0 {
+			return fmt.Errorf("%w: product %d is not serialized", ErrInvalidInput, e.ProductID)
+		}
+	} else if err := checkSerialNumbers(e.Serials, e.Quantity); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `SELECT count_line_id FROM count_lines
+		WHERE session_id = $1 AND product_id = $2 AND location_id IS NOT DISTINCT FROM $3`,
+		sessionID, e.ProductID, e.LocationID).Scan(&e.LineID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to get count line: %w", err)
+		}
+		if e.LocationID == nil {
+			return fmt.Errorf("%w: product %d is not part of count session %d", ErrInvalidInput, e.ProductID, sessionID)
+		}
+
+		var frozen bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (
+			SELECT 1 FROM count_session_locations WHERE session_id = $1 AND location_id = $2
+		)`, sessionID, *e.LocationID).Scan(&frozen)
+		if err != nil {
+			return fmt.Errorf("failed to check count session locations: %w", err)
+		}
+		if !frozen {
+			return fmt.Errorf("%w: location %d is not part of count session %d", ErrInvalidInput, *e.LocationID, sessionID)
+		}
+
+		s := models.CountSession{SessionID: sessionID, WarehouseID: warehouseID}
+		line, err := addCountLine(ctx, tx, &s, e.ProductID, e.LocationID)
+		if err != nil {
+			return err
+		}
+		e.LineID = line.LineID
+	}
+
+	e.BookQuantity, err = bookQuantity(ctx, tx, warehouseID, e.ProductID, e.LocationID)
+	if err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO count_entries (count_line_id, counter, quantity, book_quantity, counted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING entry_id
+	`
+
+	e.CountedAt = time.Now()
+
+	err = tx.QueryRow(ctx, insert, e.LineID, e.Counter, e.Quantity, e.BookQuantity, e.CountedAt).Scan(&e.EntryID)
+	if err != nil {
+		return fmt.Errorf("failed to record count: %w", err)
+	}
+
+	if serialized {
+		if err := recordCountSerials(ctx, tx, warehouseID, e); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Approve closes a session and posts an adjustment for every counted line
+// whose latest count differs from the book quantity at the time of that
+// count. The difference is applied to today's stock, so movements before
+// or after the count are kept. Lines nobody counted are left alone, and
+// serialized lines are settled unit by unit.
+func (r *countRepo) Approve(ctx context.Context, sessionID int) error {
+	if sessionID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	warehouseID, err := lockOpenSession(ctx, tx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	sql := `SELECT DISTINCT ON (cl.count_line_id)
+		cl.product_id,
+		cl.location_id,
+		ce.entry_id,
+		p.is_serialized,
+		ce.quantity - ce.book_quantity
+		FROM count_lines cl
+		JOIN count_entries ce ON ce.count_line_id = cl.count_line_id
+		JOIN products p ON p.product_id = cl.product_id
+		WHERE cl.session_id = $1
+		ORDER BY cl.count_line_id, ce.counted_at DESC, ce.entry_id DESC
+	`
+
+	rows, err := tx.Query(ctx, sql, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get variances of count session %d: %w", sessionID, err)
+	}
+
+	type variance struct {
+		productID  int
+		locationID *int
+		entryID    int
+		serialized bool
+		change     int
+	}
+
+	// A serialized count can match the books in number and still differ
+	// in which units were found.
+	var variances []variance
+	for rows.Next() {
+		var v variance
+		if err := rows.Scan(&v.productID, &v.locationID, &v.entryID, &v.serialized, &v.change); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan count variances: %w", err)
+		}
+		if v.change != 0 || v.serialized {
+			variances = append(variances, v)
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for _, v := range variances {
+		if v.serialized {
+			err = postSerialCount(ctx, tx, warehouseID, v.productID, v.entryID)
+		} else {
+			_, err = postCountVariance(ctx, tx, warehouseID, v.productID, v.locationID, v.change)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE count_sessions SET status = $1, closed_at = $2 WHERE session_id = $3`,
+		models.CountApproved, time.Now(), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to approve count session %d: %w", sessionID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// postCountVariance books a counted difference as adjustment operations.
+// A bin line changes that bin; a warehouse line adds found stock without a
+// bin and takes shrinkage the way a dispatch would, unbinned stock first.
+// Shrinkage comes out of lots first-expired-first-out, expired lots
+// included; found stock goes into the earliest-expiring unexpired lot on
+// hand, so goods of unknown origin are never sold past any lot's date.
+// The operations written are returned.
+func postCountVariance(ctx context.Context, tx pgx.Tx, warehouseID, productID int, locationID *int, change int) ([]models.Operation, error) {
+	_, err := tx.Exec(ctx, `SELECT 1 FROM products WHERE product_id = $1 FOR UPDATE`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock product %d: %w", productID, err)
+	}
+
+	op := models.Operation{OperationType: "adjustment"}
+
+	if change < 0 {
+		picks := []locationPick{{locationID: locationID, quantity: -change}}
+		if locationID != nil {
+			err = takeFromLocation(ctx, tx, *locationID, productID, -change)
+		} else {
+			picks, err = pickFromLocations(ctx, tx, warehouseID, productID, -change)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		draws, err := drawLots(ctx, tx, warehouseID, productID, -change, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := adjustStock(ctx, tx, warehouseID, productID, change); err != nil {
+			return nil, err
+		}
+
+		return insertOutgoing(ctx, tx, warehouseID, productID, picks, draws, op)
+	}
+
+	lotID, err := foundStockLot(ctx, tx, warehouseID, productID, change)
+	if err != nil {
+		return nil, err
+	}
+
+	if locationID != nil {
+		if err := putToLocation(ctx, tx, *locationID, productID, change); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := adjustStock(ctx, tx, warehouseID, productID, change); err != nil {
+		return nil, err
+	}
+
+	op.ProductID = productID
+	op.WarehouseID = warehouseID
+	op.ChangeQuant = change
+	op.ToLocationID = locationID
+	op.LotID = lotID
+
+	if err := costOperation(ctx, tx, &op); err != nil {
+		return nil, err
+	}
+	if err := insertOperation(ctx, tx, &op); err != nil {
+		return nil, fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return []models.Operation{op}, nil
+}
+
+// recordCountSerials keeps the units counted of a serialized product next
+// to the units on the books in the warehouse, and fills in e.FoundSerials
+// and e.MissingSerials. A unit counted that is not on the books has to be
+// unknown or lost; one held anywhere else must be moved, not counted.
+func recordCountSerials(ctx context.Context, tx pgx.Tx, warehouseID int, e *models.CountEntry) error {
+	check := `SELECT c.serial_number, s.status, s.warehouse_id
+		FROM unnest($3::text[]) AS c(serial_number)
+		JOIN serial_numbers s ON s.product_id = $1 AND s.serial_number = c.serial_number
+		WHERE s.status <> $4 AND NOT (s.warehouse_id = $2 AND s.status IN ($5, $6))
+		ORDER BY c.serial_number
+		LIMIT 1
+	`
+
+	var serial, status string
+	var heldIn int
+
+	err := tx.QueryRow(ctx, check, e.ProductID, warehouseID, e.Serials,
+		models.SerialLost, models.SerialInStock, models.SerialAllocated).Scan(&serial, &status, &heldIn)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: serial %s is %s in warehouse %d", ErrInvalidInput, serial, status, heldIn)
+	case !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("failed to check counted serials of product %d: %w", e.ProductID, err)
+	}
+
+	insert := `INSERT INTO count_entry_serials (entry_id, serial_number, counted, on_book)
+		SELECT $1,
+			COALESCE(c.serial_number, b.serial_number),
+			c.serial_number IS NOT NULL,
+			b.serial_number IS NOT NULL
+		FROM unnest($2::text[]) AS c(serial_number)
+		FULL JOIN (
+			SELECT serial_number FROM serial_numbers
+			WHERE product_id = $3 AND warehouse_id = $4 AND status IN ($5, $6)
+		) b ON b.serial_number = c.serial_number
+		RETURNING serial_number, counted, on_book
+	`
+
+	rows, err := tx.Query(ctx, insert, e.EntryID, e.Serials, e.ProductID, warehouseID,
+		models.SerialInStock, models.SerialAllocated)
+	if err != nil {
+		return fmt.Errorf("failed to record counted serials of product %d: %w", e.ProductID, err)
+	}
+
+	defer rows.Close()
+
+	e.FoundSerials, e.MissingSerials = nil, nil
+	for rows.Next() {
+		var counted, onBook bool
+		if err := rows.Scan(&serial, &counted, &onBook); err != nil {
+			return fmt.Errorf("failed to scan counted serials: %w", err)
+		}
+		switch {
+		case counted && !onBook:
+			e.FoundSerials = append(e.FoundSerials, serial)
+		case !counted:
+			e.MissingSerials = append(e.MissingSerials, serial)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	slices.Sort(e.FoundSerials)
+	slices.Sort(e.MissingSerials)
+
+	return nil
+}
+
+// postSerialCount settles a serialized count unit by unit. Units on the
+// books that were not counted are written off as lost, released from any
+// order line they were allocated to; counted units that were not on the
+// books are put in stock, registered if unknown and recovered if lost.
+// Units that have moved since the count are left alone.
+func postSerialCount(ctx context.Context, tx pgx.Tx, warehouseID, productID, entryID int) error {
+	_, err := tx.Exec(ctx, `SELECT 1 FROM products WHERE product_id = $1 FOR UPDATE`, productID)
+	if err != nil {
+		return fmt.Errorf("failed to lock product %d: %w", productID, err)
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE serial_numbers SET status = $1, order_item_id = NULL
+		WHERE product_id = $2 AND warehouse_id = $3 AND status IN ($4, $5)
+		AND serial_number IN (
+			SELECT serial_number FROM count_entry_serials WHERE entry_id = $6 AND NOT counted
+		)
+		RETURNING serial_id`,
+		models.SerialLost, productID, warehouseID, models.SerialInStock, models.SerialAllocated, entryID)
+	if err != nil {
+		return fmt.Errorf("failed to write off serials of product %d: %w", productID, err)
+	}
+
+	lost, err := scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	if len(lost) > 0 {
+		ops, err := postCountVariance(ctx, tx, warehouseID, productID, nil, -len(lost))
+		if err != nil {
+			return err
+		}
+		if err := linkSerials(ctx, tx, ops, lost); err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.Query(ctx, `SELECT ces.serial_number, s.serial_id
+		FROM count_entry_serials ces
+		LEFT JOIN serial_numbers s ON s.product_id = $2 AND s.serial_number = ces.serial_number
+		WHERE ces.entry_id = $1 AND ces.counted AND NOT ces.on_book
+		AND (s.serial_id IS NULL OR s.status = $3)
+		ORDER BY ces.serial_number`, entryID, productID, models.SerialLost)
+	if err != nil {
+		return fmt.Errorf("failed to get found serials of product %d: %w", productID, err)
+	}
+
+	var unknown []string
+	var recovered []int
+	for rows.Next() {
+		var serial string
+		var serialID *int
+		if err := rows.Scan(&serial, &serialID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan found serials: %w", err)
+		}
+		if serialID == nil {
+			unknown = append(unknown, serial)
+		} else {
+			recovered = append(recovered, *serialID)
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	found := len(unknown) + len(recovered)
+	if found == 0 {
+		return nil
+	}
+
+	ops, err := postCountVariance(ctx, tx, warehouseID, productID, nil, found)
+	if err != nil {
+		return err
+	}
+	op := ops[0]
+
+	if len(unknown) > 0 {
+		rc := models.Receipt{
+			ProductID:   productID,
+			WarehouseID: warehouseID,
+			Serials:     unknown,
+			LotID:       op.LotID,
+			OperationID: op.OperationID,
+		}
+		if err := registerSerials(ctx, tx, &rc); err != nil {
+			return err
+		}
+	}
+
+	if len(recovered) > 0 {
+		_, err := tx.Exec(ctx, `UPDATE serial_numbers SET status = $1, warehouse_id = $2, lot_id = $3
+			WHERE serial_id = ANY($4::int[])`,
+			models.SerialInStock, warehouseID, nullableID(op.LotID), recovered)
+		if err != nil {
+			return fmt.Errorf("failed to recover serials of product %d: %w", productID, err)
+		}
+
+		for _, serialID := range recovered {
+			if err := linkSerial(ctx, tx, op.OperationID, serialID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// foundStockLot adds found stock to the product's earliest-expiring lot
+// still in stock and in date in the warehouse and returns it, or nil if
+// there is none. Found stock never revives an expired lot.
+func foundStockLot(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int) (*int, error) {
+	sql := `UPDATE lots SET quantity = quantity + $3
+		WHERE lot_id = (
+			SELECT lot_id FROM lots
+			WHERE warehouse_id = $1 AND product_id = $2 AND quantity > 0
+			AND (expires_at IS NULL OR expires_at > CURRENT_DATE)
+			ORDER BY expires_at NULLS LAST, lot_id
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING lot_id
+	`
+
+	var lotID int
+	err := tx.QueryRow(ctx, sql, warehouseID, productID, quantity).Scan(&lotID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to add found stock to a lot of product %d: %w", productID, err)
+	}
+
+	return &lotID, nil
+}
+
+// Cancel closes a session without touching stock.
+func (r *countRepo) Cancel(ctx context.Context, sessionID int) error {
+	if sessionID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockOpenSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE count_sessions SET status = $1, closed_at = $2 WHERE session_id = $3`,
+		models.CountCancelled, time.Now(), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel count session %d: %w", sessionID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type currencyRepo struct {
+	db           *pgx.Conn
+	baseCurrency string
+}
+
+// NewCurrencyRepository reports in baseCurrency, the currency catalogue
+// prices are in.
+func NewCurrencyRepository(db *pgx.Conn, baseCurrency string) CurrencyRepository {
+	baseCurrency = normalizeCurrency(baseCurrency)
+	if baseCurrency == "" {
+		baseCurrency = models.DefaultBaseCurrency
+	}
+	return &currencyRepo{db: db, baseCurrency: baseCurrency}
+}
+
+// normalizeCurrency makes currency codes case-insensitive.
+func normalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// fromBase converts a base currency amount into an order's currency.
+func fromBase(amount models.Money, order *models.Order) models.Money {
+	if order.ExchangeRate <= 0 || order.ExchangeRate == models.OneRate {
+		return amount
+	}
+	return amount.AtRate(order.ExchangeRate)
+}
+
+// exchangeRate is the rate in force today for a currency; the base
+// currency's is 1.
+func exchangeRate(ctx context.Context, q rowQuerier, currency, baseCurrency string) (models.Rate, error) {
+	if currency == baseCurrency {
+		return models.OneRate, nil
+	}
+	if !validCurrency(currency) {
+		return 0, fmt.Errorf("%w: invalid currency %q", ErrInvalidInput, currency)
+	}
+
+	var rate models.Rate
+	err := q.QueryRow(ctx, `SELECT rate FROM exchange_rates
+		WHERE currency = $1 AND effective_from <= CURRENT_DATE
+		ORDER BY effective_from DESC
+		LIMIT 1`, currency).Scan(&rate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: no exchange rate for %s", ErrInvalidInput, currency)
+		}
+		return 0, fmt.Errorf("failed to get exchange rate of %s: %w", currency, err)
+	}
+
+	return rate, nil
+}
+
+// CreateRate records a currency's rate from a date on. A currency has at
+// most one rate per date.
+func (r *currencyRepo) CreateRate(ctx context.Context, rate *models.ExchangeRate) error {
+	if rate == nil {
+		return fmt.Errorf("%w: exchange rate cannot be nil", ErrInvalidInput)
+	}
+
+	rate.Currency = normalizeCurrency(rate.Currency)
+
+	if !validCurrency(rate.Currency) {
+		return fmt.Errorf("%w: currency must be a three-letter code", ErrInvalidInput)
+	}
+	if rate.Currency == r.baseCurrency {
+		return fmt.Errorf("%w: %s is the base currency", ErrInvalidInput, rate.Currency)
+	}
+	if rate.Rate <= 0 {
+		return fmt.Errorf("%w: rate must be positive", ErrInvalidInput)
+	}
+	if rate.EffectiveFrom.IsZero() {
+		return fmt.Errorf("%w: effective from date is required", ErrInvalidInput)
+	}
+
+	insert := `INSERT INTO exchange_rates (
+		currency,
+		rate,
+		effective_from,
+		created_at
+	) VALUES ($1, $2, $3::date, $4)
+	RETURNING exchange_rate_id, rate, effective_from
+	`
+
+	rate.CreatedAt = time.Now()
+
+	err := r.db.QueryRow(ctx, insert, rate.Currency, rate.Rate, rate.EffectiveFrom, rate.CreatedAt).
+		Scan(&rate.ExchangeRateID, &rate.Rate, &rate.EffectiveFrom)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: %s already has a rate from %s", ErrDuplicate, rate.Currency, rate.EffectiveFrom.Format(time.DateOnly))
+		}
+		return fmt.Errorf("failed to create exchange rate: %w", err)
+	}
+
+	return nil
+}
+
+// GetRates lists a currency's rates, oldest first.
+func (r *currencyRepo) GetRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
+	currency = normalizeCurrency(currency)
+	if !validCurrency(currency) {
+		return nil, fmt.Errorf("%w: currency must be a three-letter code", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			exchange_rate_id,
+			currency,
+			rate,
+			effective_from,
+			created_at
+		FROM exchange_rates
+		WHERE currency = $1
+		ORDER BY effective_from
+		`
+
+	rows, err := r.db.Query(ctx, sql, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates of %s: %w", currency, err)
+	}
+
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+
+	for rows.Next() {
+		var e models.ExchangeRate
+
+		err := rows.Scan(&e.ExchangeRateID,
+			&e.Currency,
+			&e.Rate,
+			&e.EffectiveFrom,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rates: %w", err)
+		}
+		rates = append(rates, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return rates, nil
+}
+
+// GetSalesReport converts each order at the rate it was placed at, rounded
+// to the cent, so an order counts the same however late the report is run.
+func (r *currencyRepo) GetSalesReport(ctx context.Context, from, to time.Time) (*models.SalesReport, error) {
+	if from.IsZero() || to.IsZero() {
+		return nil, fmt.Errorf("%w: report needs a from and a to date", ErrInvalidInput)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: report cannot end before it starts", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			COALESCE(currency, $3),
+			COUNT(*)::int,
+			SUM(net_amount),
+			SUM(tax_amount),
+			SUM(total_amount),
+			SUM(ROUND(net_amount * exchange_rate, 2)),
+			SUM(ROUND(tax_amount * exchange_rate, 2))
+		FROM orders
+		WHERE created_at >= $1::date
+		AND created_at < $2::date + 1
+		AND status NOT IN ($4, $5)
+		GROUP BY 1
+		ORDER BY 1
+		`
+
+	rows, err := r.db.Query(ctx, sql, from, to, r.baseCurrency, models.OrderCancelled, models.OrderDraft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales report: %w", err)
+	}
+
+	defer rows.Close()
+
+	report := models.SalesReport{
+		From:         from,
+		To:           to,
+		BaseCurrency: r.baseCurrency,
+		Currencies:   []models.CurrencySales{},
+	}
+
+	for rows.Next() {
+		var c models.CurrencySales
+
+		err := rows.Scan(&c.Currency,
+			&c.Orders,
+			&c.NetAmount,
+			&c.TaxAmount,
+			&c.TotalAmount,
+			&c.BaseNetAmount,
+			&c.BaseTaxAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sales report: %w", err)
+		}
+		c.BaseTotalAmount = c.BaseNetAmount + c.BaseTaxAmount
+
+		report.Orders += c.Orders
+		report.NetAmount += c.BaseNetAmount
+		report.TaxAmount += c.BaseTaxAmount
+		report.TotalAmount += c.BaseTotalAmount
+		report.Currencies = append(report.Currencies, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return &report, nil
+}
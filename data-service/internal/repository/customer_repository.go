@@ -48,13 +48,15 @@ func (r *customerRepo) Create(ctx context.Context, c *models.Customer) error {
 			phone_number,
 			address,
 			email,
+			customer_group,
 			registered_at
-	) VALUES ($1, $2, $3, $4, $5)
+	) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
 	RETURNING customer_id
 	`
 
 	now := time.Now()
 	c.RegisteredAt = now
+	c.Group = strings.TrimSpace(c.Group)
 
 	err := r.db.QueryRow(ctx, sql,
 		c.Name,
@@ -62,6 +64,7 @@ func (r *customerRepo) Create(ctx context.Context, c *models.Customer) error {
 		c.Address,
 
 		c.Email,
+		c.Group,
 		c.RegisteredAt,
 	).Scan(&c.CustomerID)
 	if err != nil {
@@ -93,6 +96,7 @@ func (r *customerRepo) GetByID(ctx context.Context, id int) (*models.Customer, e
 		phone_number,
 		address,
 		email,
+		COALESCE(customer_group, ''),
 		registered_at
 		FROM customers WHERE customer_id = $1
 	`
@@ -105,6 +109,7 @@ func (r *customerRepo) GetByID(ctx context.Context, id int) (*models.Customer, e
 		&customer.PhoneNumber,
 		&customer.Address,
 		&customer.Email,
+		&customer.Group,
 		&customer.RegisteredAt,
 	)
 	if err != nil {
@@ -125,6 +130,7 @@ func (r *customerRepo) GetAll(ctx context.Context) ([]models.Customer, error) {
 	phone_number,
 	address,
 	email,
+	COALESCE(customer_group, ''),
 	registered_at
 	FROM customers
 	ORDER BY customer_id`
@@ -146,6 +152,7 @@ func (r *customerRepo) GetAll(ctx context.Context) ([]models.Customer, error) {
 			&c.PhoneNumber,
 			&c.Address,
 			&c.Email,
+			&c.Group,
 			&c.RegisteredAt,
 		)
 		if err != nil {
@@ -188,15 +195,19 @@ func (r *customerRepo) Update(ctx context.Context, c *models.Customer) error {
 		name = $1,
 		phone_number = $2,
 		address = $3,
-		email = $4
-	WHERE customer_id = $5
+		email = $4,
+		customer_group = NULLIF($5, '')
+	WHERE customer_id = $6
 	`
 
+	c.Group = strings.TrimSpace(c.Group)
+
 	result, err := r.db.Exec(ctx, sql,
 		c.Name,
 		c.PhoneNumber,
 		c.Address,
 		c.Email,
+		c.Group,
 		c.CustomerID,
 	)
 
@@ -262,6 +273,7 @@ func (r *customerRepo) GetByEmail(ctx context.Context, email string) (*models.Cu
 		phone_number,
 		address,
 		email,
+		COALESCE(customer_group, ''),
 		registered_at
 		FROM customers WHERE email = $1
 	`
@@ -274,6 +286,7 @@ func (r *customerRepo) GetByEmail(ctx context.Context, email string) (*models.Cu
 		&customer.PhoneNumber,
 		&customer.Address,
 		&customer.Email,
+		&customer.Group,
 		&customer.RegisteredAt,
 	)
 	if err != nil {
@@ -298,6 +311,7 @@ func (r *customerRepo) GetByPhoneNumber(ctx context.Context, phoneNumber string)
 		phone_number,
 		address,
 		email,
+		COALESCE(customer_group, ''),
 		registered_at
 		FROM customers WHERE phone_number = $1
 	`
@@ -310,6 +324,7 @@ func (r *customerRepo) GetByPhoneNumber(ctx context.Context, phoneNumber string)
 		&customer.PhoneNumber,
 		&customer.Address,
 		&customer.Email,
+		&customer.Group,
 		&customer.RegisteredAt,
 	)
 	if err != nil {
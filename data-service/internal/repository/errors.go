@@ -3,10 +3,13 @@ package repository
 import "errors"
 
 var (
-	ErrNotFound        = errors.New("resourсe not found")
-	ErrDuplicate       = errors.New("duplicate resource")
-	ErrInvalidInput    = errors.New("invalid input data")
-	ErrNotEnough       = errors.New("not enough quantity available")
-	ErrProductNotFound = errors.New("product not found")
-	ErrCustomerExists  = errors.New("customer already exists")
+	ErrNotFound          = errors.New("resourсe not found")
+	ErrDuplicate         = errors.New("duplicate resource")
+	ErrInvalidInput      = errors.New("invalid input data")
+	ErrNotEnough         = errors.New("not enough quantity available")
+	ErrProductNotFound   = errors.New("product not found")
+	ErrCustomerExists    = errors.New("customer already exists")
+	ErrNotActive         = errors.New("reservation is not active")
+	ErrSerialsRequired   = errors.New("serial numbers must be assigned first")
+	ErrInvalidTransition = errors.New("invalid status transition")
 )
@@ -3,17 +3,30 @@ package repository
 import (
 	"context"
 	"data-service/internal/models"
+	"time"
 )
 
 type ProductRepository interface {
 	Create(ctx context.Context, product *models.Product) error
 	GetByID(ctx context.Context, id int) (*models.Product, error)
-	GetAll(ctx context.Context) ([]models.Product, error)
+	GetAll(ctx context.Context, withVariants bool) ([]models.Product, error)
 	Update(ctx context.Context, product *models.Product) error
 	Delete(ctx context.Context, id int) error
 
-	UpdateQuantity(ctx context.Context, id int, change int) error
-	GetByCategory(ctx context.Context, category string) ([]models.Product, error)
+	UpdateQuantity(ctx context.Context, id int, warehouseID int, change int) error
+	GetByCategory(ctx context.Context, category string, withVariants bool) ([]models.Product, error)
+	GetStockLevel(ctx context.Context, id int) (*models.StockLevel, error)
+
+	GetBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetByBarcode(ctx context.Context, barcode string) (*models.Product, error)
+	AddBarcode(ctx context.Context, productID int, barcode *models.Barcode) error
+	RemoveBarcode(ctx context.Context, productID int, barcode string) error
+
+	SetUnit(ctx context.Context, productID int, unit *models.ProductUnit) error
+	RemoveUnit(ctx context.Context, productID int, unit string) error
+
+	GetLowStock(ctx context.Context) ([]models.Product, error)
+	GetStockAlerts(ctx context.Context, openOnly bool) ([]models.StockAlert, error)
 }
 
 type CustomerRepository interface {
@@ -31,14 +44,165 @@ type OrderRepository interface {
 	CreateOrder(ctx context.Context, order *models.Order, items []models.OrderItem) error
 	GetByID(ctx context.Context, id int) (*models.Order, error)
 	GetAll(ctx context.Context) ([]models.Order, error)
-	UpdateStatus(ctx context.Context, id int, status string) error
+	UpdateStatus(ctx context.Context, id int, status, changedBy string) error
+	GetStatusHistory(ctx context.Context, id int) ([]models.OrderStatusChange, error)
+	ClearAttention(ctx context.Context, id int) error
 
 	GetByCustomerID(ctx context.Context, customerID int) ([]models.Order, error)
 	GetOrderWithItems(ctx context.Context, id int) (*models.Order, []models.OrderItem, error)
+	AssignSerials(ctx context.Context, orderItemID int, serials []string) error
 }
 
 type OperationRepository interface {
 	Create(ctx context.Context, operation *models.Operation) error
 	GetByProductID(ctx context.Context, productID int) ([]models.Operation, error)
 	GetByOrderID(ctx context.Context, orderID int) ([]models.Operation, error)
+
+	Reconcile(ctx context.Context, correct bool) (*models.ReconciliationReport, error)
+
+	TakeSnapshot(ctx context.Context) (*models.StockSnapshot, error)
+	GetStockAsOf(ctx context.Context, productID int, at time.Time) (*models.StockAsOf, error)
+	GetCategoryStockAsOf(ctx context.Context, category string, at time.Time) ([]models.StockAsOf, error)
+}
+
+type ReservationRepository interface {
+	Create(ctx context.Context, reservation *models.Reservation) error
+	GetByID(ctx context.Context, id int) (*models.Reservation, error)
+	GetActiveByProductID(ctx context.Context, productID int) ([]models.Reservation, error)
+	Release(ctx context.Context, id int) error
+	ReleaseExpired(ctx context.Context) (int, error)
+}
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, warehouse *models.Warehouse) error
+	GetByID(ctx context.Context, id int) (*models.Warehouse, error)
+	GetAll(ctx context.Context) ([]models.Warehouse, error)
+
+	GetStock(ctx context.Context, warehouseID int) ([]models.WarehouseStock, error)
+}
+
+type LocationRepository interface {
+	Create(ctx context.Context, location *models.Location) error
+	GetByID(ctx context.Context, id int) (*models.Location, error)
+	GetByCode(ctx context.Context, code string) (*models.Location, error)
+	GetByWarehouse(ctx context.Context, warehouseID int) ([]models.Location, error)
+
+	GetContents(ctx context.Context, locationID int) ([]models.LocationStock, error)
+	MoveStock(ctx context.Context, move *models.StockMove) error
+}
+
+type LotRepository interface {
+	Receive(ctx context.Context, receipt *models.Receipt) error
+	GetByID(ctx context.Context, id int) (*models.Lot, error)
+	GetByProductID(ctx context.Context, productID int) ([]models.Lot, error)
+	GetExpiring(ctx context.Context, before time.Time) ([]models.Lot, error)
+}
+
+type SerialRepository interface {
+	GetByNumber(ctx context.Context, productID int, serialNumber string) (*models.SerialNumber, error)
+	GetInStock(ctx context.Context, productID int) ([]models.SerialNumber, error)
+	GetHistory(ctx context.Context, productID int, serialNumber string) (*models.SerialHistory, error)
+}
+
+type BundleRepository interface {
+	SetComponents(ctx context.Context, bundle *models.Bundle) error
+	GetByID(ctx context.Context, productID int, warehouseID int) (*models.Bundle, error)
+}
+
+type SupplierRepository interface {
+	Create(ctx context.Context, supplier *models.Supplier) error
+	GetByID(ctx context.Context, id int) (*models.Supplier, error)
+	GetAll(ctx context.Context) ([]models.Supplier, error)
+}
+
+type PurchaseOrderRepository interface {
+	Create(ctx context.Context, po *models.PurchaseOrder) error
+	GetByID(ctx context.Context, id int) (*models.PurchaseOrder, error)
+	GetBySupplier(ctx context.Context, supplierID int) ([]models.PurchaseOrder, error)
+	UpdateStatus(ctx context.Context, id int, status string) error
+
+	Receive(ctx context.Context, poID int, receipts []models.Receipt) error
+}
+
+type CountRepository interface {
+	Create(ctx context.Context, session *models.CountSession) error
+	GetByID(ctx context.Context, id int) (*models.CountSession, error)
+	GetByWarehouse(ctx context.Context, warehouseID int) ([]models.CountSession, error)
+	RecordCount(ctx context.Context, sessionID int, entry *models.CountEntry) error
+	Approve(ctx context.Context, sessionID int) error
+	Cancel(ctx context.Context, sessionID int) error
+}
+
+type TransferRepository interface {
+	Create(ctx context.Context, transfer *models.Transfer) error
+	GetByID(ctx context.Context, id int) (*models.Transfer, error)
+	GetByStatus(ctx context.Context, status string) ([]models.Transfer, error)
+
+	Dispatch(ctx context.Context, id int) error
+	Receive(ctx context.Context, id int, receipts []models.TransferReceipt) error
+	Cancel(ctx context.Context, id int) error
+}
+
+type ReturnRepository interface {
+	Create(ctx context.Context, ret *models.Return) error
+	GetByID(ctx context.Context, id int) (*models.Return, error)
+	GetByOrder(ctx context.Context, orderID int) ([]models.Return, error)
+
+	Receive(ctx context.Context, id int, inspections []models.ReturnInspection) error
+	Cancel(ctx context.Context, id int) error
+}
+
+type ShipmentRepository interface {
+	Create(ctx context.Context, s *models.Shipment) error
+	GetByID(ctx context.Context, id int) (*models.Shipment, error)
+	GetByOrder(ctx context.Context, orderID int) ([]models.Shipment, error)
+
+	Confirm(ctx context.Context, id int, confirmedBy string) error
+	Cancel(ctx context.Context, id int) error
+}
+
+type WaveRepository interface {
+	Plan(ctx context.Context, rule *models.WaveRule) ([]models.Wave, error)
+	GetByID(ctx context.Context, id int) (*models.Wave, error)
+	GetByWarehouse(ctx context.Context, warehouseID int, status string) ([]models.Wave, error)
+
+	ConfirmPick(ctx context.Context, lineID, picked int, pickedBy string) error
+	Cancel(ctx context.Context, id int) error
+}
+
+type PromotionRepository interface {
+	Create(ctx context.Context, p *models.Promotion) error
+	GetByID(ctx context.Context, id int) (*models.Promotion, error)
+	GetAll(ctx context.Context) ([]models.Promotion, error)
+	Deactivate(ctx context.Context, id int) error
+}
+
+type TaxRepository interface {
+	SetTaxClass(ctx context.Context, productID int, taxClass string) error
+	CreateRate(ctx context.Context, rate *models.TaxRate) error
+	EndRate(ctx context.Context, id int, effectiveTo time.Time) error
+	GetRates(ctx context.Context, jurisdiction string) ([]models.TaxRate, error)
+}
+
+type CurrencyRepository interface {
+	CreateRate(ctx context.Context, rate *models.ExchangeRate) error
+	GetRates(ctx context.Context, currency string) ([]models.ExchangeRate, error)
+	GetSalesReport(ctx context.Context, from, to time.Time) (*models.SalesReport, error)
+}
+
+type PriceListRepository interface {
+	Create(ctx context.Context, pl *models.PriceList) error
+	GetByID(ctx context.Context, id int) (*models.PriceList, error)
+	GetByCustomer(ctx context.Context, customerID int) ([]models.PriceList, error)
+	SetPrices(ctx context.Context, id int, prices []models.PriceListPrice) error
+
+	Quote(ctx context.Context, customerID, productID, quantity int, currency string) (*models.PriceQuote, error)
+}
+
+type ValuationRepository interface {
+	SetCostMethod(ctx context.Context, productID int, method string) error
+	GetProductValue(ctx context.Context, productID int) (*models.ProductValuation, error)
+	GetValues(ctx context.Context, category string) ([]models.ProductValuation, error)
+	GetCategoryValues(ctx context.Context) ([]models.CategoryValuation, error)
+	GetOrderCOGS(ctx context.Context, orderID int) (*models.OrderCOGS, error)
 }
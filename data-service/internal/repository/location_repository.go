@@ -0,0 +1,506 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Location codes are upper-case segments joined by dashes, e.g. A-03-R2-B14.
+var locationCodeRe = regexp.MustCompile(`^[A-Z0-9]{1,10}(-[A-Z0-9]{1,10}){0,5}$`)
+
+// locationParentType lists the level a location of each type must hang off.
+var locationParentType = map[string]string{
+	models.LocationZone:  "",
+	models.LocationAisle: models.LocationZone,
+	models.LocationRack:  models.LocationAisle,
+	models.LocationBin:   models.LocationRack,
+}
+
+type locationRepo struct {
+	db *pgx.Conn
+}
+
+func NewLocationRepository(db *pgx.Conn) LocationRepository {
+	return &locationRepo{db: db}
+}
+
+func (r *locationRepo) Create(ctx context.Context, l *models.Location) error {
+	if l == nil {
+		return fmt.Errorf("%w: location cannot be nil", ErrInvalidInput)
+	}
+
+	l.Code = strings.ToUpper(strings.TrimSpace(l.Code))
+	if !locationCodeRe.MatchString(l.Code) {
+		return fmt.Errorf("%w: invalid location code '%s'", ErrInvalidInput, l.Code)
+	}
+
+	parentType, ok := locationParentType[l.Type]
+	if !ok {
+		return fmt.Errorf("%w: invalid location type '%s'", ErrInvalidInput, l.Type)
+	}
+
+	if parentType == "" {
+		if l.ParentID != nil {
+			return fmt.Errorf("%w: zone cannot have a parent", ErrInvalidInput)
+		}
+		if l.WarehouseID <= 0 {
+			return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+		}
+	} else {
+		if l.ParentID == nil {
+			return fmt.Errorf("%w: %s must be placed in a %s", ErrInvalidInput, l.Type, parentType)
+		}
+
+		parent, err := r.GetByID(ctx, *l.ParentID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("%w: parent location %d not found", ErrInvalidInput, *l.ParentID)
+			}
+			return err
+		}
+		if parent.Type != parentType {
+			return fmt.Errorf("%w: %s must be placed in a %s, got %s", ErrInvalidInput, l.Type, parentType, parent.Type)
+		}
+
+		// Children always live in their parent's warehouse.
+		l.WarehouseID = parent.WarehouseID
+	}
+
+	sql := `
+		INSERT INTO locations (
+			warehouse_id,
+			parent_id,
+			code,
+			location_type,
+			name,
+			created_at
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING location_id
+	`
+
+	l.CreatedAt = time.Now()
+
+	err := r.db.QueryRow(ctx, sql,
+		l.WarehouseID,
+		nullableID(l.ParentID),
+		l.Code,
+		l.Type,
+		l.Name,
+		l.CreatedAt,
+	).Scan(&l.LocationID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return fmt.Errorf("%w: location code already exists", ErrDuplicate)
+			case "23503":
+				return fmt.Errorf("%w: warehouse %d not found", ErrInvalidInput, l.WarehouseID)
+			}
+		}
+		return fmt.Errorf("failed to create location: %w", err)
+	}
+
+	return nil
+}
+
+func (r *locationRepo) GetByID(ctx context.Context, id int) (*models.Location, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	return r.getOne(ctx, "location_id = $1", id)
+}
+
+func (r *locationRepo) GetByCode(ctx context.Context, code string) (*models.Location, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, fmt.Errorf("%w: code cannot be empty", ErrInvalidInput)
+	}
+
+	return r.getOne(ctx, "code = $1", code)
+}
+
+func (r *locationRepo) getOne(ctx context.Context, where string, arg interface{}) (*models.Location, error) {
+	sql := `
+		SELECT
+			location_id,
+			warehouse_id,
+			parent_id,
+			code,
+			location_type,
+			COALESCE(name, ''),
+			created_at
+		FROM locations WHERE ` + where
+
+	var l models.Location
+
+	err := r.db.QueryRow(ctx, sql, arg).Scan(
+		&l.LocationID,
+		&l.WarehouseID,
+		&l.ParentID,
+		&l.Code,
+		&l.Type,
+		&l.Name,
+		&l.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+
+	return &l, nil
+}
+
+func (r *locationRepo) GetByWarehouse(ctx context.Context, warehouseID int) ([]models.Location, error) {
+	if warehouseID <= 0 {
+		return nil, fmt.Errorf("%w: warehouse ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			location_id,
+			warehouse_id,
+			parent_id,
+			code,
+			location_type,
+			COALESCE(name, ''),
+			created_at
+		FROM locations
+		WHERE warehouse_id = $1
+		ORDER BY code
+		`
+
+	rows, err := r.db.Query(ctx, sql, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations for warehouse %d: %w", warehouseID, err)
+	}
+
+	defer rows.Close()
+
+	var locations []models.Location
+
+	for rows.Next() {
+		var l models.Location
+
+		err := rows.Scan(&l.LocationID,
+			&l.WarehouseID,
+			&l.ParentID,
+			&l.Code,
+			&l.Type,
+			&l.Name,
+			&l.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan locations: %w", err)
+		}
+		locations = append(locations, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return locations, nil
+}
+
+// GetContents lists the stock held in a location. For a zone, aisle or
+// rack the bins underneath it are included, one row per bin and product.
+func (r *locationRepo) GetContents(ctx context.Context, locationID int) ([]models.LocationStock, error) {
+	if locationID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		WITH RECURSIVE tree AS (
+			SELECT location_id FROM locations WHERE location_id = $1
+			UNION ALL
+			SELECT l.location_id FROM locations l JOIN tree t ON l.parent_id = t.location_id
+		)
+		SELECT
+			ls.location_id,
+			ls.product_id,
+			ls.quantity,
+			ls.updated_at
+		FROM location_stock ls
+		JOIN tree t ON t.location_id = ls.location_id
+		WHERE ls.quantity > 0
+		ORDER BY ls.location_id, ls.product_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contents of location %d: %w", locationID, err)
+	}
+
+	defer rows.Close()
+
+	var stock []models.LocationStock
+
+	for rows.Next() {
+		var s models.LocationStock
+
+		err := rows.Scan(&s.LocationID,
+			&s.ProductID,
+			&s.Quantity,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location stock: %w", err)
+		}
+		stock = append(stock, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return stock, nil
+}
+
+func (r *locationRepo) MoveStock(ctx context.Context, m *models.StockMove) error {
+	if m == nil {
+		return fmt.Errorf("%w: move cannot be nil", ErrInvalidInput)
+	}
+	if m.ProductID <= 0 {
+		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	if m.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+	}
+	if m.FromLocationID == nil && m.ToLocationID == nil {
+		return fmt.Errorf("%w: source or destination location required", ErrInvalidInput)
+	}
+	if m.FromLocationID != nil && m.ToLocationID != nil && *m.FromLocationID == *m.ToLocationID {
+		return fmt.Errorf("%w: source and destination must differ", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	warehouseID := 0
+	for _, id := range []*int{m.FromLocationID, m.ToLocationID} {
+		if id == nil {
+			continue
+		}
+
+		binWarehouse, err := binWarehouseID(ctx, tx, *id)
+		if err != nil {
+			return err
+		}
+		if warehouseID != 0 && warehouseID != binWarehouse {
+			return fmt.Errorf("%w: locations belong to different warehouses", ErrInvalidInput)
+		}
+		warehouseID = binWarehouse
+	}
+
+	// Serialise stock changes for the product, as CreateOrder does.
+	_, err = tx.Exec(ctx, `SELECT 1 FROM products WHERE product_id = $1 FOR UPDATE`, m.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to lock product %d: %w", m.ProductID, err)
+	}
+
+	if m.FromLocationID != nil {
+		if err := takeFromLocation(ctx, tx, *m.FromLocationID, m.ProductID, m.Quantity); err != nil {
+			return err
+		}
+	} else {
+		unlocated, err := unlocatedStock(ctx, tx, warehouseID, m.ProductID)
+		if err != nil {
+			return err
+		}
+		if unlocated < m.Quantity {
+			return fmt.Errorf("%w: only %d units of product %d are not in a bin", ErrNotEnough, unlocated, m.ProductID)
+		}
+	}
+
+	if m.ToLocationID != nil {
+		if err := putToLocation(ctx, tx, *m.ToLocationID, m.ProductID, m.Quantity); err != nil {
+			return err
+		}
+	}
+
+	err = insertOperation(ctx, tx, &models.Operation{
+		ProductID:      m.ProductID,
+		WarehouseID:    warehouseID,
+		OperationType:  "move",
+		ChangeQuant:    m.Quantity,
+		FromLocationID: m.FromLocationID,
+		ToLocationID:   m.ToLocationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// binWarehouseID checks that a location exists and is a bin, and returns
+// the warehouse it belongs to.
+func binWarehouseID(ctx context.Context, tx pgx.Tx, locationID int) (int, error) {
+	var warehouseID int
+	var locType string
+
+	err := tx.QueryRow(ctx, `SELECT warehouse_id, location_type FROM locations WHERE location_id = $1`, locationID).
+		Scan(&warehouseID, &locType)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: location %d", ErrNotFound, locationID)
+		}
+		return 0, fmt.Errorf("failed to get location %d: %w", locationID, err)
+	}
+	if locType != models.LocationBin {
+		return 0, fmt.Errorf("%w: stock can only be held in bins, location %d is a %s", ErrInvalidInput, locationID, locType)
+	}
+
+	return warehouseID, nil
+}
+
+func putToLocation(ctx context.Context, tx pgx.Tx, locationID, productID, quantity int) error {
+	upsert := `INSERT INTO location_stock (location_id, product_id, quantity, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (location_id, product_id) DO UPDATE SET
+			quantity = location_stock.quantity + EXCLUDED.quantity,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := tx.Exec(ctx, upsert, locationID, productID, quantity, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update location stock %d: %w", locationID, err)
+	}
+
+	return nil
+}
+
+func takeFromLocation(ctx context.Context, tx pgx.Tx, locationID, productID, quantity int) error {
+	update := `UPDATE location_stock SET
+		quantity = quantity - $1,
+		updated_at = $2
+		WHERE location_id = $3 AND product_id = $4 AND quantity >= $1
+	`
+
+	result, err := tx.Exec(ctx, update, quantity, time.Now(), locationID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to update location stock %d: %w", locationID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: location %d holds less than %d of product %d", ErrNotEnough, locationID, quantity, productID)
+	}
+
+	return nil
+}
+
+// unlocatedStock is the part of a product's warehouse stock not assigned to
+// any bin yet.
+func unlocatedStock(ctx context.Context, tx pgx.Tx, warehouseID, productID int) (int, error) {
+	sql := `SELECT
+		COALESCE((
+			SELECT quantity FROM warehouse_stock
+			WHERE warehouse_id = $1 AND product_id = $2
+		), 0)
+		- COALESCE((
+			SELECT SUM(ls.quantity) FROM location_stock ls
+			JOIN locations l ON l.location_id = ls.location_id
+			WHERE l.warehouse_id = $1 AND ls.product_id = $2
+		), 0)
+	`
+
+	var unlocated int
+	if err := tx.QueryRow(ctx, sql, warehouseID, productID).Scan(&unlocated); err != nil {
+		return 0, fmt.Errorf("failed to get unlocated stock of product %d: %w", productID, err)
+	}
+
+	return unlocated, nil
+}
+
+type locationPick struct {
+	locationID *int
+	quantity   int
+}
+
+// pickFromLocations decides where an outgoing quantity physically leaves
+// the warehouse: stock that has no bin goes first, then bins in code order.
+// Bin stock is deducted here; the warehouse total is left to adjustStock.
+func pickFromLocations(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int) ([]locationPick, error) {
+	unlocated, err := unlocatedStock(ctx, tx, warehouseID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var picks []locationPick
+
+	if unlocated > 0 {
+		take := min(unlocated, quantity)
+		picks = append(picks, locationPick{quantity: take})
+		quantity -= take
+	}
+	if quantity == 0 {
+		return picks, nil
+	}
+
+	sql := `SELECT ls.location_id, ls.quantity
+		FROM location_stock ls
+		JOIN locations l ON l.location_id = ls.location_id
+		WHERE l.warehouse_id = $1 AND ls.product_id = $2 AND ls.quantity > 0
+		ORDER BY l.code
+		FOR UPDATE OF ls
+	`
+
+	rows, err := tx.Query(ctx, sql, warehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bins for product %d: %w", productID, err)
+	}
+
+	var bins []models.LocationStock
+	for rows.Next() {
+		var s models.LocationStock
+		if err := rows.Scan(&s.LocationID, &s.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan location stock: %w", err)
+		}
+		bins = append(bins, s)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for _, bin := range bins {
+		if quantity == 0 {
+			break
+		}
+
+		take := min(bin.Quantity, quantity)
+		if err := takeFromLocation(ctx, tx, bin.LocationID, productID, take); err != nil {
+			return nil, err
+		}
+
+		locationID := bin.LocationID
+		picks = append(picks, locationPick{locationID: &locationID, quantity: take})
+		quantity -= take
+	}
+
+	if quantity > 0 {
+		return nil, fmt.Errorf("%w: not enough in stock %d", ErrNotEnough, productID)
+	}
+
+	return picks, nil
+}
@@ -0,0 +1,459 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type lotRepo struct {
+	db *pgx.Conn
+}
+
+func NewLotRepository(db *pgx.Conn) LotRepository {
+	return &lotRepo{db: db}
+}
+
+// Receive books an incoming delivery in its own transaction.
+func (r *lotRepo) Receive(ctx context.Context, rc *models.Receipt) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := receiveStock(ctx, tx, rc); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// receiveStock creates or appends to the receipt's lot, registers its
+// serial numbers, adds the stock to the warehouse (and bin, if given) and
+// writes the incoming operation, or transfer_in for the arriving leg of a
+// transfer. Returned serialized units are put back in stock, and units on
+// a transfer booked in at the destination, rather than registered.
+// rc.LotID and rc.OperationID are filled in.
+func receiveStock(ctx context.Context, tx pgx.Tx, rc *models.Receipt) error {
+	if rc == nil {
+		return fmt.Errorf("%w: receipt cannot be nil", ErrInvalidInput)
+	}
+	if rc.ProductID <= 0 {
+		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	if rc.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	rc.LotNumber = strings.TrimSpace(rc.LotNumber)
+	if rc.LotNumber == "" && (rc.ExpiresAt != nil || rc.ManufacturedAt != nil) {
+		return fmt.Errorf("%w: lot dates given without a lot number", ErrInvalidInput)
+	}
+	if rc.ExpiresAt != nil && rc.ManufacturedAt != nil && !rc.ExpiresAt.After(*rc.ManufacturedAt) {
+		return fmt.Errorf("%w: expiry date must be after manufacture date", ErrInvalidInput)
+	}
+
+	var serialized bool
+	err := tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1 FOR UPDATE`, rc.ProductID).Scan(&serialized)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to lock product %d: %w", rc.ProductID, err)
+	}
+
+	if err := toBaseQuantity(ctx, tx, rc.ProductID, &rc.Unit, rc.UnitQuantity, &rc.Quantity); err != nil {
+		return err
+	}
+	if rc.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+	}
+
+	if err := checkReceiptSerials(rc, serialized); err != nil {
+		return err
+	}
+
+	if rc.ToLocationID != nil {
+		binWarehouse, err := binWarehouseID(ctx, tx, *rc.ToLocationID)
+		if err != nil {
+			return err
+		}
+		if binWarehouse != rc.WarehouseID {
+			return fmt.Errorf("%w: location %d is not in warehouse %d", ErrInvalidInput, *rc.ToLocationID, rc.WarehouseID)
+		}
+	}
+
+	rc.LotID = nil
+	if rc.LotNumber != "" {
+		lot, err := receiveLot(ctx, tx, rc)
+		if err != nil {
+			return err
+		}
+		rc.LotID = &lot.LotID
+	}
+
+	if err := adjustStock(ctx, tx, rc.WarehouseID, rc.ProductID, rc.Quantity); err != nil {
+		return err
+	}
+
+	if rc.ToLocationID != nil {
+		if err := putToLocation(ctx, tx, *rc.ToLocationID, rc.ProductID, rc.Quantity); err != nil {
+			return err
+		}
+	}
+
+	op := models.Operation{
+		ProductID:     rc.ProductID,
+		WarehouseID:   rc.WarehouseID,
+		OperationType: "incoming",
+		ChangeQuant:   rc.Quantity,
+		ToLocationID:  rc.ToLocationID,
+		LotID:         rc.LotID,
+		Unit:          rc.Unit,
+		UnitQuant:     rc.UnitQuantity,
+		POLineID:      rc.POLineID,
+		TransferID:    rc.TransferID,
+		OrderID:       rc.OrderID,
+		UnitCost:      rc.UnitCost,
+	}
+	if rc.TransferID != nil {
+		op.OperationType = "transfer_in"
+	}
+	if err := costOperation(ctx, tx, &op); err != nil {
+		return err
+	}
+	rc.UnitCost = op.UnitCost
+	if err := insertOperation(ctx, tx, &op); err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+	rc.OperationID = op.OperationID
+
+	if serialized {
+		switch {
+		case rc.OrderID != nil:
+			err = restockSerials(ctx, tx, rc)
+		case rc.TransferID != nil:
+			err = arriveSerials(ctx, tx, rc)
+		default:
+			err = registerSerials(ctx, tx, rc)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func receiveLot(ctx context.Context, tx pgx.Tx, rc *models.Receipt) (*models.Lot, error) {
+	sql := `SELECT
+		lot_id,
+		product_id,
+		warehouse_id,
+		lot_number,
+		manufactured_at,
+		expires_at,
+		quantity,
+		created_at
+		FROM lots
+		WHERE product_id = $1 AND warehouse_id = $2 AND lot_number = $3
+		FOR UPDATE
+	`
+
+	var lot models.Lot
+
+	err := tx.QueryRow(ctx, sql, rc.ProductID, rc.WarehouseID, rc.LotNumber).Scan(
+		&lot.LotID,
+		&lot.ProductID,
+		&lot.WarehouseID,
+		&lot.LotNumber,
+		&lot.ManufacturedAt,
+		&lot.ExpiresAt,
+		&lot.Quantity,
+		&lot.CreatedAt,
+	)
+	switch {
+	case err == nil:
+		if rc.ExpiresAt != nil && (lot.ExpiresAt == nil || !sameDay(*lot.ExpiresAt, *rc.ExpiresAt)) {
+			return nil, fmt.Errorf("%w: lot %s already exists with a different expiry date", ErrInvalidInput, rc.LotNumber)
+		}
+
+		err = tx.QueryRow(ctx, `UPDATE lots SET quantity = quantity + $1 WHERE lot_id = $2 RETURNING quantity`,
+			rc.Quantity, lot.LotID).Scan(&lot.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update lot %d: %w", lot.LotID, err)
+		}
+
+	case errors.Is(err, pgx.ErrNoRows):
+		insert := `INSERT INTO lots (
+			product_id,
+			warehouse_id,
+			lot_number,
+			manufactured_at,
+			expires_at,
+			quantity,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING lot_id
+		`
+
+		lot = models.Lot{
+			ProductID:      rc.ProductID,
+			WarehouseID:    rc.WarehouseID,
+			LotNumber:      rc.LotNumber,
+			ManufacturedAt: rc.ManufacturedAt,
+			ExpiresAt:      rc.ExpiresAt,
+			Quantity:       rc.Quantity,
+			CreatedAt:      time.Now(),
+		}
+
+		err = tx.QueryRow(ctx, insert,
+			lot.ProductID,
+			lot.WarehouseID,
+			lot.LotNumber,
+			lot.ManufacturedAt,
+			lot.ExpiresAt,
+			lot.Quantity,
+			lot.CreatedAt,
+		).Scan(&lot.LotID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lot: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("failed to get lot %s: %w", rc.LotNumber, err)
+	}
+
+	return &lot, nil
+}
+
+func (r *lotRepo) GetByID(ctx context.Context, id int) (*models.Lot, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		lot_id,
+		product_id,
+		warehouse_id,
+		lot_number,
+		manufactured_at,
+		expires_at,
+		quantity,
+		created_at
+		FROM lots
+		WHERE lot_id = $1
+	`
+
+	var lot models.Lot
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&lot.LotID,
+		&lot.ProductID,
+		&lot.WarehouseID,
+		&lot.LotNumber,
+		&lot.ManufacturedAt,
+		&lot.ExpiresAt,
+		&lot.Quantity,
+		&lot.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get lot %d: %w", id, err)
+	}
+
+	return &lot, nil
+}
+
+// GetByProductID lists the lots still holding stock, in the order they
+// would be allocated to orders.
+func (r *lotRepo) GetByProductID(ctx context.Context, productID int) ([]models.Lot, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		lot_id,
+		product_id,
+		warehouse_id,
+		lot_number,
+		manufactured_at,
+		expires_at,
+		quantity,
+		created_at
+		FROM lots
+		WHERE product_id = $1 AND quantity > 0
+		ORDER BY warehouse_id, expires_at NULLS LAST, lot_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lots by product ID %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	return scanLots(rows)
+}
+
+// GetExpiring lists lots with stock that expire on or before the given time,
+// including ones that already have.
+func (r *lotRepo) GetExpiring(ctx context.Context, before time.Time) ([]models.Lot, error) {
+	sql := `SELECT
+		lot_id,
+		product_id,
+		warehouse_id,
+		lot_number,
+		manufactured_at,
+		expires_at,
+		quantity,
+		created_at
+		FROM lots
+		WHERE quantity > 0 AND expires_at <= $1
+		ORDER BY expires_at, lot_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expiring lots: %w", err)
+	}
+
+	defer rows.Close()
+
+	return scanLots(rows)
+}
+
+func scanLots(rows pgx.Rows) ([]models.Lot, error) {
+	var lots []models.Lot
+
+	for rows.Next() {
+		var lot models.Lot
+
+		err := rows.Scan(&lot.LotID,
+			&lot.ProductID,
+			&lot.WarehouseID,
+			&lot.LotNumber,
+			&lot.ManufacturedAt,
+			&lot.ExpiresAt,
+			&lot.Quantity,
+			&lot.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lots: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lots, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Format(time.DateOnly) == b.Format(time.DateOnly)
+}
+
+type lotDraw struct {
+	lotID    *int
+	quantity int
+}
+
+// allocateLots takes an outgoing quantity first-expired-first-out. Expired
+// lots are never used; stock received before lots were tracked is used
+// after every dated lot. Lot quantities are deducted here.
+func allocateLots(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int) ([]lotDraw, error) {
+	return drawLots(ctx, tx, warehouseID, productID, quantity, false)
+}
+
+// drawLots deducts a quantity from lots first-expired-first-out, then from
+// stock without a lot. Expired lots are skipped unless withExpired is set,
+// as for stock that has gone missing rather than been sold.
+func drawLots(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int, withExpired bool) ([]lotDraw, error) {
+	sql := `SELECT lot_id, quantity, expires_at
+		FROM lots
+		WHERE warehouse_id = $1 AND product_id = $2 AND quantity > 0
+		ORDER BY expires_at NULLS LAST, lot_id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, sql, warehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lots for product %d: %w", productID, err)
+	}
+
+	var lots []models.Lot
+	for rows.Next() {
+		var lot models.Lot
+		if err := rows.Scan(&lot.LotID, &lot.Quantity, &lot.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan lots: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	var onHand int
+	err = tx.QueryRow(ctx, `SELECT COALESCE((
+		SELECT quantity FROM warehouse_stock WHERE warehouse_id = $1 AND product_id = $2
+	), 0)`, warehouseID, productID).Scan(&onHand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warehouse stock of product %d: %w", productID, err)
+	}
+
+	untracked := onHand
+	for _, lot := range lots {
+		untracked -= lot.Quantity
+	}
+
+	var draws []lotDraw
+	now := time.Now()
+
+	for _, lot := range lots {
+		if quantity == 0 {
+			break
+		}
+		if lot.Expired(now) && !withExpired {
+			continue
+		}
+
+		take := min(lot.Quantity, quantity)
+
+		_, err := tx.Exec(ctx, `UPDATE lots SET quantity = quantity - $1 WHERE lot_id = $2`, take, lot.LotID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update lot %d: %w", lot.LotID, err)
+		}
+
+		lotID := lot.LotID
+		draws = append(draws, lotDraw{lotID: &lotID, quantity: take})
+		quantity -= take
+	}
+
+	if quantity > 0 && untracked > 0 {
+		take := min(untracked, quantity)
+		draws = append(draws, lotDraw{quantity: take})
+		quantity -= take
+	}
+
+	if quantity > 0 {
+		return nil, fmt.Errorf("%w: not enough unexpired stock %d", ErrNotEnough, productID)
+	}
+
+	return draws, nil
+}
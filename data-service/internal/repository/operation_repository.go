@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"data-service/internal/models"
+	"errors"
 	"fmt"
 	"time"
 
@@ -17,57 +18,228 @@ func NewOperationRepository(db *pgx.Conn) OperationRepository {
 	return &operationRepo{db: db}
 }
 
+// Create books a stock movement that has no document of its own. Incoming
+// operations add stock, outgoing ones take it away and adjustments go
+// either way; the stock, its bins and lots change in the same transaction,
+// so the ledger cannot drift from them. Reserve and release rows belong to
+// reservations, and moves to bins, so neither is created here. A decrease
+// that leaves several bins or lots is written as one operation per bin and
+// lot, and o is filled in from the first.
 func (r *operationRepo) Create(ctx context.Context, o *models.Operation) error {
-	var orderID interface{}
-	if o.OrderID != nil && *o.OrderID > 0 {
-		orderID = *o.OrderID
-	} else {
-		orderID = nil
-	}
 	if o == nil {
 		return fmt.Errorf("%w: operation cannot be nil", ErrInvalidInput)
 	}
 	if o.ProductID <= 0 {
 		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
 	}
+	if o.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if err := toBaseQuantity(ctx, r.db, o.ProductID, &o.Unit, o.UnitQuant, &o.ChangeQuant); err != nil {
+		return err
+	}
 	if o.ChangeQuant == 0 {
 		return fmt.Errorf("%w: the variable quantity cannot be 0", ErrInvalidInput)
 	}
-	validStatuses := map[string]bool{
-		"incoming":   true,
-		"outgoing":   true,
-		"adjustment": true,
-		"reserve":    true,
+
+	switch o.OperationType {
+	case "incoming":
+		if o.ChangeQuant < 0 {
+			return fmt.Errorf("%w: incoming quantity must be positive", ErrInvalidInput)
+		}
+	case "outgoing":
+		if o.ChangeQuant > 0 {
+			return fmt.Errorf("%w: outgoing quantity must be negative", ErrInvalidInput)
+		}
+	case "adjustment":
+	default:
+		return fmt.Errorf("%w: invalid operation type '%s'", ErrInvalidInput, o.OperationType)
+	}
+	if o.ReservationID != nil || o.FromLocationID != nil || o.ToLocationID != nil || o.LotID != nil {
+		return fmt.Errorf("%w: reservations, bins and lots are changed through their own endpoints", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var serialized bool
+	err = tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1 FOR UPDATE`, o.ProductID).Scan(&serialized)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to get product %d: %w", o.ProductID, err)
+	}
+	if serialized {
+		return fmt.Errorf("%w: stock of serialized product %d changes only with serial numbers", ErrInvalidInput, o.ProductID)
+	}
+
+	if o.ChangeQuant > 0 {
+		err = bookIncrease(ctx, tx, o)
+	} else {
+		err = bookDecrease(ctx, tx, o)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// bookIncrease adds the stock of an incoming operation the way goods are
+// received, and of an adjustment the way found stock is counted in.
+func bookIncrease(ctx context.Context, tx pgx.Tx, o *models.Operation) error {
+	if o.OperationType == "adjustment" {
+		ops, err := postCountVariance(ctx, tx, o.WarehouseID, o.ProductID, nil, o.ChangeQuant)
+		if err != nil {
+			return err
+		}
+		fillFromBooked(o, ops)
+		return nil
+	}
+
+	rc := models.Receipt{
+		ProductID:    o.ProductID,
+		WarehouseID:  o.WarehouseID,
+		Quantity:     o.ChangeQuant,
+		Unit:         o.Unit,
+		UnitQuantity: o.UnitQuant,
+		OrderID:      o.OrderID,
+		UnitCost:     o.UnitCost,
+	}
+	if err := receiveStock(ctx, tx, &rc); err != nil {
+		return err
 	}
-	if !validStatuses[o.OperationType] {
-		return fmt.Errorf("%w: invalid status '%s'", ErrInvalidInput, o.OperationType)
+
+	o.OperationID = rc.OperationID
+	o.LotID = rc.LotID
+	o.UnitCost = rc.UnitCost
+
+	return nil
+}
+
+// bookDecrease takes the stock of an outgoing operation the way an order
+// line leaves, and of an adjustment the way counted shrinkage does, which
+// may write off expired lots. Stock held by reservations or committed to
+// open orders is never taken.
+func bookDecrease(ctx context.Context, tx pgx.Tx, o *models.Operation) error {
+	if err := checkFreeStock(ctx, tx, o.WarehouseID, o.ProductID, -o.ChangeQuant, o.OperationType == "adjustment"); err != nil {
+		return err
+	}
+
+	if o.OperationType == "adjustment" {
+		ops, err := postCountVariance(ctx, tx, o.WarehouseID, o.ProductID, nil, o.ChangeQuant)
+		if err != nil {
+			return err
+		}
+		fillFromBooked(o, ops)
+		return nil
+	}
+
+	picks, err := pickFromLocations(ctx, tx, o.WarehouseID, o.ProductID, -o.ChangeQuant)
+	if err != nil {
+		return err
+	}
+
+	draws, err := allocateLots(ctx, tx, o.WarehouseID, o.ProductID, -o.ChangeQuant)
+	if err != nil {
+		return err
+	}
+
+	if err := adjustStock(ctx, tx, o.WarehouseID, o.ProductID, o.ChangeQuant); err != nil {
+		return err
 	}
 
+	ops, err := insertOutgoing(ctx, tx, o.WarehouseID, o.ProductID, picks, draws, models.Operation{
+		OrderID:       o.OrderID,
+		OperationType: o.OperationType,
+	})
+	if err != nil {
+		return err
+	}
+	fillFromBooked(o, ops)
+
+	return nil
+}
+
+// fillFromBooked fills in an operation from the first of the ledger rows it
+// was booked as.
+func fillFromBooked(o *models.Operation, ops []models.Operation) {
+	if len(ops) == 0 {
+		return
+	}
+
+	o.OperationID = ops[0].OperationID
+	o.FromLocationID = ops[0].FromLocationID
+	o.ToLocationID = ops[0].ToLocationID
+	o.LotID = ops[0].LotID
+	o.UnitCost = ops[0].UnitCost
+	o.CostAmount = ops[0].CostAmount
+	o.CreatedAt = ops[0].CreatedAt
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertOperation writes a ledger row through either the connection or an
+// open transaction, so repositories can log operations next to the stock
+// change they describe.
+func insertOperation(ctx context.Context, q rowQuerier, o *models.Operation) error {
 	sql := ` INSERT INTO operations (
 		product_id,
+		warehouse_id,
 		order_id,
 		operation_type,
 		change_quant,
+		reservation_id,
+		from_location_id,
+		to_location_id,
+		lot_id,
+		unit,
+		unit_quant,
+		po_line_id,
+		transfer_id,
+		unit_cost,
+		cost_amount,
 		created_at
-		) VALUES ($1, $2, $3, $4, $5)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING operation_id
 	`
 
-	now := time.Now()
-	o.CreatedAt = now
+	o.CreatedAt = time.Now()
+	unit, unitQuant := nullableUnit(o.Unit, o.UnitQuant)
 
-	err := r.db.QueryRow(ctx, sql,
+	return q.QueryRow(ctx, sql,
 		o.ProductID,
-		orderID,
+		o.WarehouseID,
+		nullableID(o.OrderID),
 		o.OperationType,
 		o.ChangeQuant,
+		nullableID(o.ReservationID),
+		nullableID(o.FromLocationID),
+		nullableID(o.ToLocationID),
+		nullableID(o.LotID),
+		unit,
+		unitQuant,
+		nullableID(o.POLineID),
+		nullableID(o.TransferID),
+		o.UnitCost,
+		o.CostAmount,
 		o.CreatedAt,
 	).Scan(&o.OperationID)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return ErrNotFound
-		}
-		return fmt.Errorf("failed to create operation: %w", err)
+}
+
+func nullableID(id *int) interface{} {
+	if id != nil && *id > 0 {
+		return *id
 	}
 	return nil
 }
@@ -79,9 +251,20 @@ func (r *operationRepo) GetByProductID(ctx context.Context, productID int) ([]mo
 
 	sql := `SELECT 
 		product_id,
+		warehouse_id,
 		order_id,
 		operation_type,
 		change_quant,
+		reservation_id,
+		from_location_id,
+		to_location_id,
+		lot_id,
+		COALESCE(unit, ''),
+		COALESCE(unit_quant, 0),
+		po_line_id,
+		transfer_id,
+		unit_cost,
+		cost_amount,
 		created_at
 		FROM operations
 		WHERE product_id = $1
@@ -99,9 +282,20 @@ func (r *operationRepo) GetByProductID(ctx context.Context, productID int) ([]mo
 		var o models.Operation
 
 		err := rows.Scan(&o.ProductID,
+			&o.WarehouseID,
 			&o.OrderID,
 			&o.OperationType,
 			&o.ChangeQuant,
+			&o.ReservationID,
+			&o.FromLocationID,
+			&o.ToLocationID,
+			&o.LotID,
+			&o.Unit,
+			&o.UnitQuant,
+			&o.POLineID,
+			&o.TransferID,
+			&o.UnitCost,
+			&o.CostAmount,
 			&o.CreatedAt,
 		)
 		if err != nil {
@@ -125,9 +319,20 @@ func (r *operationRepo) GetByOrderID(ctx context.Context, orderID int) ([]models
 
 	sql := ` SELECT
 		product_id,
+		warehouse_id,
 		order_id,
 		operation_type,
 		change_quant,
+		reservation_id,
+		from_location_id,
+		to_location_id,
+		lot_id,
+		COALESCE(unit, ''),
+		COALESCE(unit_quant, 0),
+		po_line_id,
+		transfer_id,
+		unit_cost,
+		cost_amount,
 		created_at
 		FROM operations
 		WHERE order_id = $1
@@ -146,9 +351,20 @@ func (r *operationRepo) GetByOrderID(ctx context.Context, orderID int) ([]models
 		var o models.Operation
 
 		err := rows.Scan(&o.ProductID,
+			&o.WarehouseID,
 			&o.OrderID,
 			&o.OperationType,
 			&o.ChangeQuant,
+			&o.ReservationID,
+			&o.FromLocationID,
+			&o.ToLocationID,
+			&o.LotID,
+			&o.Unit,
+			&o.UnitQuant,
+			&o.POLineID,
+			&o.TransferID,
+			&o.UnitCost,
+			&o.CostAmount,
 			&o.CreatedAt,
 		)
 		if err != nil {
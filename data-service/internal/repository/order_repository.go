@@ -5,48 +5,90 @@ import (
 	"data-service/internal/models"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-type orderRepo struct {
-	db *pgx.Conn
+// OrderSettings are the per-deployment choices orders are placed under.
+// Each order keeps the ones it was placed with.
+type OrderSettings struct {
+	StockTiming      string
+	PricesIncludeTax bool
+	TaxJurisdiction  string
+	BaseCurrency     string
 }
 
-func NewOrderRepository(db *pgx.Conn) OrderRepository {
-	return &orderRepo{db: db}
+type orderRepo struct {
+	db       *pgx.Conn
+	settings OrderSettings
 }
 
-type productStock struct {
-	price    float64
-	quantity int
+// NewOrderRepository takes stock out of the warehouse when an order is
+// placed, or, with StockTiming set to models.StockOnShipment, only when its
+// shipments are confirmed. Orders that name no tax jurisdiction are taxed
+// in the settings' one, and orders that name no currency are in the base
+// currency, which catalogue prices are in.
+func NewOrderRepository(db *pgx.Conn, settings OrderSettings) OrderRepository {
+	if settings.StockTiming != models.StockOnShipment {
+		settings.StockTiming = models.StockOnOrder
+	}
+	settings.TaxJurisdiction = normalizeJurisdiction(settings.TaxJurisdiction)
+	settings.BaseCurrency = normalizeCurrency(settings.BaseCurrency)
+	if settings.BaseCurrency == "" {
+		settings.BaseCurrency = models.DefaultBaseCurrency
+	}
+	return &orderRepo{db: db, settings: settings}
 }
 
+// CreateOrder prices an order and places it, or, with order.Status set to
+// models.OrderDraft, keeps it as a draft that holds no stock except what
+// is reserved for it until it is placed through UpdateStatus.
 func (r *orderRepo) CreateOrder(ctx context.Context, order *models.Order, items []models.OrderItem) error {
 	if order == nil {
 		return fmt.Errorf("%w: order cannot be nil", ErrInvalidInput)
 	}
 
+	switch order.Status {
+	case "", models.OrderCreated:
+		order.Status = models.OrderCreated
+	case models.OrderDraft:
+	default:
+		return fmt.Errorf("%w: orders start out as %s or %s", ErrInvalidInput, models.OrderCreated, models.OrderDraft)
+	}
+
 	if order.CustomerID <= 0 {
 		return fmt.Errorf("%w: ID must be positive", ErrInvalidInput)
 	}
 
+	if order.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+
 	if len(items) == 0 {
 		return fmt.Errorf("slice items cannot be empty: %w", ErrInvalidInput)
 	}
 
 	for _, item := range items {
-		if item.Quantity <= 0 {
+		if item.Quantity < 0 || item.UnitQuantity < 0 || item.Quantity+item.UnitQuantity == 0 {
 			return fmt.Errorf("quantity must be positive: %w", ErrInvalidInput)
 		}
-		if item.Price <= 0 {
-			return fmt.Errorf("Price must be positive: %w", ErrInvalidInput)
-		}
 		if item.ProductID <= 0 {
 			return fmt.Errorf("Product ID cannot be empty: %w", ErrInvalidInput)
 		}
+		if o := item.Override; o != nil {
+			o.Reason = strings.TrimSpace(o.Reason)
+			if o.Price <= 0 {
+				return fmt.Errorf("%w: override price must be positive", ErrInvalidInput)
+			}
+			if o.Reason == "" || o.AuthorizedBy <= 0 {
+				return fmt.Errorf("%w: a price override needs a reason and the manager who authorized it", ErrInvalidInput)
+			}
+		}
 	}
 
 	tx, err := r.db.Begin(ctx)
@@ -70,123 +112,530 @@ func (r *orderRepo) CreateOrder(ctx context.Context, order *models.Order, items
 		return fmt.Errorf("failed to get customer by id: %w", err)
 	}
 
-	prosuctsIDs := []int{}
+	order.Currency = normalizeCurrency(order.Currency)
+	if order.Currency == "" {
+		order.Currency = r.settings.BaseCurrency
+	}
+	order.ExchangeRate, err = exchangeRate(ctx, tx, order.Currency, r.settings.BaseCurrency)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		if err := toBaseQuantity(ctx, tx, item.ProductID, &item.Unit, item.UnitQuantity, &item.Quantity); err != nil {
+			return err
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be positive: %w", ErrInvalidInput)
+		}
+	}
+
+	itemProducts := []int{}
 	for _, item := range items {
-		prosuctsIDs = append(prosuctsIDs, item.ProductID)
+		itemProducts = append(itemProducts, item.ProductID)
+	}
+
+	bundles, err := bundleComponents(ctx, tx, itemProducts)
+	if err != nil {
+		return err
+	}
+
+	requested := stockedQuantities(items, bundles)
+
+	// Lines are priced from the catalogue, so bundles are looked up next to
+	// the components they are stocked as.
+	lookupIDs := []int{}
+	for productID := range requested {
+		lookupIDs = append(lookupIDs, productID)
+	}
+	for _, productID := range itemProducts {
+		if _, ok := requested[productID]; !ok {
+			lookupIDs = append(lookupIDs, productID)
+		}
 	}
 
 	sql2 := ` SELECT
-	product_id,
-	price,
-	quantity
-	FROM products WHERE product_id = ANY($1::int[])
+	p.product_id,
+	p.price
+	FROM products p
+	WHERE p.product_id = ANY($1::int[])
+	FOR UPDATE OF p
 	`
 
 	var rows pgx.Rows
-	rows, err = tx.Query(ctx, sql2, prosuctsIDs)
+	rows, err = tx.Query(ctx, sql2, lookupIDs)
 	if err != nil {
 		return fmt.Errorf("failed to get products information: %w", err)
 	}
 
 	defer rows.Close()
 
-	productInfo := make(map[int]struct {
-		price    float64
-		quantity int
-	})
+	prices := make(map[int]models.Money)
 
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ProductID,
-			&p.Price,
-			&p.Quantity,
-		)
-		if err != nil {
+		if err := rows.Scan(&p.ProductID, &p.Price); err != nil {
 			return fmt.Errorf("failed to scan product data: %w", err)
 		}
-
-		productInfo[p.ProductID] = productStock{
-			price:    p.Price,
-			quantity: p.Quantity,
-		}
+		prices[p.ProductID] = p.Price
 	}
 
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("failed to complete row iteration: %w", err)
 	}
 
+	for productID := range requested {
+		if _, exist := prices[productID]; !exist {
+			return fmt.Errorf("product not found: %w", ErrNotFound)
+		}
+	}
+
+	if order.Status != models.OrderDraft {
+		if err := checkOrderStock(ctx, tx, order, requested); err != nil {
+			return err
+		}
+	}
+
+	// Quantity breaks count all of a product's units on the order.
+	ordered := make(map[int]int)
 	for _, item := range items {
-		info, exist := productInfo[item.ProductID]
+		ordered[item.ProductID] += item.Quantity
+	}
+
+	negotiated, err := negotiatedPrices(ctx, tx, order.CustomerID, ordered)
+	if err != nil {
+		return err
+	}
+
+	// Catalogue and price list prices are converted into the order's
+	// currency; overrides are given in it.
+	var total models.Money
+	for i := range items {
+		item := &items[i]
+
+		price, exist := prices[item.ProductID]
 		if !exist {
 			return fmt.Errorf("product not found: %w", ErrNotFound)
 		}
-		if info.quantity < item.Quantity {
-			return fmt.Errorf("%w: not enough in stock %d", ErrInvalidInput, item.ProductID)
+
+		item.ListPrice = fromBase(price, order)
+		item.PriceListID = nil
+		if item.Override != nil {
+			item.Price = item.Override.Price
+		} else if p, ok := negotiated[item.ProductID]; ok {
+			item.Price = fromBase(p.price, order)
+			item.PriceListID = &p.priceListID
+		} else {
+			if price <= 0 {
+				return fmt.Errorf("%w: product %d has no price", ErrInvalidInput, item.ProductID)
+			}
+			item.Price = item.ListPrice
 		}
+
+		total += item.Price.Mul(item.Quantity)
 	}
 
-	var total float64
-	for _, item := range items {
-		total += item.Price * float64(item.Quantity)
+	order.PromoCode = normalizePromoCode(order.PromoCode)
+	discounts, err := applyPromotions(ctx, tx, order, items, total)
+	if err != nil {
+		return err
+	}
+
+	order.DiscountAmount = 0
+	for _, d := range discounts {
+		order.DiscountAmount += d.discount.Amount
+	}
+
+	order.TaxJurisdiction = normalizeJurisdiction(order.TaxJurisdiction)
+	if order.TaxJurisdiction == "" {
+		order.TaxJurisdiction = r.settings.TaxJurisdiction
+	}
+	order.PricesIncludeTax = r.settings.PricesIncludeTax
+	if err := applyTax(ctx, tx, order, items, discounts); err != nil {
+		return err
 	}
-	order.TotalAmount = total
 
 	insert := `INSERT INTO orders (
 	customer_id,
+	warehouse_id,
 	total_amount,
 	status,
+	stock_timing,
+	carrier,
+	ship_by,
+	discount_amount,
+	promo_code,
+	tax_jurisdiction,
+	prices_include_tax,
+	net_amount,
+	tax_amount,
+	currency,
+	exchange_rate,
 	created_at
-	) VALUES ($1, $2, $3, $4)
-	RETURNING order_id
+	) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13, $14, $15, $16)
+	RETURNING order_id, status, stock_timing, created_at
 	`
 
-	err = tx.QueryRow(ctx, insert, order.CustomerID, order.TotalAmount, "created", time.Now()).Scan(&order.OrderID)
+	order.Carrier = strings.TrimSpace(order.Carrier)
+	order.NeedsAttention = false
+
+	err = tx.QueryRow(ctx, insert, order.CustomerID, order.WarehouseID, order.TotalAmount, order.Status, r.settings.StockTiming, order.Carrier, order.ShipBy, order.DiscountAmount, order.PromoCode, order.TaxJurisdiction, order.PricesIncludeTax, order.NetAmount, order.TaxAmount, order.Currency, order.ExchangeRate, time.Now()).Scan(&order.OrderID, &order.Status, &order.StockTiming, &order.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
 	}
 
-	for _, item := range items {
-		insertItemSQL := `INSERT INTO order_items (order_id, product_id, quantity, price)
-		VALUES ($1, $2, $3, $4)
+	if err := recordStatusChange(ctx, tx, order.OrderID, "", order.Status, ""); err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		item.OrderID = order.OrderID
+		item.QuantityShipped = 0
+
+		insertItemSQL := `INSERT INTO order_items (order_id, product_id, quantity, price, list_price, price_list_id, override_reason, override_by, unit, unit_quantity,
+			net_amount, tax_rate, tax_amount, gross_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING order_item_id
 	`
-		_, err = tx.Exec(ctx, insertItemSQL, order.OrderID, item.ProductID, item.Quantity, item.Price)
+		var overrideReason *string
+		var overrideBy *int
+		if item.Override != nil {
+			overrideReason, overrideBy = &item.Override.Reason, &item.Override.AuthorizedBy
+		}
+		unit, unitQuantity := nullableUnit(item.Unit, item.UnitQuantity)
+		err = tx.QueryRow(ctx, insertItemSQL, order.OrderID, item.ProductID, item.Quantity, item.Price, item.ListPrice, nullableID(item.PriceListID), overrideReason, overrideBy, unit, unitQuantity,
+			item.NetAmount, item.TaxRate, item.TaxAmount, item.GrossAmount).Scan(&item.OrderItemID)
 		if err != nil {
 			return fmt.Errorf("failed to create order item: %w", err)
 		}
+	}
 
-		update := ` UPDATE products SET quantity = quantity - $1 WHERE product_id = $2`
+	if err := insertOrderDiscounts(ctx, tx, order, items, discounts); err != nil {
+		return err
+	}
 
-		result, err := tx.Exec(ctx, update, item.Quantity, item.ProductID)
-		if err != nil {
-			return fmt.Errorf("failed to update products %d: %w", item.ProductID, err)
+	if order.Status != models.OrderDraft {
+		if err := placeOrder(ctx, tx, order, items, bundles, requested); err != nil {
+			return err
 		}
+	}
 
-		rowsAffected := result.RowsAffected()
-		if rowsAffected == 0 {
-			return ErrNotFound
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// stockedQuantities is how much of each stocked product an order's lines
+// take. Bundles carry no stock, so their lines count as the components
+// they are made of.
+func stockedQuantities(items []models.OrderItem, bundles map[int][]models.BundleComponent) map[int]int {
+	requested := make(map[int]int)
+	for _, item := range items {
+		if components, ok := bundles[item.ProductID]; ok {
+			for _, c := range components {
+				requested[c.ProductID] += item.Quantity * c.Quantity
+			}
+			continue
+		}
+		requested[item.ProductID] += item.Quantity
+	}
+
+	return requested
+}
+
+// checkOrderStock locks the stocked products of an order and checks its
+// warehouse has what the order requests of each. Stock held by
+// reservations is not available to the order, except the customer's own
+// ones that are not held for another draft order; nor is stock promised to
+// open orders that have not shipped yet, or stock in expired lots.
+func checkOrderStock(ctx context.Context, tx pgx.Tx, order *models.Order, requested map[int]int) error {
+	productIDs := make([]int, 0, len(requested))
+	for productID := range requested {
+		productIDs = append(productIDs, productID)
+	}
+
+	if err := lockProducts(ctx, tx, productIDs); err != nil {
+		return err
+	}
+
+	available, err := availableStock(ctx, tx, order.WarehouseID, productIDs, order.CustomerID, order.OrderID)
+	if err != nil {
+		return err
+	}
+
+	for productID, quantity := range requested {
+		stock, exist := available[productID]
+		if !exist {
+			return fmt.Errorf("product not found: %w", ErrNotFound)
 		}
+		if stock.available() < quantity {
+			return fmt.Errorf("%w: not enough in stock %d", ErrNotEnough, productID)
+		}
+	}
 
-		insertOperationSQL := ` INSERT INTO operations (
-			product_id,
-			order_id,
-			operation_type,
-			change_quant,
-			created_at
-		) VALUES ($1, $2, $3, $4, $5)
-			`
-		_, err = tx.Exec(ctx, insertOperationSQL, item.ProductID, order.OrderID, "outgoing", -item.Quantity, time.Now())
-		if err != nil {
-			return fmt.Errorf("failed to create operation: %w", err)
+	return nil
+}
+
+// placeOrder takes the stock of an order's lines, or, if it takes its stock
+// on shipment, leaves the lines to hold it until they ship, and converts
+// the customer's reservations the order uses. The stock must have been
+// checked with checkOrderStock.
+func placeOrder(ctx context.Context, tx pgx.Tx, order *models.Order, items []models.OrderItem, bundles map[int][]models.BundleComponent, requested map[int]int) error {
+	if order.StockTiming == models.StockOnOrder {
+		for i := range items {
+			item := &items[i]
+
+			var err error
+			if components, ok := bundles[item.ProductID]; ok {
+				err = dispatchBundle(ctx, tx, order.WarehouseID, item, components)
+			} else {
+				err = dispatchItem(ctx, tx, order.WarehouseID, item)
+			}
+			if err != nil {
+				return err
+			}
 		}
+	}
 
+	productIDs := make([]int, 0, len(requested))
+	for productID := range requested {
+		productIDs = append(productIDs, productID)
 	}
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+
+	ownReservations, err := lockCustomerReservations(ctx, tx, order, productIDs)
+	if err != nil {
+		return err
+	}
+
+	// Reservations are converted only as far as the order uses them, those
+	// held for the order first; the rest stays reserved for the customer.
+	remaining := maps.Clone(requested)
+	for i := range ownReservations {
+		res := &ownReservations[i]
+		use := min(res.Quantity, remaining[res.ProductID])
+		remaining[res.ProductID] -= use
+
+		if err := convertReservation(ctx, tx, res, use, order.OrderID); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// placeDraft places a locked draft order: its stock is checked and taken
+// as if the order were created now, at the prices it was drafted with.
+func placeDraft(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	rows, err := tx.Query(ctx, `SELECT order_item_id, product_id, quantity
+		FROM order_items WHERE order_id = $1 ORDER BY order_item_id`, order.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get items of order %d: %w", order.OrderID, err)
+	}
+
+	var items []models.OrderItem
+	var productIDs []int
+	for rows.Next() {
+		item := models.OrderItem{OrderID: order.OrderID}
+		if err := rows.Scan(&item.OrderItemID, &item.ProductID, &item.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan order items: %w", err)
+		}
+		items = append(items, item)
+		productIDs = append(productIDs, item.ProductID)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	bundles, err := bundleComponents(ctx, tx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	requested := stockedQuantities(items, bundles)
+	if err := checkOrderStock(ctx, tx, order, requested); err != nil {
+		return err
+	}
+
+	return placeOrder(ctx, tx, order, items, bundles, requested)
+}
+
+// releaseOrderReservations hands back the stock still reserved for a draft
+// order.
+func releaseOrderReservations(ctx context.Context, tx pgx.Tx, orderID int) error {
+	rows, err := tx.Query(ctx, `SELECT reservation_id, product_id, warehouse_id, quantity
+		FROM reservations
+		WHERE order_id = $1 AND status = 'active'
+		FOR UPDATE`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get reservations of order %d: %w", orderID, err)
+	}
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		var res models.Reservation
+		if err := rows.Scan(&res.ReservationID, &res.ProductID, &res.WarehouseID, &res.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reservations: %w", err)
+		}
+		reservations = append(reservations, res)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for i := range reservations {
+		if err := closeReservation(ctx, tx, &reservations[i], models.ReservationReleased, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dispatchItem takes an order line out of the warehouse and records the
+// lots it left from on the line.
+func dispatchItem(ctx context.Context, tx pgx.Tx, warehouseID int, item *models.OrderItem) error {
+	draws, err := takeStock(ctx, tx, warehouseID, item.ProductID, item.Quantity, models.Operation{
+		OrderID:       &item.OrderID,
+		OperationType: "outgoing",
+	})
+	if err != nil {
+		return err
+	}
+
+	item.Lots = nil
+	for _, draw := range draws {
+		if draw.lotID == nil {
+			continue
+		}
+
+		_, err := tx.Exec(ctx, `INSERT INTO order_item_lots (order_item_id, lot_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (order_item_id, lot_id) DO UPDATE SET quantity = order_item_lots.quantity + EXCLUDED.quantity`,
+			item.OrderItemID, *draw.lotID, draw.quantity)
+		if err != nil {
+			return fmt.Errorf("failed to record lot %d for order item: %w", *draw.lotID, err)
+		}
+		item.Lots = append(item.Lots, models.OrderItemLot{LotID: *draw.lotID, Quantity: draw.quantity})
+	}
+
+	return nil
+}
+
+// takeStock removes a quantity of a product from a warehouse: it picks the
+// bins and lots the goods leave from and writes one costed operation per
+// bin and lot combination, filled in from op. The lot draws are returned.
+func takeStock(ctx context.Context, tx pgx.Tx, warehouseID, productID, quantity int, op models.Operation) ([]lotDraw, error) {
+	picks, err := pickFromLocations(ctx, tx, warehouseID, productID, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	draws, err := allocateLots(ctx, tx, warehouseID, productID, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adjustStock(ctx, tx, warehouseID, productID, -quantity); err != nil {
+		return nil, err
+	}
+
+	if _, err := insertOutgoing(ctx, tx, warehouseID, productID, picks, draws, op); err != nil {
+		return nil, err
+	}
+
+	return draws, nil
+}
+
+// insertOutgoing writes the operations for stock already taken out of bins
+// and lots. Bins and lots are allocated independently, so both lists are
+// walked together and an operation is cut wherever either one changes.
+// The operations written are returned.
+func insertOutgoing(ctx context.Context, tx pgx.Tx, warehouseID, productID int, picks []locationPick, draws []lotDraw, op models.Operation) ([]models.Operation, error) {
+	picks = append([]locationPick(nil), picks...)
+	remaining := append([]lotDraw(nil), draws...)
+
+	var ops []models.Operation
+
+	for p, d := 0, 0; p < len(picks) && d < len(remaining); {
+		take := min(picks[p].quantity, remaining[d].quantity)
+
+		o := op
+		o.ProductID = productID
+		o.WarehouseID = warehouseID
+		o.ChangeQuant = -take
+		o.FromLocationID = picks[p].locationID
+		o.LotID = remaining[d].lotID
+
+		if err := costOperation(ctx, tx, &o); err != nil {
+			return nil, err
+		}
+		if err := insertOperation(ctx, tx, &o); err != nil {
+			return nil, fmt.Errorf("failed to create operation: %w", err)
+		}
+		ops = append(ops, o)
+
+		picks[p].quantity -= take
+		remaining[d].quantity -= take
+		if picks[p].quantity == 0 {
+			p++
+		}
+		if remaining[d].quantity == 0 {
+			d++
+		}
+	}
+
+	return ops, nil
+}
+
+// lockCustomerReservations locks the active reservations an order may use:
+// those held for it as a draft, and the customer's ones held for no order,
+// soonest to expire first.
+func lockCustomerReservations(ctx context.Context, tx pgx.Tx, order *models.Order, productIDs []int) ([]models.Reservation, error) {
+	sql := `SELECT reservation_id, product_id, warehouse_id, quantity
+		FROM reservations
+		WHERE customer_id = $1
+		AND warehouse_id = $2
+		AND product_id = ANY($3::int[])
+		AND (order_id IS NULL OR order_id = $4)
+		AND status = 'active'
+		AND expires_at > NOW()
+		ORDER BY order_id IS NULL, expires_at, reservation_id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, sql, order.CustomerID, order.WarehouseID, productIDs, order.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer reservations: %w", err)
+	}
+
+	defer rows.Close()
+
+	var reservations []models.Reservation
+
+	for rows.Next() {
+		var res models.Reservation
+		if err := rows.Scan(&res.ReservationID, &res.ProductID, &res.WarehouseID, &res.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan customer reservations: %w", err)
+		}
+		reservations = append(reservations, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return reservations, nil
+}
+
 func (r *orderRepo) GetByID(ctx context.Context, id int) (*models.Order, error) {
 	if id <= 0 {
 		return nil, fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
@@ -195,8 +644,21 @@ func (r *orderRepo) GetByID(ctx context.Context, id int) (*models.Order, error)
 	sql := ` SELECT
 		order_id,
 		customer_id,
+		warehouse_id,
 		total_amount,
 		status,
+		stock_timing,
+		COALESCE(carrier, ''),
+		ship_by,
+		needs_attention,
+		discount_amount,
+		COALESCE(promo_code, ''),
+		COALESCE(tax_jurisdiction, ''),
+		prices_include_tax,
+		net_amount,
+		tax_amount,
+		COALESCE(currency, ''),
+		exchange_rate,
 		created_at
 		FROM orders 
 		WHERE order_id = $1
@@ -207,8 +669,21 @@ func (r *orderRepo) GetByID(ctx context.Context, id int) (*models.Order, error)
 	err := r.db.QueryRow(ctx, sql, id).Scan(
 		&order.OrderID,
 		&order.CustomerID,
+		&order.WarehouseID,
 		&order.TotalAmount,
 		&order.Status,
+		&order.StockTiming,
+		&order.Carrier,
+		&order.ShipBy,
+		&order.NeedsAttention,
+		&order.DiscountAmount,
+		&order.PromoCode,
+		&order.TaxJurisdiction,
+		&order.PricesIncludeTax,
+		&order.NetAmount,
+		&order.TaxAmount,
+		&order.Currency,
+		&order.ExchangeRate,
 		&order.CreatedAt,
 	)
 	if err != nil {
@@ -217,6 +692,7 @@ func (r *orderRepo) GetByID(ctx context.Context, id int) (*models.Order, error)
 		}
 		return nil, fmt.Errorf("get order %d: %w", id, err)
 	}
+	r.withCurrency(&order)
 
 	return &order, nil
 }
@@ -226,8 +702,21 @@ func (r *orderRepo) GetAll(ctx context.Context) ([]models.Order, error) {
 	SELECT 
 		order_id,
 		customer_id,
+		warehouse_id,
 		total_amount,
 		status,
+		stock_timing,
+		COALESCE(carrier, ''),
+		ship_by,
+		needs_attention,
+		discount_amount,
+		COALESCE(promo_code, ''),
+		COALESCE(tax_jurisdiction, ''),
+		prices_include_tax,
+		net_amount,
+		tax_amount,
+		COALESCE(currency, ''),
+		exchange_rate,
 		created_at
 		FROM orders
 		ORDER BY order_id`
@@ -246,13 +735,27 @@ func (r *orderRepo) GetAll(ctx context.Context) ([]models.Order, error) {
 
 		err := rows.Scan(&o.OrderID,
 			&o.CustomerID,
+			&o.WarehouseID,
 			&o.TotalAmount,
 			&o.Status,
+			&o.StockTiming,
+			&o.Carrier,
+			&o.ShipBy,
+			&o.NeedsAttention,
+			&o.DiscountAmount,
+			&o.PromoCode,
+			&o.TaxJurisdiction,
+			&o.PricesIncludeTax,
+			&o.NetAmount,
+			&o.TaxAmount,
+			&o.Currency,
+			&o.ExchangeRate,
 			&o.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan all orders: %w", err)
 		}
+		r.withCurrency(&o)
 		orders = append(orders, o)
 	}
 	if err := rows.Err(); err != nil {
@@ -262,41 +765,448 @@ func (r *orderRepo) GetAll(ctx context.Context) ([]models.Order, error) {
 	return orders, nil
 }
 
-func (r *orderRepo) UpdateStatus(ctx context.Context, id int, status string) error {
+// withCurrency fills in the base currency on orders placed before orders
+// recorded their currency.
+func (r *orderRepo) withCurrency(order *models.Order) {
+	if order.Currency == "" {
+		order.Currency = r.settings.BaseCurrency
+	}
+}
+
+// orderTransitions lists the statuses an order may move to from each
+// status. A draft is placed by moving it to created, and a created order
+// may ship before it is paid, as it always could. Shipped and cancelled
+// orders are final, and an order that has started shipping can no longer
+// be cancelled.
+var orderTransitions = map[string][]string{
+	models.OrderDraft:            {models.OrderCreated, models.OrderCancelled},
+	models.OrderCreated:          {models.OrderPaid, models.OrderShipped, models.OrderCancelled},
+	models.OrderPaid:             {models.OrderPartiallyShipped, models.OrderShipped, models.OrderCancelled},
+	models.OrderPartiallyShipped: {models.OrderShipped},
+}
 
+// UpdateStatus moves an order along orderTransitions and records the change
+// in its history. Marking an order shipped ships whatever is left of it as
+// one shipment; partially_shipped only ever follows from confirming
+// shipments. Placing a draft takes its stock then. Cancelling puts the
+// order's stock back, and releases what was reserved for a draft, in the
+// same transaction.
+func (r *orderRepo) UpdateStatus(ctx context.Context, id int, status, changedBy string) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
 	if status == "" {
 		return fmt.Errorf("%w: Status cannot be empty", ErrInvalidInput)
 	}
 
 	validStatuses := map[string]bool{
-		"created":   true,
-		"paid":      true,
-		"cancelled": true,
-		"shipped":   true,
+		models.OrderCreated:   true,
+		models.OrderPaid:      true,
+		models.OrderCancelled: true,
+		models.OrderShipped:   true,
 	}
 
 	if !validStatuses[status] {
 		return fmt.Errorf("%w: invalid status '%s'", ErrInvalidInput, status)
 	}
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := lockOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(orderTransitions[order.Status], status) {
+		return fmt.Errorf("%w: order %d cannot go from %s to %s", ErrInvalidTransition, id, order.Status, status)
+	}
+
+	switch status {
+	case models.OrderCreated:
+		if err := placeDraft(ctx, tx, order); err != nil {
+			return err
+		}
+
+	case models.OrderShipped:
+		if err := shipRemaining(ctx, tx, order); err != nil {
+			return err
+		}
+
+	case models.OrderCancelled:
+		if err := restoreOrderStock(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := releaseOrderReservations(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := setOrderStatus(ctx, tx, order, status, changedBy); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func lockOrder(ctx context.Context, tx pgx.Tx, id int) (*models.Order, error) {
+	var order models.Order
+
+	err := tx.QueryRow(ctx, `SELECT order_id, customer_id, warehouse_id, status, stock_timing
+		FROM orders WHERE order_id = $1 FOR UPDATE`, id).
+		Scan(&order.OrderID, &order.CustomerID, &order.WarehouseID, &order.Status, &order.StockTiming)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock order %d: %w", id, err)
+	}
+
+	return &order, nil
+}
+
+// setOrderStatus writes a locked order's new status and its history row.
+func setOrderStatus(ctx context.Context, tx pgx.Tx, order *models.Order, status, changedBy string) error {
 	sql := `UPDATE orders 
 		SET status = $1
 		WHERE order_id = $2
 		`
 
-	result, err := r.db.Exec(ctx, sql, status, id)
+	if _, err := tx.Exec(ctx, sql, status, order.OrderID); err != nil {
+		return fmt.Errorf("update status order %d: %w", order.OrderID, err)
+	}
+
+	if err := recordStatusChange(ctx, tx, order.OrderID, order.Status, status, changedBy); err != nil {
+		return err
+	}
+	order.Status = status
+
+	return nil
+}
+
+func recordStatusChange(ctx context.Context, tx pgx.Tx, orderID int, from, to, changedBy string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, changed_at)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5)`,
+		orderID, from, to, strings.TrimSpace(changedBy), time.Now())
 	if err != nil {
-		return fmt.Errorf("update status order %d: %w", id, err)
+		return fmt.Errorf("failed to record status of order %d: %w", orderID, err)
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
+	return nil
+}
+
+// restoreOrderStock reverses every outgoing operation of an order with an
+// incoming one: the goods go back to the bin and lot they left from at the
+// cost they left with, and sold serial numbers are back in stock. Serials
+// assigned to lines that never shipped are released as well.
+func restoreOrderStock(ctx context.Context, tx pgx.Tx, orderID int) error {
+	sql := `SELECT
+		operation_id,
+		product_id,
+		warehouse_id,
+		change_quant,
+		from_location_id,
+		lot_id,
+		unit_cost
+		FROM operations
+		WHERE order_id = $1 AND operation_type = 'outgoing'
+		ORDER BY operation_id
+	`
+
+	rows, err := tx.Query(ctx, sql, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get operations of order %d: %w", orderID, err)
+	}
+
+	var outgoing []models.Operation
+	for rows.Next() {
+		var o models.Operation
+		err := rows.Scan(&o.OperationID, &o.ProductID, &o.WarehouseID, &o.ChangeQuant, &o.FromLocationID, &o.LotID, &o.UnitCost)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan operations: %w", err)
+		}
+		outgoing = append(outgoing, o)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for _, out := range outgoing {
+		quantity := -out.ChangeQuant
+
+		if err := adjustStock(ctx, tx, out.WarehouseID, out.ProductID, quantity); err != nil {
+			return err
+		}
+		if out.FromLocationID != nil {
+			if err := putToLocation(ctx, tx, *out.FromLocationID, out.ProductID, quantity); err != nil {
+				return err
+			}
+		}
+		if out.LotID != nil {
+			_, err := tx.Exec(ctx, `UPDATE lots SET quantity = quantity + $1 WHERE lot_id = $2`, quantity, *out.LotID)
+			if err != nil {
+				return fmt.Errorf("failed to update lot %d: %w", *out.LotID, err)
+			}
+		}
+
+		op := models.Operation{
+			ProductID:     out.ProductID,
+			WarehouseID:   out.WarehouseID,
+			OrderID:       &orderID,
+			OperationType: "incoming",
+			ChangeQuant:   quantity,
+			ToLocationID:  out.FromLocationID,
+			LotID:         out.LotID,
+			UnitCost:      out.UnitCost,
+		}
+		if err := costOperation(ctx, tx, &op); err != nil {
+			return err
+		}
+		if err := insertOperation(ctx, tx, &op); err != nil {
+			return fmt.Errorf("failed to create operation: %w", err)
+		}
+
+		serialRows, err := tx.Query(ctx, `UPDATE serial_numbers s
+			SET status = $1, order_item_id = NULL
+			FROM operation_serials os
+			WHERE os.operation_id = $2 AND s.serial_id = os.serial_id AND s.status = $3
+			RETURNING s.serial_id`, models.SerialInStock, out.OperationID, models.SerialSold)
+		if err != nil {
+			return fmt.Errorf("failed to restock serials of operation %d: %w", out.OperationID, err)
+		}
+		serialIDs, err := scanIDs(serialRows)
+		serialRows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, serialID := range serialIDs {
+			if err := linkSerial(ctx, tx, op.OperationID, serialID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE serial_numbers
+		SET status = $1, order_item_id = NULL
+		WHERE status IN ($2, $3)
+		AND order_item_id IN (SELECT order_item_id FROM order_items WHERE order_id = $4)`,
+		models.SerialInStock, models.SerialAllocated, models.SerialSold, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to release serials of order %d: %w", orderID, err)
+	}
+
+	return nil
+}
+
+// ClearAttention resolves the flag a short pick raised on an order. The
+// order stays in the wave it was picked in; once what was picked has
+// shipped, the next plan waves the units still missing.
+func (r *orderRepo) ClearAttention(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := r.db.Exec(ctx, `UPDATE orders SET needs_attention = FALSE WHERE order_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to clear attention on order %d: %w", id, err)
+	}
+	if result.RowsAffected() == 0 {
 		return ErrNotFound
 	}
 
 	return nil
 }
 
+// GetStatusHistory lists an order's status changes, oldest first.
+func (r *orderRepo) GetStatusHistory(ctx context.Context, id int) ([]models.OrderStatusChange, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get order %d: %w", id, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	sql := `SELECT
+		history_id,
+		order_id,
+		COALESCE(from_status, ''),
+		to_status,
+		COALESCE(changed_by, ''),
+		changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, history_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history of order %d: %w", id, err)
+	}
+
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+
+	for rows.Next() {
+		var c models.OrderStatusChange
+
+		err := rows.Scan(&c.HistoryID,
+			&c.OrderID,
+			&c.FromStatus,
+			&c.ToStatus,
+			&c.ChangedBy,
+			&c.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		history = append(history, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return history, nil
+}
+
+// AssignSerials picks the units that will ship on a serialized order line.
+// The serials must be in stock in the order's warehouse; they are marked
+// sold and linked to the line's outgoing operations, or, if the order takes
+// its stock on shipment, marked allocated until the line ships.
+func (r *orderRepo) AssignSerials(ctx context.Context, orderItemID int, serials []string) error {
+	if orderItemID <= 0 {
+		return fmt.Errorf("%w: order item ID must be positive", ErrInvalidInput)
+	}
+	if len(serials) == 0 {
+		return fmt.Errorf("%w: serials cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT
+		oi.order_id,
+		oi.product_id,
+		oi.quantity,
+		o.warehouse_id,
+		o.status,
+		o.stock_timing,
+		p.is_serialized,
+		(SELECT COUNT(*) FROM serial_numbers s WHERE s.order_item_id = oi.order_item_id)
+		FROM order_items oi
+		JOIN orders o ON o.order_id = oi.order_id
+		JOIN products p ON p.product_id = oi.product_id
+		WHERE oi.order_item_id = $1
+		FOR UPDATE OF oi
+	`
+
+	var orderID, productID, quantity, warehouseID, assigned int
+	var status, stockTiming string
+	var serialized bool
+
+	err = tx.QueryRow(ctx, sql, orderItemID).Scan(&orderID, &productID, &quantity, &warehouseID, &status, &stockTiming, &serialized, &assigned)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get order item %d: %w", orderItemID, err)
+	}
+
+	if !serialized {
+		return fmt.Errorf("%w: product %d is not serialized", ErrInvalidInput, productID)
+	}
+	if status == models.OrderShipped || status == models.OrderCancelled {
+		return fmt.Errorf("%w: order %d is already %s", ErrInvalidInput, orderID, status)
+	}
+	if status == models.OrderDraft {
+		return fmt.Errorf("%w: order %d is still a draft", ErrInvalidInput, orderID)
+	}
+	if assigned+len(serials) > quantity {
+		return fmt.Errorf("%w: line has %d units, %d already assigned", ErrInvalidInput, quantity, assigned)
+	}
+
+	claim := `UPDATE serial_numbers
+		SET status = $1, order_item_id = $2
+		WHERE product_id = $3 AND serial_number = $4 AND warehouse_id = $5 AND status = $6
+		RETURNING serial_id
+	`
+
+	claimed := models.SerialSold
+	if stockTiming != models.StockOnOrder {
+		claimed = models.SerialAllocated
+	}
+
+	for _, serial := range serials {
+		var serialID int
+		err := tx.QueryRow(ctx, claim,
+			claimed,
+			orderItemID,
+			productID,
+			strings.TrimSpace(serial),
+			warehouseID,
+			models.SerialInStock,
+		).Scan(&serialID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: serial %s is not in stock in warehouse %d", ErrInvalidInput, serial, warehouseID)
+			}
+			return fmt.Errorf("failed to assign serial %s: %w", serial, err)
+		}
+
+		if stockTiming != models.StockOnOrder {
+			continue
+		}
+		if err := linkShippedSerial(ctx, tx, orderID, productID, serialID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// linkShippedSerial links a sold unit to an outgoing operation of its order
+// that has room for it.
+func linkShippedSerial(ctx context.Context, tx pgx.Tx, orderID, productID, serialID int) error {
+	findOperation := `SELECT o.operation_id
+		FROM operations o
+		WHERE o.order_id = $1 AND o.product_id = $2 AND o.operation_type = 'outgoing'
+		AND (SELECT COUNT(*) FROM operation_serials os WHERE os.operation_id = o.operation_id) < -o.change_quant
+		ORDER BY o.operation_id
+		LIMIT 1
+	`
+
+	var operationID int
+	if err := tx.QueryRow(ctx, findOperation, orderID, productID).Scan(&operationID); err != nil {
+		return fmt.Errorf("failed to find outgoing operation for serial %d: %w", serialID, err)
+	}
+
+	return linkSerial(ctx, tx, operationID, serialID)
+}
+
 func (r *orderRepo) GetOrderWithItems(ctx context.Context, id int) (*models.Order, []models.OrderItem, error) {
 	if id <= 0 {
 		return nil, nil, fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
@@ -305,13 +1215,37 @@ func (r *orderRepo) GetOrderWithItems(ctx context.Context, id int) (*models.Orde
 	sql := `SELECT
 	o.order_id,
 	o.customer_id,
+	o.warehouse_id,
 	o.total_amount,
 	o.status,
+	o.stock_timing,
+	COALESCE(o.carrier, ''),
+	o.ship_by,
+	o.needs_attention,
+	o.discount_amount,
+	COALESCE(o.promo_code, ''),
+	COALESCE(o.tax_jurisdiction, ''),
+	o.prices_include_tax,
+	o.net_amount,
+	o.tax_amount,
+	COALESCE(o.currency, ''),
+	o.exchange_rate,
 	o.created_at,
 	oi.order_item_id,
 	oi.product_id,
 	oi.quantity,
-	oi.price
+	oi.quantity_shipped,
+	oi.price,
+	oi.list_price,
+	oi.price_list_id,
+	COALESCE(oi.override_reason, ''),
+	COALESCE(oi.override_by, 0),
+	oi.net_amount,
+	oi.tax_rate,
+	oi.tax_amount,
+	oi.gross_amount,
+	COALESCE(oi.unit, ''),
+	COALESCE(oi.unit_quantity, 0)
 	FROM orders o
 	LEFT JOIN order_items oi ON o.order_id = oi.order_id
 	WHERE o.order_id = $1
@@ -334,33 +1268,83 @@ func (r *orderRepo) GetOrderWithItems(ctx context.Context, id int) (*models.Orde
 		var orderItemID pgtype.Int4 // вместо int
 		var productID pgtype.Int4   // вместо int
 		var quantity pgtype.Int4    // вместо int
-		var price pgtype.Float4     // вместо float64
+		var price, listPrice *models.Money
+		var priceListID *int
+		var overrideReason string
+		var overrideBy int
+		var netAmount, taxAmount, grossAmount *models.Money
+		var taxRate *models.Percent
+		var unit string
+		var unitQuantity int
+		var quantityShipped pgtype.Int4
 
 		err := rows.Scan(&currentOrder.OrderID,
 			&currentOrder.CustomerID,
+			&currentOrder.WarehouseID,
 			&currentOrder.TotalAmount,
 			&currentOrder.Status,
+			&currentOrder.StockTiming,
+			&currentOrder.Carrier,
+			&currentOrder.ShipBy,
+			&currentOrder.NeedsAttention,
+			&currentOrder.DiscountAmount,
+			&currentOrder.PromoCode,
+			&currentOrder.TaxJurisdiction,
+			&currentOrder.PricesIncludeTax,
+			&currentOrder.NetAmount,
+			&currentOrder.TaxAmount,
+			&currentOrder.Currency,
+			&currentOrder.ExchangeRate,
 			&currentOrder.CreatedAt,
 			&orderItemID,
 			&productID,
 			&quantity,
+			&quantityShipped,
 			&price,
+			&listPrice,
+			&priceListID,
+			&overrideReason,
+			&overrideBy,
+			&netAmount,
+			&taxRate,
+			&taxAmount,
+			&grossAmount,
+			&unit,
+			&unitQuantity,
 		)
 		if err != nil {
 			return nil, nil, fmt.Errorf("scan order/item: %w", err)
 		}
 		if !orderFound {
+			r.withCurrency(&currentOrder)
 			order = &currentOrder
 			orderFound = true
 		}
 		if orderItemID.Valid {
-			items = append(items, models.OrderItem{
-				OrderItemID: int(orderItemID.Int32),
-				OrderID:     currentOrder.OrderID,
-				ProductID:   int(productID.Int32),
-				Quantity:    int(quantity.Int32),
-				Price:       float64(price.Float32),
-			})
+			item := models.OrderItem{
+				OrderItemID:     int(orderItemID.Int32),
+				OrderID:         currentOrder.OrderID,
+				ProductID:       int(productID.Int32),
+				Quantity:        int(quantity.Int32),
+				QuantityShipped: int(quantityShipped.Int32),
+				Price:           *price,
+				ListPrice:       *listPrice,
+				PriceListID:     priceListID,
+				NetAmount:       *netAmount,
+				TaxRate:         *taxRate,
+				TaxAmount:       *taxAmount,
+				GrossAmount:     *grossAmount,
+				Unit:            unit,
+				UnitQuantity:    unitQuantity,
+			}
+			if overrideReason != "" {
+				item.Override = &models.PriceOverride{
+					Price:        item.Price,
+					Reason:       overrideReason,
+					AuthorizedBy: overrideBy,
+				}
+			}
+			items = append(items, item)
 		}
 	}
 
@@ -372,10 +1356,61 @@ func (r *orderRepo) GetOrderWithItems(ctx context.Context, id int) (*models.Orde
 		return nil, nil, ErrNotFound
 	}
 
+	if err := r.loadItemLots(ctx, items); err != nil {
+		return nil, nil, err
+	}
+
+	order.Discounts, err = getOrderDiscounts(ctx, r.db, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return order, items, nil
 
 }
 
+func (r *orderRepo) loadItemLots(ctx context.Context, items []models.OrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(items))
+	ids := make([]int, 0, len(items))
+	for i, item := range items {
+		index[item.OrderItemID] = i
+		ids = append(ids, item.OrderItemID)
+	}
+
+	sql := `SELECT order_item_id, lot_id, quantity
+		FROM order_item_lots
+		WHERE order_item_id = ANY($1::int[])
+		ORDER BY order_item_id, lot_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get order item lots: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID int
+		var lot models.OrderItemLot
+		if err := rows.Scan(&itemID, &lot.LotID, &lot.Quantity); err != nil {
+			return fmt.Errorf("failed to scan order item lots: %w", err)
+		}
+		i := index[itemID]
+		items[i].Lots = append(items[i].Lots, lot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return nil
+}
+
 func (r *orderRepo) GetByCustomerID(ctx context.Context, customerID int) ([]models.Order, error) {
 	if customerID <= 0 {
 		return nil, fmt.Errorf("%w: ID must be positive", ErrInvalidInput)
@@ -384,8 +1419,21 @@ func (r *orderRepo) GetByCustomerID(ctx context.Context, customerID int) ([]mode
 	sql := `SELECT 
 		order_id,
 		customer_id,
+		warehouse_id,
 		total_amount,
 		status,
+		stock_timing,
+		COALESCE(carrier, ''),
+		ship_by,
+		needs_attention,
+		discount_amount,
+		COALESCE(promo_code, ''),
+		COALESCE(tax_jurisdiction, ''),
+		prices_include_tax,
+		net_amount,
+		tax_amount,
+		COALESCE(currency, ''),
+		exchange_rate,
 		created_at
 		FROM orders
 		WHERE customer_id = $1`
@@ -404,13 +1452,27 @@ func (r *orderRepo) GetByCustomerID(ctx context.Context, customerID int) ([]mode
 
 		err := rows.Scan(&o.OrderID,
 			&o.CustomerID,
+			&o.WarehouseID,
 			&o.TotalAmount,
 			&o.Status,
+			&o.StockTiming,
+			&o.Carrier,
+			&o.ShipBy,
+			&o.NeedsAttention,
+			&o.DiscountAmount,
+			&o.PromoCode,
+			&o.TaxJurisdiction,
+			&o.PricesIncludeTax,
+			&o.NetAmount,
+			&o.TaxAmount,
+			&o.Currency,
+			&o.ExchangeRate,
 			&o.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan orders by customerID: %w", err)
 		}
+		r.withCurrency(&o)
 
 		orders = append(orders, o)
 	}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"data-service/internal/models"
+	"slices"
+	"testing"
+)
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderDraft, models.OrderCreated, true},
+		{models.OrderCreated, models.OrderPaid, true},
+		{models.OrderCreated, models.OrderShipped, true},
+		{models.OrderPaid, models.OrderShipped, true},
+		{models.OrderPartiallyShipped, models.OrderShipped, true},
+		{models.OrderPaid, models.OrderCancelled, true},
+		{models.OrderShipped, models.OrderCreated, false},
+		{models.OrderPaid, models.OrderCreated, false},
+		{models.OrderPartiallyShipped, models.OrderCancelled, false},
+		{models.OrderCancelled, models.OrderCreated, false},
+	}
+
+	for _, tt := range tests {
+		if got := slices.Contains(orderTransitions[tt.from], tt.to); got != tt.want {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
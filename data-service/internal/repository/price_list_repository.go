@@ -0,0 +1,449 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type priceListRepo struct {
+	db           *pgx.Conn
+	baseCurrency string
+}
+
+// NewPriceListRepository quotes in baseCurrency, the currency catalogue
+// and price list prices are in, unless a quote asks for another.
+func NewPriceListRepository(db *pgx.Conn, baseCurrency string) PriceListRepository {
+	baseCurrency = normalizeCurrency(baseCurrency)
+	if baseCurrency == "" {
+		baseCurrency = models.DefaultBaseCurrency
+	}
+	return &priceListRepo{db: db, baseCurrency: baseCurrency}
+}
+
+// validatePrices defaults a missing quantity break to 1 and checks that
+// every product has at most one price per break.
+func validatePrices(prices []models.PriceListPrice) error {
+	seen := make(map[[2]int]bool, len(prices))
+	for i := range prices {
+		p := &prices[i]
+		if p.ProductID <= 0 {
+			return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+		}
+		if p.MinQuantity == 0 {
+			p.MinQuantity = 1
+		}
+		if p.MinQuantity < 0 {
+			return fmt.Errorf("%w: minimum quantity must be positive", ErrInvalidInput)
+		}
+		if p.Price <= 0 {
+			return fmt.Errorf("%w: price must be positive", ErrInvalidInput)
+		}
+
+		key := [2]int{p.ProductID, p.MinQuantity}
+		if seen[key] {
+			return fmt.Errorf("%w: product %d has two prices from %d units", ErrInvalidInput, p.ProductID, p.MinQuantity)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+func insertPrices(ctx context.Context, tx pgx.Tx, priceListID int, prices []models.PriceListPrice) error {
+	insert := `INSERT INTO price_list_prices (price_list_id, product_id, min_quantity, price)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	for _, p := range prices {
+		_, err := tx.Exec(ctx, insert, priceListID, p.ProductID, p.MinQuantity, p.Price)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%w: product %d", ErrProductNotFound, p.ProductID)
+			}
+			return fmt.Errorf("failed to add price of product %d: %w", p.ProductID, err)
+		}
+	}
+
+	return nil
+}
+
+// Create adds a price list for either a customer or a customer group,
+// together with its prices.
+func (r *priceListRepo) Create(ctx context.Context, pl *models.PriceList) error {
+	if pl == nil {
+		return fmt.Errorf("%w: price list cannot be nil", ErrInvalidInput)
+	}
+
+	pl.Name = strings.TrimSpace(pl.Name)
+	pl.CustomerGroup = strings.TrimSpace(pl.CustomerGroup)
+
+	if pl.Name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidInput)
+	}
+	if (pl.CustomerID == nil) == (pl.CustomerGroup == "") {
+		return fmt.Errorf("%w: a price list is for either a customer or a customer group", ErrInvalidInput)
+	}
+	if pl.CustomerID != nil && *pl.CustomerID <= 0 {
+		return fmt.Errorf("%w: customer ID must be positive", ErrInvalidInput)
+	}
+	if pl.ValidFrom.IsZero() {
+		return fmt.Errorf("%w: valid from date is required", ErrInvalidInput)
+	}
+	if pl.ValidTo != nil && !pl.ValidTo.After(pl.ValidFrom) {
+		return fmt.Errorf("%w: price list must end after it starts", ErrInvalidInput)
+	}
+	if err := validatePrices(pl.Prices); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	insert := `INSERT INTO price_lists (
+		name,
+		customer_id,
+		customer_group,
+		valid_from,
+		valid_to,
+		created_at
+	) VALUES ($1, $2, NULLIF($3, ''), $4::date, $5::date, $6)
+	RETURNING price_list_id, valid_from, valid_to
+	`
+
+	pl.CreatedAt = time.Now()
+
+	err = tx.QueryRow(ctx, insert, pl.Name, nullableID(pl.CustomerID), pl.CustomerGroup, pl.ValidFrom, pl.ValidTo, pl.CreatedAt).
+		Scan(&pl.PriceListID, &pl.ValidFrom, &pl.ValidTo)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to create price list: %w", err)
+	}
+
+	if err := insertPrices(ctx, tx, pl.PriceListID, pl.Prices); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if pl.Prices == nil {
+		pl.Prices = []models.PriceListPrice{}
+	}
+
+	return nil
+}
+
+func (r *priceListRepo) GetByID(ctx context.Context, id int) (*models.PriceList, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			price_list_id,
+			name,
+			customer_id,
+			COALESCE(customer_group, ''),
+			valid_from,
+			valid_to,
+			created_at
+		FROM price_lists WHERE price_list_id = $1
+		`
+
+	var pl models.PriceList
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&pl.PriceListID,
+		&pl.Name,
+		&pl.CustomerID,
+		&pl.CustomerGroup,
+		&pl.ValidFrom,
+		&pl.ValidTo,
+		&pl.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get price list by id %d: %w", id, err)
+	}
+
+	pl.Prices, err = getPriceListPrices(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pl, nil
+}
+
+func getPriceListPrices(ctx context.Context, q querier, priceListID int) ([]models.PriceListPrice, error) {
+	sql := `
+		SELECT product_id, min_quantity, price
+		FROM price_list_prices
+		WHERE price_list_id = $1
+		ORDER BY product_id, min_quantity
+		`
+
+	rows, err := q.Query(ctx, sql, priceListID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices of price list %d: %w", priceListID, err)
+	}
+
+	defer rows.Close()
+
+	prices := []models.PriceListPrice{}
+
+	for rows.Next() {
+		var p models.PriceListPrice
+		if err := rows.Scan(&p.ProductID, &p.MinQuantity, &p.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan price list prices: %w", err)
+		}
+		prices = append(prices, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return prices, nil
+}
+
+// GetByCustomer lists the price lists a customer gets, its own and its
+// group's, past and future included, without their prices.
+func (r *priceListRepo) GetByCustomer(ctx context.Context, customerID int) ([]models.PriceList, error) {
+	if customerID <= 0 {
+		return nil, fmt.Errorf("%w: customer ID must be positive", ErrInvalidInput)
+	}
+
+	var group *string
+	err := r.db.QueryRow(ctx, `SELECT customer_group FROM customers WHERE customer_id = $1`, customerID).Scan(&group)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer %d: %w", customerID, err)
+	}
+
+	sql := `
+		SELECT
+			price_list_id,
+			name,
+			customer_id,
+			COALESCE(customer_group, ''),
+			valid_from,
+			valid_to,
+			created_at
+		FROM price_lists
+		WHERE customer_id = $1 OR customer_group = $2
+		ORDER BY valid_from, price_list_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, customerID, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price lists of customer %d: %w", customerID, err)
+	}
+
+	defer rows.Close()
+
+	var lists []models.PriceList
+
+	for rows.Next() {
+		var pl models.PriceList
+
+		err := rows.Scan(&pl.PriceListID,
+			&pl.Name,
+			&pl.CustomerID,
+			&pl.CustomerGroup,
+			&pl.ValidFrom,
+			&pl.ValidTo,
+			&pl.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan price lists: %w", err)
+		}
+		lists = append(lists, pl)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lists, nil
+}
+
+// SetPrices replaces all prices of a price list. Orders already placed
+// keep the prices they were charged.
+func (r *priceListRepo) SetPrices(ctx context.Context, id int, prices []models.PriceListPrice) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if err := validatePrices(prices); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int
+	err = tx.QueryRow(ctx, `SELECT price_list_id FROM price_lists WHERE price_list_id = $1 FOR UPDATE`, id).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock price list %d: %w", id, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM price_list_prices WHERE price_list_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to clear prices of price list %d: %w", id, err)
+	}
+
+	if err := insertPrices(ctx, tx, id, prices); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// negotiatedPrice is what a price list charges for a product.
+type negotiatedPrice struct {
+	price       models.Money
+	priceListID int
+}
+
+// negotiatedPrices looks up, for each product in quantities, the price
+// lists in force today that a customer gets, and takes the lowest price
+// whose quantity break the quantity reaches. The customer's own lists come
+// before its group's. Products that no list prices are left out.
+func negotiatedPrices(ctx context.Context, q querier, customerID int, quantities map[int]int) (map[int]negotiatedPrice, error) {
+	productIDs := make([]int, 0, len(quantities))
+	amounts := make([]int, 0, len(quantities))
+	for productID, quantity := range quantities {
+		productIDs = append(productIDs, productID)
+		amounts = append(amounts, quantity)
+	}
+
+	sql := `SELECT DISTINCT ON (plp.product_id)
+		plp.product_id,
+		plp.price,
+		pl.price_list_id
+		FROM customers c
+		JOIN price_lists pl ON pl.customer_id = c.customer_id OR pl.customer_group = c.customer_group
+		JOIN price_list_prices plp ON plp.price_list_id = pl.price_list_id
+		JOIN unnest($2::int[], $3::int[]) AS q(product_id, quantity)
+			ON q.product_id = plp.product_id AND plp.min_quantity <= q.quantity
+		WHERE c.customer_id = $1
+		AND pl.valid_from <= CURRENT_DATE
+		AND (pl.valid_to IS NULL OR pl.valid_to > CURRENT_DATE)
+		ORDER BY plp.product_id, pl.customer_id IS NULL, plp.price, pl.price_list_id
+	`
+
+	rows, err := q.Query(ctx, sql, customerID, productIDs, amounts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price list prices: %w", err)
+	}
+
+	defer rows.Close()
+
+	prices := make(map[int]negotiatedPrice)
+
+	for rows.Next() {
+		var productID int
+		var p negotiatedPrice
+		if err := rows.Scan(&productID, &p.price, &p.priceListID); err != nil {
+			return nil, fmt.Errorf("failed to scan price list prices: %w", err)
+		}
+		prices[productID] = p
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return prices, nil
+}
+
+// Quote resolves the unit price a customer would be charged today for a
+// quantity, in base units, of a product, the way placing an order would.
+// The quote is in the base currency unless currency names another.
+func (r *priceListRepo) Quote(ctx context.Context, customerID, productID, quantity int, currency string) (*models.PriceQuote, error) {
+	if customerID <= 0 {
+		return nil, fmt.Errorf("%w: customer ID must be positive", ErrInvalidInput)
+	}
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+	}
+
+	quote := models.PriceQuote{
+		CustomerID: customerID,
+		ProductID:  productID,
+		Quantity:   quantity,
+		Currency:   normalizeCurrency(currency),
+	}
+	if quote.Currency == "" {
+		quote.Currency = r.baseCurrency
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE customer_id = $1)`, customerID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get customer %d: %w", customerID, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var catalogue models.Money
+	err := r.db.QueryRow(ctx, `SELECT price FROM products WHERE product_id = $1`, productID).Scan(&catalogue)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProductNotFound
+		}
+		return nil, fmt.Errorf("failed to get product %d: %w", productID, err)
+	}
+
+	// fromBase only needs the order's rate, so the quote stands in for one.
+	order := models.Order{Currency: quote.Currency}
+	order.ExchangeRate, err = exchangeRate(ctx, r.db, quote.Currency, r.baseCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	negotiated, err := negotiatedPrices(ctx, r.db, customerID, map[int]int{productID: quantity})
+	if err != nil {
+		return nil, err
+	}
+
+	quote.ListPrice = fromBase(catalogue, &order)
+	quote.Price = quote.ListPrice
+	if p, ok := negotiated[productID]; ok {
+		quote.Price = fromBase(p.price, &order)
+		quote.PriceListID = &p.priceListID
+	}
+	quote.Amount = quote.Price.Mul(quantity)
+
+	return &quote, nil
+}
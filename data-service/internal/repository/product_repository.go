@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"data-service/internal/models"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type productRepo struct {
@@ -27,17 +30,64 @@ func (r *productRepo) Create(ctx context.Context, p *models.Product) error {
 	if p.Quantity < 0 {
 		return fmt.Errorf("%w: product quantity cannot be negative", ErrInvalidInput)
 	}
+	if p.Serialized && p.Quantity > 0 {
+		return fmt.Errorf("%w: serialized products must be received with serial numbers", ErrInvalidInput)
+	}
+	sku, err := normalizeSKU(p.SKU)
+	if err != nil {
+		return err
+	}
+	p.SKU = sku
+	if p.BaseUnit == "" {
+		p.BaseUnit = models.DefaultBaseUnit
+	}
+	if p.BaseUnit, err = normalizeUnit(p.BaseUnit); err != nil {
+		return err
+	}
+	if err := validateUnits(p.BaseUnit, p.Units); err != nil {
+		return err
+	}
+	if err := validateAttributes(p); err != nil {
+		return err
+	}
+	if err := validateReorder(p, false, false); err != nil {
+		return err
+	}
+	for i := range p.Barcodes {
+		if err := validateBarcode(&p.Barcodes[i]); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if p.ParentID != nil {
+		if p.Category, err = lockParent(ctx, tx, *p.ParentID); err != nil {
+			return err
+		}
+	}
 
 	sql := `
 		INSERT INTO products (
+			sku,
 			name,
 			price,
 			description,
 			quantity,
+			base_unit,
 			category,
+			is_serialized,
+			parent_id,
+			attributes,
+			reorder_point,
+			reorder_quantity,
 			created_at,
 			updated_at
-	) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	) VALUES ($1, $2, $3, $4, 0, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	RETURNING product_id
 	`
 
@@ -45,19 +95,60 @@ func (r *productRepo) Create(ctx context.Context, p *models.Product) error {
 	p.CreatedAt = now
 	p.UpdatedAt = now
 
-	err := r.db.QueryRow(ctx, sql,
+	err = tx.QueryRow(ctx, sql,
+		p.SKU,
 		p.Name,
 		p.Price,
 		p.Description,
-		p.Quantity,
+		p.BaseUnit,
 		p.Category,
+		p.Serialized,
+		nullableID(p.ParentID),
+		p.Attributes,
+		p.ReorderPoint,
+		p.ReorderQuantity,
 		p.CreatedAt,
 		p.UpdatedAt,
 	).Scan(&p.ProductID)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == "idx_products_variant_attributes" {
+				return fmt.Errorf("%w: product %d already has a variant with these attributes", ErrDuplicate, *p.ParentID)
+			}
+			return fmt.Errorf("%w: SKU %s already exists", ErrDuplicate, p.SKU)
+		}
 		return fmt.Errorf("failed to create product: %w", err)
 	}
 
+	for _, b := range p.Barcodes {
+		if err := insertBarcode(ctx, tx, p.ProductID, b); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range p.Units {
+		if err := upsertUnit(ctx, tx, p.ProductID, u); err != nil {
+			return err
+		}
+	}
+
+	// Opening stock is placed in the default warehouse.
+	if p.Quantity > 0 {
+		if err := adjustStock(ctx, tx, models.DefaultWarehouseID, p.ProductID, p.Quantity); err != nil {
+			return err
+		}
+		if err := logAdjustment(ctx, tx, models.DefaultWarehouseID, p.ProductID, "incoming", p.Quantity); err != nil {
+			return err
+		}
+	} else if err := checkReorderPoint(ctx, tx, p.ProductID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -68,26 +159,40 @@ func (r *productRepo) GetByID(ctx context.Context, id int) (*models.Product, err
 
 	sql := `
 		SELECT 
-			product_id,
-			name,
-			price,
-			description,
-			quantity,
-			category,
-			created_at,
-			updated_at	
-		FROM products WHERE product_id = $1
+			p.product_id,
+			p.sku,
+			p.name,
+			p.price,
+			p.description,
+			` + productQuantitySQL + `,
+			p.base_unit,
+			p.category,
+			p.is_serialized,
+			p.parent_id,
+			p.attributes,
+			p.reorder_point,
+			p.reorder_quantity,
+			p.created_at,
+			p.updated_at
+		FROM products p WHERE p.product_id = $1
 		`
 
 	var product models.Product
 
 	err := r.db.QueryRow(ctx, sql, id).Scan(
 		&product.ProductID,
+		&product.SKU,
 		&product.Name,
 		&product.Price,
 		&product.Description,
 		&product.Quantity,
+		&product.BaseUnit,
 		&product.Category,
+		&product.Serialized,
+		&product.ParentID,
+		&product.Attributes,
+		&product.ReorderPoint,
+		&product.ReorderQuantity,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 	)
@@ -98,23 +203,54 @@ func (r *productRepo) GetByID(ctx context.Context, id int) (*models.Product, err
 		return nil, fmt.Errorf("failed to get product by id %d: %w", id, err)
 	}
 
+	product.Stock, err = getProductStock(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Barcodes, err = r.getBarcodes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Units, err = r.getUnits(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.ParentID == nil {
+		product.Variants, err = r.getVariants(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &product, nil
 
 }
 
-func (r *productRepo) GetAll(ctx context.Context) ([]models.Product, error) {
+// GetAll lists every product, variants included. With withVariants only
+// top-level products are returned and variants are nested under their parent.
+func (r *productRepo) GetAll(ctx context.Context, withVariants bool) ([]models.Product, error) {
 	sql := `
     SELECT 
-        product_id,
-        name,
-		price,
-		description,
-		quantity,
-		category,
-		created_at,
-		updated_at	
-    FROM products 
-    ORDER BY product_id
+        p.product_id,
+        p.sku,
+        p.name,
+		p.price,
+		p.description,
+		` + productQuantitySQL + `,
+		p.base_unit,
+		p.category,
+		p.is_serialized,
+		p.parent_id,
+		p.attributes,
+		p.reorder_point,
+		p.reorder_quantity,
+		p.created_at,
+		p.updated_at
+    FROM products p
+    ORDER BY p.product_id
 `
 	rows, err := r.db.Query(ctx, sql)
 	if err != nil {
@@ -129,11 +265,18 @@ func (r *productRepo) GetAll(ctx context.Context) ([]models.Product, error) {
 		var p models.Product
 
 		err := rows.Scan(&p.ProductID,
+			&p.SKU,
 			&p.Name,
 			&p.Price,
 			&p.Description,
 			&p.Quantity,
+			&p.BaseUnit,
 			&p.Category,
+			&p.Serialized,
+			&p.ParentID,
+			&p.Attributes,
+			&p.ReorderPoint,
+			&p.ReorderQuantity,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 		)
@@ -147,6 +290,10 @@ func (r *productRepo) GetAll(ctx context.Context) ([]models.Product, error) {
 		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
 	}
 
+	if withVariants {
+		products = nestVariants(products)
+	}
+
 	return products, nil
 }
 
@@ -157,45 +304,107 @@ func (r *productRepo) Update(ctx context.Context, p *models.Product) error {
 	if p.Price <= 0 {
 		return fmt.Errorf("%w: product price  should be positive", ErrInvalidInput)
 	}
-	if p.Quantity < 0 {
-		return fmt.Errorf("%w: product quantity cannot be negative", ErrInvalidInput)
-	}
 	if p.ProductID <= 0 {
 		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
 	}
+	// An empty SKU keeps the stored one.
+	if strings.TrimSpace(p.SKU) != "" {
+		sku, err := normalizeSKU(p.SKU)
+		if err != nil {
+			return err
+		}
+		p.SKU = sku
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hasVariants, isBundle bool
+	err = tx.QueryRow(ctx, `SELECT
+		EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id),
+		EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.product_id)
+		FROM products p WHERE p.product_id = $1
+		FOR UPDATE`, p.ProductID).Scan(&hasVariants, &isBundle)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock product %d: %w", p.ProductID, err)
+	}
+	if err := validateReorder(p, hasVariants, isBundle); err != nil {
+		return err
+	}
 
+	// Stock is only changed through UpdateQuantity and operations, so the
+	// stored quantity is returned rather than overwritten. The serialized
+	// flag can only flip while there is no stock without serial numbers.
+	// Variants keep their parent's category.
 	sql := `
-	UPDATE products 
+	UPDATE products p
 	SET 
 		name = $1,
 		price = $2,
     	description = $3,
-    	quantity = $4,
-    	category = $5,
-		updated_at = $6
+    	category = COALESCE((SELECT pp.category FROM products pp WHERE pp.product_id = p.parent_id), $4),
+		is_serialized = $5,
+		updated_at = $6,
+		sku = COALESCE(NULLIF($8, ''), sku),
+		reorder_point = $9,
+		reorder_quantity = $10
 	WHERE product_id = $7
-	RETURNING updated_at
+	AND (is_serialized = $5 OR quantity = 0)
+	RETURNING ` + productQuantitySQL + `, sku, base_unit, category, parent_id, attributes, updated_at
 	`
 
 	now := time.Now()
 
-	err := r.db.QueryRow(ctx, sql,
+	err = tx.QueryRow(ctx, sql,
 		p.Name,
 		p.Price,
 		p.Description,
-		p.Quantity,
 		p.Category,
+		p.Serialized,
 		now,
 		p.ProductID,
-	).Scan(&p.UpdatedAt)
+		p.SKU,
+		p.ReorderPoint,
+		p.ReorderQuantity,
+	).Scan(&p.Quantity, &p.SKU, &p.BaseUnit, &p.Category, &p.ParentID, &p.Attributes, &p.UpdatedAt)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: SKU %s already exists", ErrDuplicate, p.SKU)
+		}
 		if err == pgx.ErrNoRows {
+			var exists bool
+			err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM products WHERE product_id = $1)`, p.ProductID).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("failed to update product %d: %w", p.ProductID, err)
+			}
+			if exists {
+				return fmt.Errorf("%w: serialized flag can only change while product is out of stock", ErrInvalidInput)
+			}
 			return ErrNotFound
 		}
 		return fmt.Errorf("failed to update product %d: %w", p.ProductID, err)
 	}
 
+	_, err = tx.Exec(ctx, `UPDATE products SET category = $1, updated_at = $2 WHERE parent_id = $3 AND category <> $1`, p.Category, now, p.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to update variants of product %d: %w", p.ProductID, err)
+	}
+
+	if err := checkReorderPoint(ctx, tx, p.ProductID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -208,6 +417,10 @@ func (r *productRepo) Delete(ctx context.Context, id int) error {
 
 	result, err := r.db.Exec(ctx, sql, id)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%w: product %d still has variants or stock history", ErrInvalidInput, id)
+		}
 		return fmt.Errorf("failed to delete product %d: %w", id, err)
 	}
 
@@ -220,61 +433,84 @@ func (r *productRepo) Delete(ctx context.Context, id int) error {
 
 }
 
-func (r *productRepo) UpdateQuantity(ctx context.Context, id int, change int) error {
-	product, err := r.GetByID(ctx, id)
-	if err != nil {
-		return ErrNotFound
+// UpdateQuantity books a direct stock change in a warehouse the way an
+// approved count does: decreases leave unbinned stock first, then bins, and
+// come out of lots first-expired-first-out, and never take stock held by
+// reservations or committed to open orders; increases go in without a bin,
+// into the earliest-expiring lot still sellable.
+func (r *productRepo) UpdateQuantity(ctx context.Context, id int, warehouseID int, change int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if warehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID cannot be empty", ErrInvalidInput)
 	}
 
-	newQuantity := product.Quantity + change
-	if newQuantity < 0 {
-		return fmt.Errorf("%w: insufficient quantity. Current: %d, Requested change: %d", ErrNotEnough, product.Quantity, change)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	sql := `UPDATE products SET 
-	quantity = quantity + $1,
-		updated_at = $2
-	WHERE product_id = $3
-	RETURNING quantity, updated_at
-	`
+	var serialized bool
+	err = tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1 FOR UPDATE`, id).Scan(&serialized)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get product %d: %w", id, err)
+	}
+	if serialized {
+		return fmt.Errorf("%w: stock of serialized product %d changes only with serial numbers", ErrInvalidInput, id)
+	}
 
-	now := time.Now()
-	var returnedQuantity int
-	var returnedUpdatedAt time.Time
+	if change == 0 {
+		return nil
+	}
+	if change < 0 {
+		if err := checkFreeStock(ctx, tx, warehouseID, id, -change, true); err != nil {
+			return err
+		}
+	}
 
-	err = r.db.QueryRow(ctx, sql, change, now, id).Scan(&returnedQuantity, &returnedUpdatedAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
+	if _, err := postCountVariance(ctx, tx, warehouseID, id, nil, change); err != nil {
+		if errors.Is(err, ErrProductNotFound) {
 			return ErrNotFound
 		}
-		return fmt.Errorf("failed to update product quantity %d: %w", id, err)
+		return err
 	}
 
-	if returnedQuantity != newQuantity {
-		return fmt.Errorf("quantity mismatch after update: expected %d, got %d",
-			newQuantity, returnedQuantity)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (r *productRepo) GetByCategory(ctx context.Context, category string) ([]models.Product, error) {
+func (r *productRepo) GetByCategory(ctx context.Context, category string, withVariants bool) ([]models.Product, error) {
 	if category == "" {
 		return nil, fmt.Errorf(" category cannot be empty: %w", ErrInvalidInput)
 	}
 
 	sql := `
 		SELECT 
-			product_id,
-			name,
-			price,
-			description,
-			quantity,
-			category,
-			created_at,
-			updated_at	
-		FROM products WHERE category = $1
-		ORDER BY product_id
+			p.product_id,
+			p.sku,
+			p.name,
+			p.price,
+			p.description,
+			` + productQuantitySQL + `,
+			p.base_unit,
+			p.category,
+			p.is_serialized,
+			p.parent_id,
+			p.attributes,
+			p.reorder_point,
+			p.reorder_quantity,
+			p.created_at,
+			p.updated_at
+		FROM products p WHERE p.category = $1
+		ORDER BY p.product_id
 		`
 
 	rows, err := r.db.Query(ctx, sql, category)
@@ -290,11 +526,421 @@ func (r *productRepo) GetByCategory(ctx context.Context, category string) ([]mod
 		var p models.Product
 
 		err := rows.Scan(&p.ProductID,
+			&p.SKU,
+			&p.Name,
+			&p.Price,
+			&p.Description,
+			&p.Quantity,
+			&p.BaseUnit,
+			&p.Category,
+			&p.Serialized,
+			&p.ParentID,
+			&p.Attributes,
+			&p.ReorderPoint,
+			&p.ReorderQuantity,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan products: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if withVariants {
+		products = nestVariants(products)
+	}
+
+	return products, nil
+
+}
+
+func (r *productRepo) GetStockLevel(ctx context.Context, id int) (*models.StockLevel, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	// For a parent the figures cover all of its variants.
+	rows, err := r.db.Query(ctx, `SELECT product_id FROM products WHERE product_id = $1 OR parent_id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock level for product %d: %w", id, err)
+	}
+
+	var family []int
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan product family: %w", err)
+		}
+		family = append(family, productID)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if len(family) == 0 {
+		return nil, ErrNotFound
+	}
+
+	figures, err := availableStock(ctx, r.db, 0, family, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := models.StockLevel{ProductID: id}
+	for _, f := range figures {
+		stock.OnHand += f.onHand
+		stock.Reserved += f.reserved
+		stock.Committed += f.committed
+		stock.Expired += f.expired
+	}
+
+	sql := `SELECT COALESCE(SUM(tl.quantity), 0) FROM transfer_lines tl
+		JOIN transfers t ON t.transfer_id = tl.transfer_id
+		WHERE tl.product_id = ANY($1::int[])
+		AND t.status = 'in_transit'
+	`
+
+	if err := r.db.QueryRow(ctx, sql, family).Scan(&stock.InTransit); err != nil {
+		return nil, fmt.Errorf("failed to get stock in transit for product %d: %w", id, err)
+	}
+
+	stock.Available = max(stock.OnHand-stock.Reserved-stock.Committed-stock.Expired, 0)
+
+	return &stock, nil
+}
+
+func (r *productRepo) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if sku == "" {
+		return nil, fmt.Errorf("%w: SKU cannot be empty", ErrInvalidInput)
+	}
+
+	var id int
+	err := r.db.QueryRow(ctx, `SELECT product_id FROM products WHERE sku = $1`, sku).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get product by sku %s: %w", sku, err)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+func (r *productRepo) GetByBarcode(ctx context.Context, barcode string) (*models.Product, error) {
+	barcode = strings.TrimSpace(barcode)
+	if barcode == "" {
+		return nil, fmt.Errorf("%w: barcode cannot be empty", ErrInvalidInput)
+	}
+
+	var id int
+	err := r.db.QueryRow(ctx, `SELECT product_id FROM product_barcodes WHERE barcode = $1`, barcode).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get product by barcode %s: %w", barcode, err)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+func (r *productRepo) AddBarcode(ctx context.Context, productID int, b *models.Barcode) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if err := validateBarcode(b); err != nil {
+		return err
+	}
+
+	return insertBarcode(ctx, r.db, productID, *b)
+}
+
+func (r *productRepo) RemoveBarcode(ctx context.Context, productID int, barcode string) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `DELETE FROM product_barcodes WHERE product_id = $1 AND barcode = $2`
+
+	result, err := r.db.Exec(ctx, sql, productID, strings.TrimSpace(barcode))
+	if err != nil {
+		return fmt.Errorf("failed to delete barcode %s: %w", barcode, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *productRepo) getBarcodes(ctx context.Context, productID int) ([]models.Barcode, error) {
+	sql := `SELECT barcode, symbology FROM product_barcodes WHERE product_id = $1 ORDER BY created_at, barcode`
+
+	rows, err := r.db.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get barcodes for product %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	var barcodes []models.Barcode
+
+	for rows.Next() {
+		var b models.Barcode
+		if err := rows.Scan(&b.Code, &b.Symbology); err != nil {
+			return nil, fmt.Errorf("failed to scan barcodes: %w", err)
+		}
+		barcodes = append(barcodes, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return barcodes, nil
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertBarcode(ctx context.Context, q execer, productID int, b models.Barcode) error {
+	sql := `INSERT INTO product_barcodes (barcode, product_id, symbology, created_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := q.Exec(ctx, sql, b.Code, productID, b.Symbology, time.Now())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return fmt.Errorf("%w: barcode %s already assigned", ErrDuplicate, b.Code)
+			case "23503":
+				return ErrNotFound
+			}
+		}
+		return fmt.Errorf("failed to add barcode %s: %w", b.Code, err)
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// SetUnit defines an alternate unit for a product or changes its factor.
+// Past operations are unaffected since they are stored in the base unit.
+func (r *productRepo) SetUnit(ctx context.Context, productID int, u *models.ProductUnit) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	var baseUnit string
+	err := r.db.QueryRow(ctx, `SELECT base_unit FROM products WHERE product_id = $1`, productID).Scan(&baseUnit)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get product %d: %w", productID, err)
+	}
+
+	units := []models.ProductUnit{*u}
+	if err := validateUnits(baseUnit, units); err != nil {
+		return err
+	}
+	*u = units[0]
+
+	return upsertUnit(ctx, r.db, productID, *u)
+}
+
+func (r *productRepo) RemoveUnit(ctx context.Context, productID int, unit string) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `DELETE FROM product_units WHERE product_id = $1 AND unit = $2`
+
+	result, err := r.db.Exec(ctx, sql, productID, strings.ToLower(strings.TrimSpace(unit)))
+	if err != nil {
+		return fmt.Errorf("failed to delete unit %s: %w", unit, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *productRepo) getUnits(ctx context.Context, productID int) ([]models.ProductUnit, error) {
+	sql := `SELECT unit, factor FROM product_units WHERE product_id = $1 ORDER BY factor, unit`
+
+	rows, err := r.db.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get units for product %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	var units []models.ProductUnit
+
+	for rows.Next() {
+		var u models.ProductUnit
+		if err := rows.Scan(&u.Unit, &u.Factor); err != nil {
+			return nil, fmt.Errorf("failed to scan units: %w", err)
+		}
+		units = append(units, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return units, nil
+}
+
+func upsertUnit(ctx context.Context, q execer, productID int, u models.ProductUnit) error {
+	sql := `INSERT INTO product_units (product_id, unit, factor) VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, unit) DO UPDATE SET factor = EXCLUDED.factor
+	`
+
+	if _, err := q.Exec(ctx, sql, productID, u.Unit, u.Factor); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to set unit %s: %w", u.Unit, err)
+	}
+
+	return nil
+}
+
+func (r *productRepo) getVariants(ctx context.Context, parentID int) ([]models.Product, error) {
+	sql := `
+		SELECT
+			p.product_id,
+			p.sku,
+			p.name,
+			p.price,
+			p.description,
+			p.quantity,
+			p.base_unit,
+			p.category,
+			p.is_serialized,
+			p.parent_id,
+			p.attributes,
+			p.reorder_point,
+			p.reorder_quantity,
+			p.created_at,
+			p.updated_at
+		FROM products p WHERE p.parent_id = $1
+		ORDER BY p.product_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variants of product %d: %w", parentID, err)
+	}
+
+	defer rows.Close()
+
+	var variants []models.Product
+
+	for rows.Next() {
+		var p models.Product
+
+		err := rows.Scan(&p.ProductID,
+			&p.SKU,
+			&p.Name,
+			&p.Price,
+			&p.Description,
+			&p.Quantity,
+			&p.BaseUnit,
+			&p.Category,
+			&p.Serialized,
+			&p.ParentID,
+			&p.Attributes,
+			&p.ReorderPoint,
+			&p.ReorderQuantity,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan variants: %w", err)
+		}
+		variants = append(variants, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return variants, nil
+}
+
+// GetLowStock lists the products whose stock is at or below their reorder
+// point. Parents and bundles hold no stock of their own and are left out.
+func (r *productRepo) GetLowStock(ctx context.Context) ([]models.Product, error) {
+	sql := `
+		SELECT
+			p.product_id,
+			p.sku,
+			p.name,
+			p.price,
+			p.description,
+			p.quantity,
+			p.base_unit,
+			p.category,
+			p.is_serialized,
+			p.parent_id,
+			p.attributes,
+			p.reorder_point,
+			p.reorder_quantity,
+			p.created_at,
+			p.updated_at
+		FROM products p
+		WHERE p.reorder_point IS NOT NULL AND p.quantity <= p.reorder_point
+		AND NOT EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id)
+		AND NOT EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.product_id)
+		ORDER BY p.quantity - p.reorder_point, p.product_id
+		`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get low stock products: %w", err)
+	}
+
+	defer rows.Close()
+
+	var products []models.Product
+
+	for rows.Next() {
+		var p models.Product
+
+		err := rows.Scan(&p.ProductID,
+			&p.SKU,
 			&p.Name,
 			&p.Price,
 			&p.Description,
 			&p.Quantity,
+			&p.BaseUnit,
 			&p.Category,
+			&p.Serialized,
+			&p.ParentID,
+			&p.Attributes,
+			&p.ReorderPoint,
+			&p.ReorderQuantity,
 			&p.CreatedAt,
 			&p.UpdatedAt,
 		)
@@ -309,5 +955,77 @@ func (r *productRepo) GetByCategory(ctx context.Context, category string) ([]mod
 	}
 
 	return products, nil
+}
+
+// GetStockAlerts lists stock alerts, newest first. With openOnly, alerts
+// already resolved by restocking are left out.
+func (r *productRepo) GetStockAlerts(ctx context.Context, openOnly bool) ([]models.StockAlert, error) {
+	sql := `SELECT
+		alert_id,
+		product_id,
+		quantity,
+		reorder_point,
+		reorder_quantity,
+		created_at,
+		resolved_at
+		FROM stock_alerts
+		WHERE NOT $1 OR resolved_at IS NULL
+		ORDER BY created_at DESC, alert_id DESC
+	`
+
+	rows, err := r.db.Query(ctx, sql, openOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock alerts: %w", err)
+	}
+
+	defer rows.Close()
 
+	var alerts []models.StockAlert
+
+	for rows.Next() {
+		var a models.StockAlert
+
+		err := rows.Scan(&a.AlertID,
+			&a.ProductID,
+			&a.Quantity,
+			&a.ReorderPoint,
+			&a.ReorderQuantity,
+			&a.CreatedAt,
+			&a.ResolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock alerts: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// logAdjustment writes the costed operation for a stock change made
+// directly through adjustStock, so the ledger keeps adding up to
+// products.quantity.
+func logAdjustment(ctx context.Context, tx pgx.Tx, warehouseID, productID int, operationType string, change int) error {
+	if change == 0 {
+		return nil
+	}
+
+	o := models.Operation{
+		ProductID:     productID,
+		WarehouseID:   warehouseID,
+		OperationType: operationType,
+		ChangeQuant:   change,
+	}
+	if err := costOperation(ctx, tx, &o); err != nil {
+		return err
+	}
+	if err := insertOperation(ctx, tx, &o); err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,618 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type promotionRepo struct {
+	db *pgx.Conn
+}
+
+func NewPromotionRepository(db *pgx.Conn) PromotionRepository {
+	return &promotionRepo{db: db}
+}
+
+// normalizePromoCode makes codes case-insensitive.
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (r *promotionRepo) Create(ctx context.Context, p *models.Promotion) error {
+	if p == nil {
+		return fmt.Errorf("%w: promotion cannot be nil", ErrInvalidInput)
+	}
+
+	p.Code = normalizePromoCode(p.Code)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Category = strings.TrimSpace(p.Category)
+
+	if p.Name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidInput)
+	}
+
+	switch p.Kind {
+	case models.PromotionPercent:
+		if p.Value <= 0 || p.Value > models.HundredPercent {
+			return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidInput)
+		}
+		if !p.Value.Hundredths() {
+			return fmt.Errorf("%w: percentage may have at most two decimal places", ErrInvalidInput)
+		}
+		p.Amount = nil
+		p.BuyQuantity, p.GetQuantity = nil, nil
+	case models.PromotionFixed:
+		if p.Amount == nil || *p.Amount <= 0 {
+			return fmt.Errorf("%w: amount must be positive", ErrInvalidInput)
+		}
+		p.Value = 0
+		p.BuyQuantity, p.GetQuantity = nil, nil
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity == nil || *p.BuyQuantity <= 0 || p.GetQuantity == nil || *p.GetQuantity <= 0 {
+			return fmt.Errorf("%w: buy and get quantities must be positive", ErrInvalidInput)
+		}
+		p.Value = 0
+		p.Amount = nil
+	default:
+		return fmt.Errorf("%w: unknown promotion kind %q", ErrInvalidInput, p.Kind)
+	}
+
+	if p.ProductID != nil && p.Category != "" {
+		return fmt.Errorf("%w: a promotion is for a product or a category, not both", ErrInvalidInput)
+	}
+	if p.MinOrderValue != nil && *p.MinOrderValue <= 0 {
+		return fmt.Errorf("%w: minimum order value must be positive", ErrInvalidInput)
+	}
+	if p.MaxUses != nil && *p.MaxUses <= 0 {
+		return fmt.Errorf("%w: usage limit must be positive", ErrInvalidInput)
+	}
+	if p.MaxUsesPerCustomer != nil && *p.MaxUsesPerCustomer <= 0 {
+		return fmt.Errorf("%w: usage limit per customer must be positive", ErrInvalidInput)
+	}
+
+	p.CreatedAt = time.Now()
+	if p.StartsAt.IsZero() {
+		p.StartsAt = p.CreatedAt
+	}
+	if p.EndsAt != nil && !p.EndsAt.After(p.StartsAt) {
+		return fmt.Errorf("%w: promotion must end after it starts", ErrInvalidInput)
+	}
+
+	insert := `INSERT INTO promotions (
+		code,
+		name,
+		kind,
+		value,
+		buy_quantity,
+		get_quantity,
+		product_id,
+		category,
+		min_order_value,
+		max_uses,
+		max_uses_per_customer,
+		starts_at,
+		ends_at,
+		active,
+		created_at
+	) VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, TRUE, $14)
+	RETURNING promotion_id
+	`
+
+	// The value column holds the percentage or the amount.
+	var value any = p.Value
+	if p.Amount != nil {
+		value = *p.Amount
+	}
+
+	err := r.db.QueryRow(ctx, insert,
+		p.Code,
+		p.Name,
+		p.Kind,
+		value,
+		p.BuyQuantity,
+		p.GetQuantity,
+		nullableID(p.ProductID),
+		p.Category,
+		p.MinOrderValue,
+		p.MaxUses,
+		p.MaxUsesPerCustomer,
+		p.StartsAt,
+		p.EndsAt,
+		p.CreatedAt,
+	).Scan(&p.PromotionID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return fmt.Errorf("%w: promo code %s already exists", ErrDuplicate, p.Code)
+			case "23503":
+				return ErrProductNotFound
+			}
+		}
+		return fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	p.Active = true
+	p.Uses = 0
+
+	return nil
+}
+
+const promotionColumns = `
+	p.promotion_id,
+	COALESCE(p.code, ''),
+	p.name,
+	p.kind,
+	CASE WHEN p.kind = 'percent' THEN p.value ELSE 0 END,
+	CASE WHEN p.kind = 'fixed' THEN p.value END,
+	p.buy_quantity,
+	p.get_quantity,
+	p.product_id,
+	COALESCE(p.category, ''),
+	p.min_order_value,
+	p.max_uses,
+	p.max_uses_per_customer,
+	p.starts_at,
+	p.ends_at,
+	p.active,
+	p.created_at,
+	(
+		SELECT COUNT(DISTINCT od.order_id) FROM order_discounts od
+		JOIN orders o ON o.order_id = od.order_id
+		WHERE od.promotion_id = p.promotion_id AND o.status <> 'cancelled'
+	)::int`
+
+func scanPromotion(row pgx.Row, p *models.Promotion) error {
+	return row.Scan(
+		&p.PromotionID,
+		&p.Code,
+		&p.Name,
+		&p.Kind,
+		&p.Value,
+		&p.Amount,
+		&p.BuyQuantity,
+		&p.GetQuantity,
+		&p.ProductID,
+		&p.Category,
+		&p.MinOrderValue,
+		&p.MaxUses,
+		&p.MaxUsesPerCustomer,
+		&p.StartsAt,
+		&p.EndsAt,
+		&p.Active,
+		&p.CreatedAt,
+		&p.Uses,
+	)
+}
+
+func (r *promotionRepo) GetByID(ctx context.Context, id int) (*models.Promotion, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `SELECT ` + promotionColumns + ` FROM promotions p WHERE p.promotion_id = $1`
+
+	var p models.Promotion
+	if err := scanPromotion(r.db.QueryRow(ctx, sql, id), &p); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get promotion by id %d: %w", id, err)
+	}
+
+	return &p, nil
+}
+
+func (r *promotionRepo) GetAll(ctx context.Context) ([]models.Promotion, error) {
+	sql := `SELECT ` + promotionColumns + ` FROM promotions p ORDER BY p.promotion_id`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all promotions: %w", err)
+	}
+
+	defer rows.Close()
+
+	var promotions []models.Promotion
+
+	for rows.Next() {
+		var p models.Promotion
+		if err := scanPromotion(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan promotions: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return promotions, nil
+}
+
+// Deactivate stops a promotion from applying to new orders.
+func (r *promotionRepo) Deactivate(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := r.db.Exec(ctx, `UPDATE promotions SET active = FALSE WHERE promotion_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate promotion %d: %w", id, err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// lockPromotions locks the automatic promotions running now and the one
+// with the given code, and returns their IDs. It runs as a statement of its
+// own, so the uses counted afterwards include the orders of whoever held
+// the locks before.
+func lockPromotions(ctx context.Context, tx pgx.Tx, code string) ([]int, error) {
+	sql := `SELECT promotion_id FROM promotions
+	WHERE active
+	AND starts_at <= NOW()
+	AND (ends_at IS NULL OR ends_at > NOW())
+	AND (code IS NULL OR code = $1)
+	ORDER BY promotion_id
+	FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, sql, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock promotions: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ids []int
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan promotions: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return ids, nil
+}
+
+// pendingDiscount is a discount worked out before the order's lines have
+// IDs; item indexes the order's items and is -1 for the whole order.
+type pendingDiscount struct {
+	discount models.OrderDiscount
+	item     int
+}
+
+// applyPromotions works out the discounts an order gets from the
+// automatic promotions running now and from its promo code. Promotions are
+// applied in the order they were created, each to the undiscounted lines,
+// and together never take off more than the order, or any one line, is
+// worth. An automatic
+// promotion that does not fit the order is passed over; a quoted code that
+// does not is an error. The promotions are locked before their uses are
+// counted, so usage limits hold. Their amounts are converted into the
+// order's currency.
+func applyPromotions(ctx context.Context, tx pgx.Tx, order *models.Order, items []models.OrderItem, subtotal models.Money) ([]pendingDiscount, error) {
+	promotionIDs, err := lockPromotions(ctx, tx, order.PromoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := `SELECT ` + promotionColumns + `,
+	(
+		SELECT COUNT(DISTINCT od.order_id) FROM order_discounts od
+		JOIN orders o ON o.order_id = od.order_id
+		WHERE od.promotion_id = p.promotion_id AND o.status <> 'cancelled' AND o.customer_id = $2
+	)::int
+	FROM promotions p
+	WHERE p.promotion_id = ANY($1::int[])
+	ORDER BY p.promotion_id
+	`
+
+	rows, err := tx.Query(ctx, sql, promotionIDs, order.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions: %w", err)
+	}
+
+	type candidate struct {
+		promotion    models.Promotion
+		customerUses int
+	}
+
+	var candidates []candidate
+	codeFound := false
+	for rows.Next() {
+		var c candidate
+		p := &c.promotion
+		err := rows.Scan(
+			&p.PromotionID,
+			&p.Code,
+			&p.Name,
+			&p.Kind,
+			&p.Value,
+			&p.Amount,
+			&p.BuyQuantity,
+			&p.GetQuantity,
+			&p.ProductID,
+			&p.Category,
+			&p.MinOrderValue,
+			&p.MaxUses,
+			&p.MaxUsesPerCustomer,
+			&p.StartsAt,
+			&p.EndsAt,
+			&p.Active,
+			&p.CreatedAt,
+			&p.Uses,
+			&c.customerUses,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan promotions: %w", err)
+		}
+		if p.Code != "" {
+			codeFound = true
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if order.PromoCode != "" && !codeFound {
+		return nil, fmt.Errorf("%w: promo code %s is not valid", ErrInvalidInput, order.PromoCode)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+
+	categories := make(map[int]string)
+	rows, err = tx.Query(ctx, `SELECT product_id, COALESCE(category, '') FROM products WHERE product_id = ANY($1::int[])`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product categories: %w", err)
+	}
+	for rows.Next() {
+		var productID int
+		var category string
+		if err := rows.Scan(&productID, &category); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan product categories: %w", err)
+		}
+		categories[productID] = category
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	var discounts []pendingDiscount
+	budget := newDiscountBudget(items)
+
+	for _, c := range candidates {
+		p := c.promotion
+
+		// skip passes over an automatic promotion and rejects a quoted one.
+		skip := func(format string, args ...any) error {
+			if p.Code == "" {
+				return nil
+			}
+			return fmt.Errorf("%w: promo code %s "+format, append([]any{ErrInvalidInput, p.Code}, args...)...)
+		}
+
+		if p.MaxUses != nil && p.Uses >= *p.MaxUses {
+			if err := skip("has been used up"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.MaxUsesPerCustomer != nil && c.customerUses >= *p.MaxUsesPerCustomer {
+			if err := skip("has already been used by this customer"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.MinOrderValue != nil && subtotal < fromBase(*p.MinOrderValue, order) {
+			if err := skip("needs an order of at least %s %s", fromBase(*p.MinOrderValue, order), order.Currency); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		applied := promotionDiscounts(&p, order, items, categories)
+		if len(applied) == 0 {
+			if err := skip("does not apply to this order"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		discounts = append(discounts, budget.take(applied)...)
+	}
+
+	return discounts, nil
+}
+
+// promotionDiscounts works out what a promotion takes off the lines it
+// covers, each line at its undiscounted value, or, for a fixed amount, off
+// the whole order.
+func promotionDiscounts(p *models.Promotion, order *models.Order, items []models.OrderItem, categories map[int]string) []pendingDiscount {
+	var applied []pendingDiscount
+	var scoped models.Money
+
+	for i, item := range items {
+		if p.ProductID != nil && item.ProductID != *p.ProductID {
+			continue
+		}
+		if p.Category != "" && categories[item.ProductID] != p.Category {
+			continue
+		}
+
+		amount := item.Price.Mul(item.Quantity)
+		scoped += amount
+
+		var off models.Money
+		switch p.Kind {
+		case models.PromotionPercent:
+			off = amount.Percent(p.Value)
+		case models.PromotionBuyXGetY:
+			free := item.Quantity / (*p.BuyQuantity + *p.GetQuantity) * *p.GetQuantity
+			off = item.Price.Mul(free)
+		}
+
+		if off > 0 {
+			applied = append(applied, pendingDiscount{
+				discount: models.OrderDiscount{PromotionID: p.PromotionID, Description: p.Name, Amount: off},
+				item:     i,
+			})
+		}
+	}
+
+	if p.Kind == models.PromotionFixed && scoped > 0 {
+		applied = append(applied, pendingDiscount{
+			discount: models.OrderDiscount{PromotionID: p.PromotionID, Description: p.Name, Amount: min(fromBase(*p.Amount, order), scoped)},
+			item:     -1,
+		})
+	}
+
+	return applied
+}
+
+// discountBudget is what discounts may still take off an order: in all,
+// and off each line, so stacked promotions never take a line below zero.
+type discountBudget struct {
+	left  models.Money
+	lines []models.Money
+}
+
+func newDiscountBudget(items []models.OrderItem) *discountBudget {
+	b := &discountBudget{lines: make([]models.Money, len(items))}
+	for i, item := range items {
+		b.lines[i] = item.Price.Mul(item.Quantity)
+		b.left += b.lines[i]
+	}
+	return b
+}
+
+// take cuts discounts down to what is left of the order and of their
+// lines, and returns those that still take something off.
+func (b *discountBudget) take(discounts []pendingDiscount) []pendingDiscount {
+	var taken []pendingDiscount
+
+	for _, d := range discounts {
+		limit := b.left
+		if d.item >= 0 {
+			limit = min(limit, b.lines[d.item])
+		}
+
+		d.discount.Amount = min(d.discount.Amount, limit)
+		if d.discount.Amount <= 0 {
+			continue
+		}
+
+		b.left -= d.discount.Amount
+		if d.item >= 0 {
+			b.lines[d.item] -= d.discount.Amount
+		}
+		taken = append(taken, d)
+	}
+
+	return taken
+}
+
+// insertOrderDiscounts stores the discounts of a placed order, whose lines
+// have their IDs by now.
+func insertOrderDiscounts(ctx context.Context, tx pgx.Tx, order *models.Order, items []models.OrderItem, discounts []pendingDiscount) error {
+	insert := `INSERT INTO order_discounts (order_id, promotion_id, order_item_id, description, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING order_discount_id
+	`
+
+	order.Discounts = nil
+	for _, d := range discounts {
+		discount := d.discount
+		discount.OrderID = order.OrderID
+		if d.item >= 0 {
+			itemID := items[d.item].OrderItemID
+			discount.OrderItemID = &itemID
+		}
+
+		err := tx.QueryRow(ctx, insert, discount.OrderID, discount.PromotionID, nullableID(discount.OrderItemID), discount.Description, discount.Amount).
+			Scan(&discount.DiscountID)
+		if err != nil {
+			return fmt.Errorf("failed to record discount: %w", err)
+		}
+		order.Discounts = append(order.Discounts, discount)
+	}
+
+	return nil
+}
+
+func getOrderDiscounts(ctx context.Context, q querier, orderID int) ([]models.OrderDiscount, error) {
+	sql := `
+		SELECT
+			order_discount_id,
+			order_id,
+			promotion_id,
+			order_item_id,
+			description,
+			amount
+		FROM order_discounts
+		WHERE order_id = $1
+		ORDER BY order_discount_id
+		`
+
+	rows, err := q.Query(ctx, sql, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discounts of order %d: %w", orderID, err)
+	}
+
+	defer rows.Close()
+
+	var discounts []models.OrderDiscount
+
+	for rows.Next() {
+		var d models.OrderDiscount
+
+		err := rows.Scan(&d.DiscountID,
+			&d.OrderID,
+			&d.PromotionID,
+			&d.OrderItemID,
+			&d.Description,
+			&d.Amount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order discounts: %w", err)
+		}
+		discounts = append(discounts, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return discounts, nil
+}
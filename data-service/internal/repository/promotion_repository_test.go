@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"data-service/internal/models"
+	"testing"
+)
+
+func TestStackedPromotionsStopAtLineValue(t *testing.T) {
+	product, other := 1, 2
+	buy, get := 1, 1
+	percent := func(n int) models.Percent { return models.HundredPercent * models.Percent(n) / 100 }
+
+	items := []models.OrderItem{
+		{ProductID: product, Quantity: 2, Price: 5000},
+		{ProductID: other, Quantity: 1, Price: 10000},
+	}
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		want       []models.Money
+	}{
+		{
+			name: "two percentages",
+			promotions: []models.Promotion{
+				{PromotionID: 1, Kind: models.PromotionPercent, Value: percent(60), ProductID: &product},
+				{PromotionID: 2, Kind: models.PromotionPercent, Value: percent(60), ProductID: &product},
+			},
+			want: []models.Money{6000, 4000},
+		},
+		{
+			name: "percentage and buy one get one",
+			promotions: []models.Promotion{
+				{PromotionID: 1, Kind: models.PromotionPercent, Value: percent(60), ProductID: &product},
+				{PromotionID: 2, Kind: models.PromotionBuyXGetY, BuyQuantity: &buy, GetQuantity: &get, ProductID: &product},
+			},
+			want: []models.Money{6000, 4000},
+		},
+		{
+			name: "room left on the line",
+			promotions: []models.Promotion{
+				{PromotionID: 1, Kind: models.PromotionPercent, Value: percent(10), ProductID: &product},
+				{PromotionID: 2, Kind: models.PromotionPercent, Value: percent(20), ProductID: &product},
+			},
+			want: []models.Money{1000, 2000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{ExchangeRate: models.OneRate}
+			budget := newDiscountBudget(items)
+
+			var got []models.Money
+			for i := range tt.promotions {
+				for _, d := range budget.take(promotionDiscounts(&tt.promotions[i], order, items, nil)) {
+					if d.item != 0 {
+						t.Fatalf("discount on line %d, want line 0", d.item)
+					}
+					got = append(got, d.discount.Amount)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("discounts = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("discounts = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if budget.lines[0] < 0 {
+				t.Errorf("line left at %s", budget.lines[0])
+			}
+		})
+	}
+}
+
+func TestDiscountBudgetCapsOrder(t *testing.T) {
+	items := []models.OrderItem{{ProductID: 1, Quantity: 1, Price: 3000}}
+	budget := newDiscountBudget(items)
+
+	taken := budget.take([]pendingDiscount{
+		{discount: models.OrderDiscount{Amount: 2000}, item: -1},
+		{discount: models.OrderDiscount{Amount: 2000}, item: 0},
+		{discount: models.OrderDiscount{Amount: 500}, item: -1},
+	})
+
+	if len(taken) != 2 || taken[0].discount.Amount != 2000 || taken[1].discount.Amount != 1000 {
+		t.Errorf("taken = %+v, want 20.00 off the order and 10.00 off the line", taken)
+	}
+	if budget.left != 0 {
+		t.Errorf("left = %s, want 0", budget.left)
+	}
+}
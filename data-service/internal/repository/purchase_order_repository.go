@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// poTransitions lists the statuses a purchase order can be moved to by hand
+// and the statuses it may come from. partially_received and received are
+// only ever set by Receive.
+var poTransitions = map[string][]string{
+	models.PurchaseOrderSent: {models.PurchaseOrderDraft},
+	models.PurchaseOrderClosed: {
+		models.PurchaseOrderDraft,
+		models.PurchaseOrderSent,
+		models.PurchaseOrderPartiallyReceived,
+		models.PurchaseOrderReceived,
+	},
+}
+
+// poReceivable lists the statuses goods can be received against.
+// Received orders still take late over-deliveries until they are closed.
+var poReceivable = map[string]bool{
+	models.PurchaseOrderSent:              true,
+	models.PurchaseOrderPartiallyReceived: true,
+	models.PurchaseOrderReceived:          true,
+}
+
+type purchaseOrderRepo struct {
+	db *pgx.Conn
+}
+
+func NewPurchaseOrderRepository(db *pgx.Conn) PurchaseOrderRepository {
+	return &purchaseOrderRepo{db: db}
+}
+
+func (r *purchaseOrderRepo) Create(ctx context.Context, po *models.PurchaseOrder) error {
+	if po == nil {
+		return fmt.Errorf("%w: purchase order cannot be nil", ErrInvalidInput)
+	}
+	if po.SupplierID <= 0 {
+		return fmt.Errorf("%w: supplier ID must be positive", ErrInvalidInput)
+	}
+	if po.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if len(po.Lines) == 0 {
+		return fmt.Errorf("%w: purchase order needs at least one line", ErrInvalidInput)
+	}
+
+	seen := make(map[int]bool, len(po.Lines))
+	for _, line := range po.Lines {
+		if line.ProductID <= 0 {
+			return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+		}
+		if line.QuantityOrdered <= 0 {
+			return fmt.Errorf("%w: ordered quantity must be positive", ErrInvalidInput)
+		}
+		if line.UnitCost < 0 {
+			return fmt.Errorf("%w: unit cost cannot be negative", ErrInvalidInput)
+		}
+		if seen[line.ProductID] {
+			return fmt.Errorf("%w: product %d listed twice", ErrInvalidInput, line.ProductID)
+		}
+		seen[line.ProductID] = true
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	insert := `INSERT INTO purchase_orders (
+		supplier_id,
+		warehouse_id,
+		status,
+		created_at,
+		updated_at
+	) VALUES ($1, $2, $3, $4, $4)
+	RETURNING po_id, created_at, updated_at
+	`
+
+	po.Status = models.PurchaseOrderDraft
+
+	err = tx.QueryRow(ctx, insert, po.SupplierID, po.WarehouseID, po.Status, time.Now()).
+		Scan(&po.POID, &po.CreatedAt, &po.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%w: supplier %d or warehouse %d not found", ErrInvalidInput, po.SupplierID, po.WarehouseID)
+		}
+		return fmt.Errorf("failed to create purchase order: %w", err)
+	}
+
+	insertLine := `INSERT INTO purchase_order_lines (po_id, product_id, quantity_ordered, unit_cost)
+		VALUES ($1, $2, $3, $4)
+		RETURNING po_line_id
+	`
+
+	for i := range po.Lines {
+		line := &po.Lines[i]
+		line.POID = po.POID
+		line.QuantityReceived = 0
+
+		err := tx.QueryRow(ctx, insertLine, po.POID, line.ProductID, line.QuantityOrdered, line.UnitCost).Scan(&line.LineID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%w: product %d", ErrProductNotFound, line.ProductID)
+			}
+			return fmt.Errorf("failed to create purchase order line: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *purchaseOrderRepo) GetByID(ctx context.Context, id int) (*models.PurchaseOrder, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			po_id,
+			supplier_id,
+			warehouse_id,
+			status,
+			created_at,
+			updated_at
+		FROM purchase_orders WHERE po_id = $1
+		`
+
+	var po models.PurchaseOrder
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&po.POID,
+		&po.SupplierID,
+		&po.WarehouseID,
+		&po.Status,
+		&po.CreatedAt,
+		&po.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get purchase order by id %d: %w", id, err)
+	}
+
+	po.Lines, err = getPOLines(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &po, nil
+}
+
+// GetBySupplier lists a supplier's purchase orders without their lines.
+func (r *purchaseOrderRepo) GetBySupplier(ctx context.Context, supplierID int) ([]models.PurchaseOrder, error) {
+	if supplierID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			po_id,
+			supplier_id,
+			warehouse_id,
+			status,
+			created_at,
+			updated_at
+		FROM purchase_orders
+		WHERE supplier_id = $1
+		ORDER BY po_id DESC
+		`
+
+	rows, err := r.db.Query(ctx, sql, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase orders of supplier %d: %w", supplierID, err)
+	}
+
+	defer rows.Close()
+
+	var orders []models.PurchaseOrder
+
+	for rows.Next() {
+		var po models.PurchaseOrder
+
+		err := rows.Scan(&po.POID,
+			&po.SupplierID,
+			&po.WarehouseID,
+			&po.Status,
+			&po.CreatedAt,
+			&po.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase orders: %w", err)
+		}
+		orders = append(orders, po)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return orders, nil
+}
+
+// UpdateStatus makes the manual moves: sending a draft to the supplier and
+// closing an order, after which nothing more is received against it.
+func (r *purchaseOrderRepo) UpdateStatus(ctx context.Context, id int, status string) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	from, ok := poTransitions[status]
+	if !ok {
+		return fmt.Errorf("%w: status '%s' cannot be set by hand", ErrInvalidInput, status)
+	}
+
+	sql := `UPDATE purchase_orders
+		SET status = $1, updated_at = $2
+		WHERE po_id = $3 AND status = ANY($4)
+		`
+
+	result, err := r.db.Exec(ctx, sql, status, time.Now(), id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update purchase order %d: %w", id, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		var current string
+		err := r.db.QueryRow(ctx, `SELECT status FROM purchase_orders WHERE po_id = $1`, id).Scan(&current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("failed to get purchase order %d: %w", id, err)
+		}
+		return fmt.Errorf("%w: purchase order %d cannot go from %s to %s", ErrInvalidInput, id, current, status)
+	}
+
+	return nil
+}
+
+// Receive books goods delivered against a purchase order. Each receipt names
+// the PO line it fills and is posted like any other delivery, with its
+// incoming operation linked to the line and costed at the line's unit cost. Lines may be under- or
+// over-received; the order's status is recomputed from all lines.
+func (r *purchaseOrderRepo) Receive(ctx context.Context, poID int, receipts []models.Receipt) error {
+	if poID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if len(receipts) == 0 {
+		return fmt.Errorf("%w: nothing to receive", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var warehouseID int
+	var status string
+
+	err = tx.QueryRow(ctx, `SELECT warehouse_id, status FROM purchase_orders WHERE po_id = $1 FOR UPDATE`, poID).
+		Scan(&warehouseID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock purchase order %d: %w", poID, err)
+	}
+	if !poReceivable[status] {
+		return fmt.Errorf("%w: purchase order %d is %s", ErrInvalidInput, poID, status)
+	}
+
+	lines, err := getPOLines(ctx, tx, poID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*models.PurchaseOrderLine, len(lines))
+	for i := range lines {
+		byID[lines[i].LineID] = &lines[i]
+	}
+
+	for i := range receipts {
+		rc := &receipts[i]
+		if rc.POLineID == nil {
+			return fmt.Errorf("%w: receipt %d has no PO line", ErrInvalidInput, i+1)
+		}
+
+		line, ok := byID[*rc.POLineID]
+		if !ok {
+			return fmt.Errorf("%w: line %d is not on purchase order %d", ErrInvalidInput, *rc.POLineID, poID)
+		}
+		if rc.ProductID != 0 && rc.ProductID != line.ProductID {
+			return fmt.Errorf("%w: line %d is for product %d, not %d", ErrInvalidInput, line.LineID, line.ProductID, rc.ProductID)
+		}
+		rc.ProductID = line.ProductID
+		rc.WarehouseID = warehouseID
+		if rc.UnitCost == nil {
+			unitCost := line.UnitCost.Cost()
+			rc.UnitCost = &unitCost
+		}
+
+		if err := receiveStock(ctx, tx, rc); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `UPDATE purchase_order_lines SET quantity_received = quantity_received + $1 WHERE po_line_id = $2`,
+			rc.Quantity, line.LineID)
+		if err != nil {
+			return fmt.Errorf("failed to update purchase order line %d: %w", line.LineID, err)
+		}
+		line.QuantityReceived += rc.Quantity
+	}
+
+	status = models.PurchaseOrderReceived
+	for _, line := range lines {
+		if line.QuantityReceived < line.QuantityOrdered {
+			status = models.PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE purchase_orders SET status = $1, updated_at = $2 WHERE po_id = $3`, status, time.Now(), poID)
+	if err != nil {
+		return fmt.Errorf("failed to update purchase order %d: %w", poID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func getPOLines(ctx context.Context, q querier, poID int) ([]models.PurchaseOrderLine, error) {
+	sql := `
+		SELECT
+			po_line_id,
+			po_id,
+			product_id,
+			quantity_ordered,
+			quantity_received,
+			unit_cost
+		FROM purchase_order_lines
+		WHERE po_id = $1
+		ORDER BY po_line_id
+		`
+
+	rows, err := q.Query(ctx, sql, poID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lines of purchase order %d: %w", poID, err)
+	}
+
+	defer rows.Close()
+
+	var lines []models.PurchaseOrderLine
+
+	for rows.Next() {
+		var line models.PurchaseOrderLine
+
+		err := rows.Scan(&line.LineID,
+			&line.POID,
+			&line.ProductID,
+			&line.QuantityOrdered,
+			&line.QuantityReceived,
+			&line.UnitCost,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase order lines: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// stockOperationTypes are the operation types that change stock. Reserve,
+// release and move operations leave warehouse totals alone, and so does a
+// transit loss: those goods left stock when the transfer was dispatched.
+const stockOperationTypes = `('incoming', 'outgoing', 'adjustment', 'transfer_out', 'transfer_in')`
+
+// Reconcile recomputes every product's stock from its operations and
+// reports each mismatch, per warehouse and for the stored product total.
+// It is meant to be run periodically as well as on demand.
+//
+// With correct set, an adjustment operation is posted for each warehouse
+// drift. Drift comes from stock changes that were never logged, so stock is
+// left as it is and only the ledger is corrected. The adjustments are
+// costed like any other, so valuation follows the corrected ledger. A
+// product total that disagrees with its warehouse stock cannot be fixed from
+// the ledger and is only reported.
+func (r *operationRepo) Reconcile(ctx context.Context, correct bool) (*models.ReconciliationReport, error) {
+	// One snapshot for both queries, so a concurrent order cannot show up
+	// in one and not the other.
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	report := models.ReconciliationReport{
+		CheckedAt: time.Now(),
+		Corrected: correct,
+		Drifts:    []models.StockDrift{},
+	}
+	index := make(map[int]int)
+
+	sql := `WITH ledger AS (
+			SELECT product_id, SUM(change_quant)::int AS quantity
+			FROM operations
+			WHERE operation_type IN ` + stockOperationTypes + `
+			GROUP BY product_id
+		)
+		SELECT p.product_id, p.quantity, COALESCE(l.quantity, 0)
+		FROM products p
+		LEFT JOIN ledger l ON l.product_id = p.product_id
+		WHERE p.quantity <> COALESCE(l.quantity, 0)
+		ORDER BY p.product_id
+	`
+
+	rows, err := tx.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile product totals: %w", err)
+	}
+
+	for rows.Next() {
+		var d models.StockDrift
+		if err := rows.Scan(&d.ProductID, &d.Quantity, &d.LedgerQuantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan stock drift: %w", err)
+		}
+		d.Difference = d.Quantity - d.LedgerQuantity
+		index[d.ProductID] = len(report.Drifts)
+		report.Drifts = append(report.Drifts, d)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	sql = `WITH ledger AS (
+			SELECT product_id, warehouse_id, SUM(change_quant)::int AS quantity
+			FROM operations
+			WHERE operation_type IN ` + stockOperationTypes + `
+			GROUP BY product_id, warehouse_id
+		)
+		SELECT
+			COALESCE(ws.product_id, l.product_id),
+			COALESCE(ws.warehouse_id, l.warehouse_id),
+			COALESCE(ws.quantity, 0),
+			COALESCE(l.quantity, 0),
+			p.quantity
+		FROM warehouse_stock ws
+		FULL JOIN ledger l ON l.product_id = ws.product_id AND l.warehouse_id = ws.warehouse_id
+		JOIN products p ON p.product_id = COALESCE(ws.product_id, l.product_id)
+		WHERE COALESCE(ws.quantity, 0) <> COALESCE(l.quantity, 0)
+		ORDER BY 1, 2
+	`
+
+	rows, err = tx.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile warehouse stock: %w", err)
+	}
+
+	for rows.Next() {
+		var productID, productQuantity int
+		var w models.WarehouseDrift
+
+		if err := rows.Scan(&productID, &w.WarehouseID, &w.Quantity, &w.LedgerQuantity, &productQuantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan warehouse drift: %w", err)
+		}
+		w.Difference = w.Quantity - w.LedgerQuantity
+
+		i, ok := index[productID]
+		if !ok {
+			// The product total matches its ledger but drifts between
+			// warehouses cancel out.
+			i = len(report.Drifts)
+			index[productID] = i
+			report.Drifts = append(report.Drifts, models.StockDrift{
+				ProductID:      productID,
+				Quantity:       productQuantity,
+				LedgerQuantity: productQuantity,
+			})
+		}
+		report.Drifts[i].Warehouses = append(report.Drifts[i].Warehouses, w)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if !correct {
+		return &report, nil
+	}
+
+	for i := range report.Drifts {
+		for j := range report.Drifts[i].Warehouses {
+			w := &report.Drifts[i].Warehouses[j]
+
+			o := models.Operation{
+				ProductID:     report.Drifts[i].ProductID,
+				WarehouseID:   w.WarehouseID,
+				OperationType: "adjustment",
+				ChangeQuant:   w.Difference,
+			}
+			if err := costOperation(ctx, tx, &o); err != nil {
+				return nil, err
+			}
+			if err := insertOperation(ctx, tx, &o); err != nil {
+				return nil, fmt.Errorf("failed to create operation: %w", err)
+			}
+			w.OperationID = &o.OperationID
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &report, nil
+}
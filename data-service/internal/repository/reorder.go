@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StockAlertChannel is the Postgres NOTIFY channel new stock alerts are
+// published on, with the alert as JSON payload.
+const StockAlertChannel = "stock_alerts"
+
+// validateReorder checks a product's reorder settings. Parents keep their
+// stock per variant and bundles per component, so neither can have a
+// reorder point of its own.
+func validateReorder(p *models.Product, hasVariants, isBundle bool) error {
+	if p.ReorderPoint == nil {
+		if p.ReorderQuantity != 0 {
+			return fmt.Errorf("%w: reorder quantity given without a reorder point", ErrInvalidInput)
+		}
+		return nil
+	}
+	if hasVariants {
+		return fmt.Errorf("%w: product %d has variants, set reorder points on the variants", ErrInvalidInput, p.ProductID)
+	}
+	if isBundle {
+		return fmt.Errorf("%w: product %d is a bundle, set reorder points on the components", ErrInvalidInput, p.ProductID)
+	}
+	if *p.ReorderPoint < 0 {
+		return fmt.Errorf("%w: reorder point cannot be negative", ErrInvalidInput)
+	}
+	if p.ReorderQuantity <= 0 {
+		return fmt.Errorf("%w: reorder quantity must be positive", ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// checkReorderPoint raises a stock alert when a product is at or below its
+// reorder point and has no open alert, and resolves the open alert once
+// stock is back above the point. Further decrements below the point find
+// the alert still open, so it fires once per crossing. Parents and bundles
+// hold no stock of their own and are never below. Listeners are notified
+// when the transaction commits.
+func checkReorderPoint(ctx context.Context, tx pgx.Tx, productID int) error {
+	sql := `SELECT
+		p.quantity,
+		p.reorder_point,
+		p.reorder_quantity,
+		(SELECT a.alert_id FROM stock_alerts a WHERE a.product_id = p.product_id AND a.resolved_at IS NULL),
+		EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id)
+			OR EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.product_id)
+		FROM products p WHERE p.product_id = $1
+	`
+
+	var quantity, reorderQuantity int
+	var reorderPoint, openAlertID *int
+	var stockless bool
+
+	err := tx.QueryRow(ctx, sql, productID).Scan(&quantity, &reorderPoint, &reorderQuantity, &openAlertID, &stockless)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to check reorder point of product %d: %w", productID, err)
+	}
+
+	below := reorderPoint != nil && !stockless && quantity <= *reorderPoint
+
+	switch {
+	case below && openAlertID == nil:
+		alert := models.StockAlert{
+			ProductID:       productID,
+			Quantity:        quantity,
+			ReorderPoint:    *reorderPoint,
+			ReorderQuantity: reorderQuantity,
+		}
+
+		insert := `INSERT INTO stock_alerts (product_id, quantity, reorder_point, reorder_quantity)
+			VALUES ($1, $2, $3, $4)
+			RETURNING alert_id, created_at
+		`
+
+		err := tx.QueryRow(ctx, insert, alert.ProductID, alert.Quantity, alert.ReorderPoint, alert.ReorderQuantity).
+			Scan(&alert.AlertID, &alert.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to raise stock alert for product %d: %w", productID, err)
+		}
+
+		payload, err := json.Marshal(alert)
+		if err != nil {
+			return fmt.Errorf("failed to marshal stock alert: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, StockAlertChannel, string(payload)); err != nil {
+			return fmt.Errorf("failed to notify stock alert: %w", err)
+		}
+
+	case !below && openAlertID != nil:
+		_, err := tx.Exec(ctx, `UPDATE stock_alerts SET resolved_at = NOW() WHERE alert_id = $1`, *openAlertID)
+		if err != nil {
+			return fmt.Errorf("failed to resolve stock alert %d: %w", *openAlertID, err)
+		}
+	}
+
+	return nil
+}
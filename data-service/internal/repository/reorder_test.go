@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"data-service/internal/models"
+	"errors"
+	"testing"
+)
+
+func TestValidateReorder(t *testing.T) {
+	point := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		product     models.Product
+		hasVariants bool
+		isBundle    bool
+		wantErr     bool
+	}{
+		{"no reorder point", models.Product{}, false, false, false},
+		{"no reorder point on a parent", models.Product{}, true, false, false},
+		{"reorder point", models.Product{ReorderPoint: point(5), ReorderQuantity: 10}, false, false, false},
+		{"reorder point on a parent", models.Product{ReorderPoint: point(5), ReorderQuantity: 10}, true, false, true},
+		{"reorder point on a bundle", models.Product{ReorderPoint: point(5), ReorderQuantity: 10}, false, true, true},
+		{"negative reorder point", models.Product{ReorderPoint: point(-1), ReorderQuantity: 10}, false, false, true},
+		{"no reorder quantity", models.Product{ReorderPoint: point(5)}, false, false, true},
+	}
+
+	for _, tt := range tests {
+		err := validateReorder(&tt.product, tt.hasVariants, tt.isBundle)
+		if tt.wantErr && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: validateReorder = %v, want ErrInvalidInput", tt.name, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%s: validateReorder = %v, want nil", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type reservationRepo struct {
+	db *pgx.Conn
+}
+
+func NewReservationRepository(db *pgx.Conn) ReservationRepository {
+	return &reservationRepo{db: db}
+}
+
+// Create holds stock for a customer, or for a draft order, until the
+// reservation expires or an order converts it. A reservation for a draft
+// is the draft customer's, in the draft's warehouse, and is converted when
+// the draft is placed.
+func (r *reservationRepo) Create(ctx context.Context, res *models.Reservation) error {
+	if res == nil {
+		return fmt.Errorf("%w: reservation cannot be nil", ErrInvalidInput)
+	}
+	if res.ProductID <= 0 {
+		return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	if res.OrderID != nil && *res.OrderID <= 0 {
+		return fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+	if res.OrderID == nil && res.WarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if res.OrderID == nil && res.CustomerID <= 0 {
+		return fmt.Errorf("%w: customer ID must be positive", ErrInvalidInput)
+	}
+	if res.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+	}
+	if !res.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: expiry must be in the future", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if res.OrderID != nil {
+		if err := holdForDraft(ctx, tx, res); err != nil {
+			return err
+		}
+	}
+
+	// The product is locked before its stock is counted, so concurrent
+	// reservations and orders see each other's holds. Open orders that
+	// have not shipped yet hold their stock like a reservation.
+	productIDs := []int{res.ProductID}
+	if err := lockProducts(ctx, tx, productIDs); err != nil {
+		return err
+	}
+
+	stock, err := availableStock(ctx, tx, res.WarehouseID, productIDs, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	figures, exist := stock[res.ProductID]
+	if !exist {
+		return ErrProductNotFound
+	}
+	if available := figures.available(); available < res.Quantity {
+		return fmt.Errorf("%w: available %d, requested %d", ErrNotEnough, available, res.Quantity)
+	}
+
+	insert := `INSERT INTO reservations (
+		product_id,
+		warehouse_id,
+		customer_id,
+		order_id,
+		quantity,
+		status,
+		expires_at,
+		created_at
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING reservation_id
+	`
+
+	res.Status = models.ReservationActive
+	res.CreatedAt = time.Now()
+	res.ClosedAt = nil
+
+	err = tx.QueryRow(ctx, insert,
+		res.ProductID,
+		res.WarehouseID,
+		res.CustomerID,
+		nullableID(res.OrderID),
+		res.Quantity,
+		res.Status,
+		res.ExpiresAt,
+		res.CreatedAt,
+	).Scan(&res.ReservationID)
+	if err != nil {
+		return fmt.Errorf("failed to create reservation: %w", err)
+	}
+
+	err = insertOperation(ctx, tx, &models.Operation{
+		ProductID:     res.ProductID,
+		WarehouseID:   res.WarehouseID,
+		OrderID:       res.OrderID,
+		OperationType: "reserve",
+		ChangeQuant:   -res.Quantity,
+		ReservationID: &res.ReservationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// holdForDraft checks a reservation's order is a draft and fills in its
+// customer and warehouse from it.
+func holdForDraft(ctx context.Context, tx pgx.Tx, res *models.Reservation) error {
+	var customerID, warehouseID int
+	var status string
+
+	err := tx.QueryRow(ctx, `SELECT customer_id, warehouse_id, status FROM orders WHERE order_id = $1 FOR SHARE`, *res.OrderID).
+		Scan(&customerID, &warehouseID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: order %d", ErrNotFound, *res.OrderID)
+		}
+		return fmt.Errorf("failed to get order %d: %w", *res.OrderID, err)
+	}
+
+	switch {
+	case status != models.OrderDraft:
+		return fmt.Errorf("%w: order %d is %s; stock is only reserved for draft orders", ErrInvalidInput, *res.OrderID, status)
+	case res.CustomerID != 0 && res.CustomerID != customerID:
+		return fmt.Errorf("%w: order %d is for customer %d", ErrInvalidInput, *res.OrderID, customerID)
+	case res.WarehouseID != 0 && res.WarehouseID != warehouseID:
+		return fmt.Errorf("%w: order %d ships from warehouse %d", ErrInvalidInput, *res.OrderID, warehouseID)
+	}
+
+	res.CustomerID = customerID
+	res.WarehouseID = warehouseID
+
+	return nil
+}
+
+func (r *reservationRepo) GetByID(ctx context.Context, id int) (*models.Reservation, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: reservation ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		reservation_id,
+		product_id,
+		warehouse_id,
+		customer_id,
+		order_id,
+		quantity,
+		status,
+		expires_at,
+		created_at,
+		closed_at
+		FROM reservations
+		WHERE reservation_id = $1
+	`
+
+	var res models.Reservation
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&res.ReservationID,
+		&res.ProductID,
+		&res.WarehouseID,
+		&res.CustomerID,
+		&res.OrderID,
+		&res.Quantity,
+		&res.Status,
+		&res.ExpiresAt,
+		&res.CreatedAt,
+		&res.ClosedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get reservation %d: %w", id, err)
+	}
+
+	return &res, nil
+}
+
+func (r *reservationRepo) GetActiveByProductID(ctx context.Context, productID int) ([]models.Reservation, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		reservation_id,
+		product_id,
+		warehouse_id,
+		customer_id,
+		order_id,
+		quantity,
+		status,
+		expires_at,
+		created_at,
+		closed_at
+		FROM reservations
+		WHERE product_id = $1
+		AND status = 'active'
+		AND expires_at > NOW()
+		ORDER BY expires_at
+	`
+
+	rows, err := r.db.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservations by product ID %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	var reservations []models.Reservation
+
+	for rows.Next() {
+		var res models.Reservation
+
+		err := rows.Scan(&res.ReservationID,
+			&res.ProductID,
+			&res.WarehouseID,
+			&res.CustomerID,
+			&res.OrderID,
+			&res.Quantity,
+			&res.Status,
+			&res.ExpiresAt,
+			&res.CreatedAt,
+			&res.ClosedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reservations: %w", err)
+		}
+
+		reservations = append(reservations, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return reservations, nil
+}
+
+// Release cancels an active reservation and returns its quantity to
+// available stock.
+func (r *reservationRepo) Release(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: reservation ID must be positive", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT reservation_id, product_id, warehouse_id, quantity, status
+		FROM reservations
+		WHERE reservation_id = $1
+		FOR UPDATE
+	`
+
+	var res models.Reservation
+
+	err = tx.QueryRow(ctx, sql, id).Scan(&res.ReservationID, &res.ProductID, &res.WarehouseID, &res.Quantity, &res.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get reservation %d: %w", id, err)
+	}
+
+	if res.Status != models.ReservationActive {
+		return fmt.Errorf("%w: reservation %d is %s", ErrNotActive, id, res.Status)
+	}
+
+	if err := closeReservation(ctx, tx, &res, models.ReservationReleased, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseExpired closes every active reservation past its expiry and
+// reports how many were released. It is meant to be run periodically.
+func (r *reservationRepo) ReleaseExpired(ctx context.Context) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `SELECT reservation_id, product_id, warehouse_id, quantity
+		FROM reservations
+		WHERE status = 'active' AND expires_at <= NOW()
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := tx.Query(ctx, sql)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired reservations: %w", err)
+	}
+
+	var expired []models.Reservation
+
+	for rows.Next() {
+		var res models.Reservation
+		if err := rows.Scan(&res.ReservationID, &res.ProductID, &res.WarehouseID, &res.Quantity); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expired reservations: %w", err)
+		}
+		expired = append(expired, res)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for i := range expired {
+		err := closeReservation(ctx, tx, &expired[i], models.ReservationExpired, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(expired), nil
+}
+
+// convertReservation converts quantity units of a locked reservation into
+// an order. A reservation the order uses up is closed as converted; any
+// other keeps the rest, now held for the customer alone, and a release
+// operation records the part the order took.
+func convertReservation(ctx context.Context, tx pgx.Tx, res *models.Reservation, quantity, orderID int) error {
+	if quantity == res.Quantity {
+		return closeReservation(ctx, tx, res, models.ReservationConverted, &orderID)
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE reservations SET quantity = quantity - $1, order_id = NULL WHERE reservation_id = $2`,
+		quantity, res.ReservationID)
+	if err != nil {
+		return fmt.Errorf("failed to update reservation %d: %w", res.ReservationID, err)
+	}
+	res.Quantity -= quantity
+
+	if quantity == 0 {
+		return nil
+	}
+
+	err = insertOperation(ctx, tx, &models.Operation{
+		ProductID:     res.ProductID,
+		WarehouseID:   res.WarehouseID,
+		OrderID:       &orderID,
+		OperationType: "release",
+		ChangeQuant:   quantity,
+		ReservationID: &res.ReservationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return nil
+}
+
+// closeReservation moves a locked reservation out of the active state and
+// writes the matching release operation. orderID is set when the
+// reservation is converted into an order.
+func closeReservation(ctx context.Context, tx pgx.Tx, res *models.Reservation, status string, orderID *int) error {
+	update := `UPDATE reservations
+		SET status = $1,
+		order_id = $2,
+		closed_at = $3
+		WHERE reservation_id = $4
+	`
+
+	_, err := tx.Exec(ctx, update, status, nullableID(orderID), time.Now(), res.ReservationID)
+	if err != nil {
+		return fmt.Errorf("failed to update reservation %d: %w", res.ReservationID, err)
+	}
+
+	err = insertOperation(ctx, tx, &models.Operation{
+		ProductID:     res.ProductID,
+		WarehouseID:   res.WarehouseID,
+		OrderID:       orderID,
+		OperationType: "release",
+		ChangeQuant:   res.Quantity,
+		ReservationID: &res.ReservationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,703 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var returnOutcomes = map[string]bool{
+	models.ReturnRestock: true,
+	models.ReturnDamaged: true,
+	models.ReturnScrap:   true,
+}
+
+type returnRepo struct {
+	db *pgx.Conn
+}
+
+func NewReturnRepository(db *pgx.Conn) ReturnRepository {
+	return &returnRepo{db: db}
+}
+
+// Create authorizes a return against a shipped order. A line can only
+// bring back what was shipped on it less what other returns already cover;
+// goods that never arrived on a received return can be authorized again.
+// The goods come back to the order's warehouse unless another is given.
+func (r *returnRepo) Create(ctx context.Context, ret *models.Return) error {
+	if ret == nil {
+		return fmt.Errorf("%w: return cannot be nil", ErrInvalidInput)
+	}
+	if ret.OrderID <= 0 {
+		return fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+	if ret.WarehouseID < 0 {
+		return fmt.Errorf("%w: warehouse ID cannot be negative", ErrInvalidInput)
+	}
+	if len(ret.Lines) == 0 {
+		return fmt.Errorf("%w: return needs at least one line", ErrInvalidInput)
+	}
+
+	seen := make(map[int]bool, len(ret.Lines))
+	for _, line := range ret.Lines {
+		if line.OrderItemID <= 0 {
+			return fmt.Errorf("%w: order item ID must be positive", ErrInvalidInput)
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+		}
+		if seen[line.OrderItemID] {
+			return fmt.Errorf("%w: order item %d listed twice", ErrInvalidInput, line.OrderItemID)
+		}
+		seen[line.OrderItemID] = true
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var orderWarehouseID int
+	var status string
+
+	err = tx.QueryRow(ctx, `SELECT warehouse_id, status FROM orders WHERE order_id = $1 FOR UPDATE`, ret.OrderID).
+		Scan(&orderWarehouseID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock order %d: %w", ret.OrderID, err)
+	}
+	if status != models.OrderShipped && status != models.OrderPartiallyShipped {
+		return fmt.Errorf("%w: order %d is %s, only shipped orders can be returned", ErrInvalidInput, ret.OrderID, status)
+	}
+	if ret.WarehouseID == 0 {
+		ret.WarehouseID = orderWarehouseID
+	}
+
+	returnable := `SELECT
+		oi.product_id,
+		oi.quantity_shipped - COALESCE((
+			SELECT SUM(COALESCE(rl.quantity_received, rl.quantity))
+			FROM return_lines rl
+			JOIN returns rt ON rt.return_id = rl.return_id
+			WHERE rl.order_item_id = oi.order_item_id AND rt.status <> $3
+		), 0)::int
+		FROM order_items oi
+		WHERE oi.order_item_id = $1 AND oi.order_id = $2
+	`
+
+	for i := range ret.Lines {
+		line := &ret.Lines[i]
+
+		var left int
+		err := tx.QueryRow(ctx, returnable, line.OrderItemID, ret.OrderID, models.ReturnCancelled).Scan(&line.ProductID, &left)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: order item %d is not on order %d", ErrInvalidInput, line.OrderItemID, ret.OrderID)
+			}
+			return fmt.Errorf("failed to get returnable quantity of order item %d: %w", line.OrderItemID, err)
+		}
+		if line.Quantity > left {
+			return fmt.Errorf("%w: only %d units of order item %d can be returned", ErrNotEnough, left, line.OrderItemID)
+		}
+	}
+
+	insert := `INSERT INTO returns (
+		order_id,
+		warehouse_id,
+		status,
+		reason,
+		created_at
+	) VALUES ($1, $2, $3, $4, $5)
+	RETURNING return_id
+	`
+
+	ret.Reason = strings.TrimSpace(ret.Reason)
+	ret.Status = models.ReturnAuthorized
+	ret.RefundAmount = 0
+	ret.CreatedAt = time.Now()
+	ret.ReceivedAt = nil
+
+	err = tx.QueryRow(ctx, insert, ret.OrderID, ret.WarehouseID, ret.Status, ret.Reason, ret.CreatedAt).Scan(&ret.ReturnID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%w: warehouse %d not found", ErrInvalidInput, ret.WarehouseID)
+		}
+		return fmt.Errorf("failed to create return: %w", err)
+	}
+
+	insertLine := `INSERT INTO return_lines (return_id, order_item_id, quantity)
+		VALUES ($1, $2, $3)
+		RETURNING return_line_id
+	`
+
+	for i := range ret.Lines {
+		line := &ret.Lines[i]
+		line.ReturnID = ret.ReturnID
+		line.QuantityReceived = nil
+		line.RefundAmount = 0
+		line.Inspections = nil
+
+		err := tx.QueryRow(ctx, insertLine, ret.ReturnID, line.OrderItemID, line.Quantity).Scan(&line.LineID)
+		if err != nil {
+			return fmt.Errorf("failed to create return line: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *returnRepo) GetByID(ctx context.Context, id int) (*models.Return, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			return_id,
+			order_id,
+			warehouse_id,
+			status,
+			COALESCE(reason, ''),
+			refund_amount,
+			created_at,
+			received_at
+		FROM returns WHERE return_id = $1
+		`
+
+	var ret models.Return
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&ret.ReturnID,
+		&ret.OrderID,
+		&ret.WarehouseID,
+		&ret.Status,
+		&ret.Reason,
+		&ret.RefundAmount,
+		&ret.CreatedAt,
+		&ret.ReceivedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get return by id %d: %w", id, err)
+	}
+
+	ret.Lines, err = getReturnLines(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadInspections(ctx, ret.Lines); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// GetByOrder lists the returns of an order without their lines.
+func (r *returnRepo) GetByOrder(ctx context.Context, orderID int) ([]models.Return, error) {
+	if orderID <= 0 {
+		return nil, fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			return_id,
+			order_id,
+			warehouse_id,
+			status,
+			COALESCE(reason, ''),
+			refund_amount,
+			created_at,
+			received_at
+		FROM returns
+		WHERE order_id = $1
+		ORDER BY return_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get returns of order %d: %w", orderID, err)
+	}
+
+	defer rows.Close()
+
+	var returns []models.Return
+
+	for rows.Next() {
+		var ret models.Return
+
+		err := rows.Scan(&ret.ReturnID,
+			&ret.OrderID,
+			&ret.WarehouseID,
+			&ret.Status,
+			&ret.Reason,
+			&ret.RefundAmount,
+			&ret.CreatedAt,
+			&ret.ReceivedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan returns: %w", err)
+		}
+		returns = append(returns, ret)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return returns, nil
+}
+
+func getReturnLines(ctx context.Context, q querier, returnID int) ([]models.ReturnLine, error) {
+	sql := `
+		SELECT
+			rl.return_line_id,
+			rl.return_id,
+			rl.order_item_id,
+			oi.product_id,
+			rl.quantity,
+			rl.quantity_received,
+			rl.refund_amount
+		FROM return_lines rl
+		JOIN order_items oi ON oi.order_item_id = rl.order_item_id
+		WHERE rl.return_id = $1
+		ORDER BY rl.return_line_id
+		`
+
+	rows, err := q.Query(ctx, sql, returnID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lines of return %d: %w", returnID, err)
+	}
+
+	defer rows.Close()
+
+	var lines []models.ReturnLine
+
+	for rows.Next() {
+		var line models.ReturnLine
+
+		err := rows.Scan(&line.LineID,
+			&line.ReturnID,
+			&line.OrderItemID,
+			&line.ProductID,
+			&line.Quantity,
+			&line.QuantityReceived,
+			&line.RefundAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan return lines: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lines, nil
+}
+
+func (r *returnRepo) loadInspections(ctx context.Context, lines []models.ReturnLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(lines))
+	ids := make([]int, 0, len(lines))
+	for i, line := range lines {
+		index[line.LineID] = i
+		ids = append(ids, line.LineID)
+	}
+
+	sql := `SELECT inspection_id, return_line_id, outcome, quantity, to_location_id, created_at
+		FROM return_inspections
+		WHERE return_line_id = ANY($1::int[])
+		ORDER BY inspection_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get return inspections: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var in models.ReturnInspection
+		err := rows.Scan(&in.InspectionID, &in.ReturnLineID, &in.Outcome, &in.Quantity, &in.ToLocationID, &in.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan return inspections: %w", err)
+		}
+		i := index[in.ReturnLineID]
+		lines[i].Inspections = append(lines[i].Inspections, in)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return nil
+}
+
+// lockReturn locks an authorized return.
+func lockReturn(ctx context.Context, tx pgx.Tx, id int) (*models.Return, error) {
+	var ret models.Return
+
+	err := tx.QueryRow(ctx, `SELECT return_id, order_id, warehouse_id, status
+		FROM returns WHERE return_id = $1 FOR UPDATE`, id).
+		Scan(&ret.ReturnID, &ret.OrderID, &ret.WarehouseID, &ret.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock return %d: %w", id, err)
+	}
+	if ret.Status != models.ReturnAuthorized {
+		return nil, fmt.Errorf("%w: return %d is %s", ErrInvalidInput, id, ret.Status)
+	}
+
+	return &ret, nil
+}
+
+// returnedLine is a return line with what Receive needs of its order line.
+type returnedLine struct {
+	models.ReturnLine
+	charged    models.Money
+	ordered    int
+	serialized bool
+	received   int
+	damaged    int
+}
+
+// Receive books the arrival of an authorized return. Each inspection gives
+// the outcome for some units of a line; units not inspected did not
+// arrive. Restocked units go back into the return's warehouse at the cost
+// they left with, under the lots they were shipped from where those are in
+// that warehouse, and are written as incoming operations on the order.
+// Damaged and scrapped units do not re-enter stock; serialized ones are
+// marked scrapped.
+//
+// Each line is refunded at what a unit of the order line was charged,
+// discounts and tax included, for every unit that arrived except damaged
+// ones, which the customer is answerable for.
+func (r *returnRepo) Receive(ctx context.Context, id int, inspections []models.ReturnInspection) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ret, err := lockReturn(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	sql := `SELECT
+		rl.return_line_id,
+		rl.order_item_id,
+		rl.quantity,
+		oi.product_id,
+		oi.gross_amount,
+		oi.quantity,
+		p.is_serialized
+		FROM return_lines rl
+		JOIN order_items oi ON oi.order_item_id = rl.order_item_id
+		JOIN products p ON p.product_id = oi.product_id
+		WHERE rl.return_id = $1
+		ORDER BY rl.return_line_id
+	`
+
+	rows, err := tx.Query(ctx, sql, id)
+	if err != nil {
+		return fmt.Errorf("failed to get lines of return %d: %w", id, err)
+	}
+
+	var lines []*returnedLine
+	index := make(map[int]*returnedLine)
+	for rows.Next() {
+		line := &returnedLine{}
+		err := rows.Scan(&line.LineID, &line.OrderItemID, &line.Quantity, &line.ProductID, &line.charged, &line.ordered, &line.serialized)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan return lines: %w", err)
+		}
+		lines = append(lines, line)
+		index[line.LineID] = line
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	serials := make(map[string]bool)
+	for i := range inspections {
+		in := &inspections[i]
+
+		line, ok := index[in.ReturnLineID]
+		if !ok {
+			return fmt.Errorf("%w: line %d is not on return %d", ErrInvalidInput, in.ReturnLineID, id)
+		}
+		if !returnOutcomes[in.Outcome] {
+			return fmt.Errorf("%w: invalid outcome '%s'", ErrInvalidInput, in.Outcome)
+		}
+		if in.ToLocationID != nil && in.Outcome != models.ReturnRestock {
+			return fmt.Errorf("%w: only restocked units are put away", ErrInvalidInput)
+		}
+
+		if line.serialized {
+			if in.Quantity == 0 {
+				in.Quantity = len(in.Serials)
+			}
+			if len(in.Serials) != in.Quantity {
+				return fmt.Errorf("%w: %d serial numbers given for %d units", ErrInvalidInput, len(in.Serials), in.Quantity)
+			}
+			for j, serial := range in.Serials {
+				serial = strings.TrimSpace(serial)
+				if serial == "" {
+					return fmt.Errorf("%w: serial number cannot be empty", ErrInvalidInput)
+				}
+				key := fmt.Sprintf("%d/%s", line.ProductID, serial)
+				if serials[key] {
+					return fmt.Errorf("%w: serial number %s listed twice", ErrInvalidInput, serial)
+				}
+				serials[key] = true
+				in.Serials[j] = serial
+			}
+		} else if len(in.Serials) > 0 {
+			return fmt.Errorf("%w: product %d is not serialized", ErrInvalidInput, line.ProductID)
+		}
+
+		if in.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+		}
+
+		line.received += in.Quantity
+		if line.received > line.Quantity {
+			return fmt.Errorf("%w: line %d authorizes only %d units", ErrInvalidInput, line.LineID, line.Quantity)
+		}
+		if in.Outcome == models.ReturnDamaged {
+			line.damaged += in.Quantity
+		}
+	}
+
+	productIDs := make([]int, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+	}
+
+	bundles, err := bundleComponents(ctx, tx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO return_inspections (return_line_id, outcome, quantity, to_location_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING inspection_id
+	`
+
+	now := time.Now()
+
+	for i := range inspections {
+		in := &inspections[i]
+		line := index[in.ReturnLineID]
+		in.CreatedAt = now
+
+		err := tx.QueryRow(ctx, insert, in.ReturnLineID, in.Outcome, in.Quantity, nullableID(in.ToLocationID), in.CreatedAt).
+			Scan(&in.InspectionID)
+		if err != nil {
+			return fmt.Errorf("failed to record inspection of return line %d: %w", in.ReturnLineID, err)
+		}
+
+		if in.Outcome != models.ReturnRestock {
+			if line.serialized {
+				if err := scrapReturnedSerials(ctx, tx, line.OrderItemID, line.ProductID, in.Serials); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if components, ok := bundles[line.ProductID]; ok {
+			for _, c := range components {
+				err := restockReturned(ctx, tx, ret, line.OrderItemID, c.ProductID, in.Quantity*c.Quantity, in.ToLocationID, nil)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		err = restockReturned(ctx, tx, ret, line.OrderItemID, line.ProductID, in.Quantity, in.ToLocationID, in.Serials)
+		if err != nil {
+			return err
+		}
+	}
+
+	var total models.Money
+	for _, line := range lines {
+		refund := line.charged.Share(int64(line.received-line.damaged), int64(line.ordered))
+		total += refund
+
+		_, err := tx.Exec(ctx, `UPDATE return_lines SET quantity_received = $1, refund_amount = $2 WHERE return_line_id = $3`,
+			line.received, refund, line.LineID)
+		if err != nil {
+			return fmt.Errorf("failed to update return line %d: %w", line.LineID, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE returns SET status = $1, refund_amount = $2, received_at = $3 WHERE return_id = $4`,
+		models.ReturnReceived, total, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to update return %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// restockReturned receives returned units of one product back into stock.
+// Like a transfer, the quantity is split over the lots the order line
+// shipped from, as far as those lots are in the return's warehouse, and
+// valued at what the order's outgoing operations cost.
+func restockReturned(ctx context.Context, tx pgx.Tx, ret *models.Return, orderItemID, productID, quantity int, toLocationID *int, serials []string) error {
+	sql := `SELECT
+		l.lot_number,
+		l.manufactured_at,
+		l.expires_at,
+		oil.quantity
+		FROM order_item_lots oil
+		JOIN lots l ON l.lot_id = oil.lot_id
+		WHERE oil.order_item_id = $1 AND l.product_id = $2 AND l.warehouse_id = $3
+		ORDER BY l.expires_at NULLS LAST, l.lot_id
+	`
+
+	rows, err := tx.Query(ctx, sql, orderItemID, productID, ret.WarehouseID)
+	if err != nil {
+		return fmt.Errorf("failed to get lots of order item %d: %w", orderItemID, err)
+	}
+
+	var parts []models.Receipt
+	for rows.Next() {
+		var part models.Receipt
+		if err := rows.Scan(&part.LotNumber, &part.ManufacturedAt, &part.ExpiresAt, &part.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan order item lots: %w", err)
+		}
+		parts = append(parts, part)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	var unitCost *models.Cost
+	err = tx.QueryRow(ctx, `SELECT SUM(cost_amount) / SUM(change_quant)
+		FROM operations
+		WHERE order_id = $1 AND product_id = $2 AND operation_type = 'outgoing'`,
+		ret.OrderID, productID).Scan(&unitCost)
+	if err != nil {
+		return fmt.Errorf("failed to get order cost of product %d: %w", productID, err)
+	}
+
+	remaining := quantity
+	for i := range parts {
+		parts[i].Quantity = min(parts[i].Quantity, remaining)
+		remaining -= parts[i].Quantity
+	}
+	if remaining > 0 {
+		parts = append(parts, models.Receipt{Quantity: remaining})
+	}
+
+	for _, part := range parts {
+		if part.Quantity == 0 {
+			continue
+		}
+
+		part.ProductID = productID
+		part.WarehouseID = ret.WarehouseID
+		part.ToLocationID = toLocationID
+		part.OrderID = &ret.OrderID
+		part.UnitCost = unitCost
+		if serials != nil {
+			part.Serials = serials[:part.Quantity]
+			serials = serials[part.Quantity:]
+		}
+
+		if err := receiveStock(ctx, tx, &part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scrapReturnedSerials marks returned units that are not restocked as
+// scrapped.
+func scrapReturnedSerials(ctx context.Context, tx pgx.Tx, orderItemID, productID int, serials []string) error {
+	for _, serial := range serials {
+		result, err := tx.Exec(ctx, `UPDATE serial_numbers SET status = $1
+			WHERE product_id = $2 AND serial_number = $3 AND status = $4 AND order_item_id = $5`,
+			models.SerialScrapped, productID, serial, models.SerialSold, orderItemID)
+		if err != nil {
+			return fmt.Errorf("failed to scrap serial %s: %w", serial, err)
+		}
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("%w: serial %s was not sold on order item %d", ErrInvalidInput, serial, orderItemID)
+		}
+	}
+
+	return nil
+}
+
+// Cancel drops a return whose goods have not arrived.
+func (r *returnRepo) Cancel(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockReturn(ctx, tx, id); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE returns SET status = $1 WHERE return_id = $2`, models.ReturnCancelled, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel return %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type serialRepo struct {
+	db *pgx.Conn
+}
+
+func NewSerialRepository(db *pgx.Conn) SerialRepository {
+	return &serialRepo{db: db}
+}
+
+func (r *serialRepo) GetByNumber(ctx context.Context, productID int, serialNumber string) (*models.SerialNumber, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+	serialNumber = strings.TrimSpace(serialNumber)
+	if serialNumber == "" {
+		return nil, fmt.Errorf("%w: serial number cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		serial_id,
+		product_id,
+		serial_number,
+		warehouse_id,
+		lot_id,
+		status,
+		order_item_id,
+		created_at
+		FROM serial_numbers
+		WHERE product_id = $1 AND serial_number = $2
+	`
+
+	var s models.SerialNumber
+
+	err := r.db.QueryRow(ctx, sql, productID, serialNumber).Scan(
+		&s.SerialID,
+		&s.ProductID,
+		&s.SerialNumber,
+		&s.WarehouseID,
+		&s.LotID,
+		&s.Status,
+		&s.OrderItemID,
+		&s.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get serial %s: %w", serialNumber, err)
+	}
+
+	return &s, nil
+}
+
+func (r *serialRepo) GetInStock(ctx context.Context, productID int) ([]models.SerialNumber, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `SELECT
+		serial_id,
+		product_id,
+		serial_number,
+		warehouse_id,
+		lot_id,
+		status,
+		order_item_id,
+		created_at
+		FROM serial_numbers
+		WHERE product_id = $1 AND status = 'in_stock'
+		ORDER BY warehouse_id, serial_number
+	`
+
+	rows, err := r.db.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serials for product %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	var serials []models.SerialNumber
+
+	for rows.Next() {
+		var s models.SerialNumber
+
+		err := rows.Scan(&s.SerialID,
+			&s.ProductID,
+			&s.SerialNumber,
+			&s.WarehouseID,
+			&s.LotID,
+			&s.Status,
+			&s.OrderItemID,
+			&s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan serials: %w", err)
+		}
+		serials = append(serials, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return serials, nil
+}
+
+// GetHistory rebuilds a unit's life (received, sold, returned, ...) from
+// the ledger operations linked to it.
+func (r *serialRepo) GetHistory(ctx context.Context, productID int, serialNumber string) (*models.SerialHistory, error) {
+	serial, err := r.GetByNumber(ctx, productID, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := `SELECT
+		o.operation_id,
+		o.product_id,
+		o.warehouse_id,
+		o.order_id,
+		o.operation_type,
+		o.change_quant,
+		o.reservation_id,
+		o.from_location_id,
+		o.to_location_id,
+		o.lot_id,
+		COALESCE(o.unit, ''),
+		COALESCE(o.unit_quant, 0),
+		o.po_line_id,
+		o.transfer_id,
+		o.unit_cost,
+		o.cost_amount,
+		o.created_at
+		FROM operations o
+		JOIN operation_serials os ON os.operation_id = o.operation_id
+		WHERE os.serial_id = $1
+		ORDER BY o.created_at, o.operation_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, serial.SerialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of serial %s: %w", serialNumber, err)
+	}
+
+	defer rows.Close()
+
+	history := models.SerialHistory{Serial: *serial}
+
+	for rows.Next() {
+		var o models.Operation
+
+		err := rows.Scan(&o.OperationID,
+			&o.ProductID,
+			&o.WarehouseID,
+			&o.OrderID,
+			&o.OperationType,
+			&o.ChangeQuant,
+			&o.ReservationID,
+			&o.FromLocationID,
+			&o.ToLocationID,
+			&o.LotID,
+			&o.Unit,
+			&o.UnitQuant,
+			&o.POLineID,
+			&o.TransferID,
+			&o.UnitCost,
+			&o.CostAmount,
+			&o.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan serial history: %w", err)
+		}
+		history.Operations = append(history.Operations, o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return &history, nil
+}
+
+func checkReceiptSerials(rc *models.Receipt, serialized bool) error {
+	if !serialized {
+		if len(rc.Serials) > 0 {
+			return fmt.Errorf("%w: product %d is not serialized", ErrInvalidInput, rc.ProductID)
+		}
+		return nil
+	}
+
+	return checkSerialNumbers(rc.Serials, rc.Quantity)
+}
+
+// checkSerialNumbers checks there is one serial number per unit, trims them
+// and refuses blanks and repeats.
+func checkSerialNumbers(serials []string, quantity int) error {
+	if len(serials) != quantity {
+		return fmt.Errorf("%w: %d serial numbers given for %d units", ErrInvalidInput, len(serials), quantity)
+	}
+
+	seen := make(map[string]bool, len(serials))
+	for i, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return fmt.Errorf("%w: serial number cannot be empty", ErrInvalidInput)
+		}
+		if seen[serial] {
+			return fmt.Errorf("%w: serial number %s listed twice", ErrInvalidInput, serial)
+		}
+		seen[serial] = true
+		serials[i] = serial
+	}
+
+	return nil
+}
+
+// registerSerials records the units of a receipt and links them to its
+// incoming operation.
+func registerSerials(ctx context.Context, tx pgx.Tx, rc *models.Receipt) error {
+	insert := `INSERT INTO serial_numbers (
+		product_id,
+		serial_number,
+		warehouse_id,
+		lot_id,
+		status,
+		created_at
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING serial_id
+	`
+
+	now := time.Now()
+
+	for _, serial := range rc.Serials {
+		var serialID int
+		err := tx.QueryRow(ctx, insert,
+			rc.ProductID,
+			serial,
+			rc.WarehouseID,
+			nullableID(rc.LotID),
+			models.SerialInStock,
+			now,
+		).Scan(&serialID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return fmt.Errorf("%w: serial number %s already registered", ErrDuplicate, serial)
+			}
+			return fmt.Errorf("failed to register serial %s: %w", serial, err)
+		}
+
+		if err := linkSerial(ctx, tx, rc.OperationID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restockSerials puts units sold on rc.OrderID back in stock in the
+// receipt's warehouse and links them to its incoming operation.
+func restockSerials(ctx context.Context, tx pgx.Tx, rc *models.Receipt) error {
+	sql := `UPDATE serial_numbers
+		SET status = $1, warehouse_id = $2, lot_id = $3, order_item_id = NULL
+		WHERE product_id = $4 AND serial_number = $5 AND status = $6
+		AND order_item_id IN (SELECT order_item_id FROM order_items WHERE order_id = $7)
+		RETURNING serial_id
+	`
+
+	for _, serial := range rc.Serials {
+		var serialID int
+		err := tx.QueryRow(ctx, sql,
+			models.SerialInStock,
+			rc.WarehouseID,
+			nullableID(rc.LotID),
+			rc.ProductID,
+			serial,
+			models.SerialSold,
+			*rc.OrderID,
+		).Scan(&serialID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: serial %s was not sold on order %d", ErrInvalidInput, serial, *rc.OrderID)
+			}
+			return fmt.Errorf("failed to restock serial %s: %w", serial, err)
+		}
+
+		if err := linkSerial(ctx, tx, rc.OperationID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// arriveSerials books units in transit on rc.TransferID into the receipt's
+// warehouse and links them to its transfer_in operation.
+func arriveSerials(ctx context.Context, tx pgx.Tx, rc *models.Receipt) error {
+	sql := `UPDATE serial_numbers
+		SET status = $1, warehouse_id = $2, lot_id = $3
+		WHERE product_id = $4 AND serial_number = $5 AND status = $6
+		AND serial_id IN (
+			SELECT tls.serial_id FROM transfer_line_serials tls
+			JOIN transfer_lines tl ON tl.transfer_line_id = tls.transfer_line_id
+			WHERE tl.transfer_id = $7
+		)
+		RETURNING serial_id
+	`
+
+	for _, serial := range rc.Serials {
+		var serialID int
+		err := tx.QueryRow(ctx, sql,
+			models.SerialInStock,
+			rc.WarehouseID,
+			nullableID(rc.LotID),
+			rc.ProductID,
+			serial,
+			models.SerialInTransit,
+			*rc.TransferID,
+		).Scan(&serialID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: serial %s is not in transit on transfer %d", ErrInvalidInput, serial, *rc.TransferID)
+			}
+			return fmt.Errorf("failed to receive serial %s: %w", serial, err)
+		}
+
+		if err := linkSerial(ctx, tx, rc.OperationID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkSerials links units to the operations that moved them, filling each
+// operation up to its quantity in turn.
+func linkSerials(ctx context.Context, tx pgx.Tx, ops []models.Operation, serialIDs []int) error {
+	for _, o := range ops {
+		n := min(max(o.ChangeQuant, -o.ChangeQuant), len(serialIDs))
+		for _, serialID := range serialIDs[:n] {
+			if err := linkSerial(ctx, tx, o.OperationID, serialID); err != nil {
+				return err
+			}
+		}
+		serialIDs = serialIDs[n:]
+	}
+
+	return nil
+}
+
+func linkSerial(ctx context.Context, tx pgx.Tx, operationID, serialID int) error {
+	_, err := tx.Exec(ctx, `INSERT INTO operation_serials (operation_id, serial_id) VALUES ($1, $2)`, operationID, serialID)
+	if err != nil {
+		return fmt.Errorf("failed to link serial %d to operation %d: %w", serialID, operationID, err)
+	}
+
+	return nil
+}
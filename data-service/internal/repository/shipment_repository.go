@@ -0,0 +1,552 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type shipmentRepo struct {
+	db *pgx.Conn
+}
+
+func NewShipmentRepository(db *pgx.Conn) ShipmentRepository {
+	return &shipmentRepo{db: db}
+}
+
+// canShip reports whether an order in this status may send out parcels.
+func canShip(status string) bool {
+	return status == models.OrderPaid || status == models.OrderPartiallyShipped
+}
+
+// Create drafts a parcel for a paid order. Lines may not exceed what is
+// left to ship on them; this is checked again on confirmation, since other
+// parcels may be confirmed in between.
+func (r *shipmentRepo) Create(ctx context.Context, s *models.Shipment) error {
+	if s == nil {
+		return fmt.Errorf("%w: shipment cannot be nil", ErrInvalidInput)
+	}
+	if s.OrderID <= 0 {
+		return fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+	if len(s.Lines) == 0 {
+		return fmt.Errorf("%w: shipment needs at least one line", ErrInvalidInput)
+	}
+
+	seen := make(map[int]bool, len(s.Lines))
+	for _, line := range s.Lines {
+		if line.OrderItemID <= 0 {
+			return fmt.Errorf("%w: order item ID must be positive", ErrInvalidInput)
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+		}
+		if seen[line.OrderItemID] {
+			return fmt.Errorf("%w: order item %d listed twice", ErrInvalidInput, line.OrderItemID)
+		}
+		seen[line.OrderItemID] = true
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := lockOrder(ctx, tx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	if !canShip(order.Status) {
+		return fmt.Errorf("%w: order %d is %s", ErrInvalidTransition, s.OrderID, order.Status)
+	}
+
+	for i := range s.Lines {
+		line := &s.Lines[i]
+
+		var left int
+		err := tx.QueryRow(ctx, `SELECT product_id, quantity - quantity_shipped
+			FROM order_items WHERE order_item_id = $1 AND order_id = $2`,
+			line.OrderItemID, s.OrderID).Scan(&line.ProductID, &left)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: order item %d is not on order %d", ErrInvalidInput, line.OrderItemID, s.OrderID)
+			}
+			return fmt.Errorf("failed to get order item %d: %w", line.OrderItemID, err)
+		}
+		if line.Quantity > left {
+			return fmt.Errorf("%w: only %d units of order item %d are left to ship", ErrInvalidInput, left, line.OrderItemID)
+		}
+	}
+
+	s.Status = models.ShipmentDraft
+	s.ConfirmedAt = nil
+	if err := insertShipment(ctx, tx, s); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func insertShipment(ctx context.Context, tx pgx.Tx, s *models.Shipment) error {
+	insert := `INSERT INTO shipments (
+		order_id,
+		status,
+		carrier,
+		tracking_number,
+		created_at,
+		confirmed_at
+	) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+	RETURNING shipment_id
+	`
+
+	s.Carrier = strings.TrimSpace(s.Carrier)
+	s.TrackingNumber = strings.TrimSpace(s.TrackingNumber)
+	s.CreatedAt = time.Now()
+
+	err := tx.QueryRow(ctx, insert, s.OrderID, s.Status, s.Carrier, s.TrackingNumber, s.CreatedAt, s.ConfirmedAt).
+		Scan(&s.ShipmentID)
+	if err != nil {
+		return fmt.Errorf("failed to create shipment: %w", err)
+	}
+
+	insertLine := `INSERT INTO shipment_lines (shipment_id, order_item_id, quantity)
+		VALUES ($1, $2, $3)
+		RETURNING shipment_line_id
+	`
+
+	for i := range s.Lines {
+		line := &s.Lines[i]
+		line.ShipmentID = s.ShipmentID
+
+		err := tx.QueryRow(ctx, insertLine, s.ShipmentID, line.OrderItemID, line.Quantity).Scan(&line.LineID)
+		if err != nil {
+			return fmt.Errorf("failed to create shipment line: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *shipmentRepo) GetByID(ctx context.Context, id int) (*models.Shipment, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			shipment_id,
+			order_id,
+			status,
+			COALESCE(carrier, ''),
+			COALESCE(tracking_number, ''),
+			created_at,
+			confirmed_at
+		FROM shipments WHERE shipment_id = $1
+		`
+
+	var s models.Shipment
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&s.ShipmentID,
+		&s.OrderID,
+		&s.Status,
+		&s.Carrier,
+		&s.TrackingNumber,
+		&s.CreatedAt,
+		&s.ConfirmedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get shipment by id %d: %w", id, err)
+	}
+
+	s.Lines, err = getShipmentLines(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetByOrder lists the shipments of an order without their lines.
+func (r *shipmentRepo) GetByOrder(ctx context.Context, orderID int) ([]models.Shipment, error) {
+	if orderID <= 0 {
+		return nil, fmt.Errorf("%w: order ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			shipment_id,
+			order_id,
+			status,
+			COALESCE(carrier, ''),
+			COALESCE(tracking_number, ''),
+			created_at,
+			confirmed_at
+		FROM shipments
+		WHERE order_id = $1
+		ORDER BY shipment_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipments of order %d: %w", orderID, err)
+	}
+
+	defer rows.Close()
+
+	var shipments []models.Shipment
+
+	for rows.Next() {
+		var s models.Shipment
+
+		err := rows.Scan(&s.ShipmentID,
+			&s.OrderID,
+			&s.Status,
+			&s.Carrier,
+			&s.TrackingNumber,
+			&s.CreatedAt,
+			&s.ConfirmedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipments: %w", err)
+		}
+		shipments = append(shipments, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return shipments, nil
+}
+
+func getShipmentLines(ctx context.Context, q querier, shipmentID int) ([]models.ShipmentLine, error) {
+	sql := `
+		SELECT
+			sl.shipment_line_id,
+			sl.shipment_id,
+			sl.order_item_id,
+			oi.product_id,
+			sl.quantity
+		FROM shipment_lines sl
+		JOIN order_items oi ON oi.order_item_id = sl.order_item_id
+		WHERE sl.shipment_id = $1
+		ORDER BY sl.shipment_line_id
+		`
+
+	rows, err := q.Query(ctx, sql, shipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lines of shipment %d: %w", shipmentID, err)
+	}
+
+	defer rows.Close()
+
+	var lines []models.ShipmentLine
+
+	for rows.Next() {
+		var line models.ShipmentLine
+
+		err := rows.Scan(&line.LineID,
+			&line.ShipmentID,
+			&line.OrderItemID,
+			&line.ProductID,
+			&line.Quantity,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipment lines: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lines, nil
+}
+
+// lockShipment locks a draft shipment.
+func lockShipment(ctx context.Context, tx pgx.Tx, id int) (*models.Shipment, error) {
+	var s models.Shipment
+
+	err := tx.QueryRow(ctx, `SELECT shipment_id, order_id, status FROM shipments WHERE shipment_id = $1 FOR UPDATE`, id).
+		Scan(&s.ShipmentID, &s.OrderID, &s.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock shipment %d: %w", id, err)
+	}
+	if s.Status != models.ShipmentDraft {
+		return nil, fmt.Errorf("%w: shipment %d is %s", ErrInvalidInput, id, s.Status)
+	}
+
+	return &s, nil
+}
+
+// Confirm sends a drafted parcel: its lines count as shipped and the order
+// becomes partially_shipped or shipped, recorded as changed by confirmedBy.
+func (r *shipmentRepo) Confirm(ctx context.Context, id int, confirmedBy string) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := lockShipment(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	order, err := lockOrder(ctx, tx, s.OrderID)
+	if err != nil {
+		return err
+	}
+	if !canShip(order.Status) {
+		return fmt.Errorf("%w: order %d is %s", ErrInvalidTransition, order.OrderID, order.Status)
+	}
+
+	lines, err := getShipmentLines(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := shipLines(ctx, tx, order, lines); err != nil {
+		return err
+	}
+
+	var complete bool
+	err = tx.QueryRow(ctx, `SELECT bool_and(quantity_shipped = quantity) FROM order_items WHERE order_id = $1`, order.OrderID).
+		Scan(&complete)
+	if err != nil {
+		return fmt.Errorf("failed to get shipped quantities of order %d: %w", order.OrderID, err)
+	}
+
+	status := models.OrderPartiallyShipped
+	if complete {
+		status = models.OrderShipped
+	}
+	if status != order.Status {
+		if err := setOrderStatus(ctx, tx, order, status, confirmedBy); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE shipments SET status = $1, confirmed_at = $2 WHERE shipment_id = $3`,
+		models.ShipmentConfirmed, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// shipRemaining sends everything not yet shipped on a locked order as one
+// confirmed shipment.
+func shipRemaining(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	rows, err := tx.Query(ctx, `SELECT order_item_id, product_id, quantity - quantity_shipped
+		FROM order_items
+		WHERE order_id = $1 AND quantity > quantity_shipped
+		ORDER BY order_item_id`, order.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get unshipped lines of order %d: %w", order.OrderID, err)
+	}
+
+	s := models.Shipment{OrderID: order.OrderID, Status: models.ShipmentConfirmed}
+	for rows.Next() {
+		var line models.ShipmentLine
+		if err := rows.Scan(&line.OrderItemID, &line.ProductID, &line.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan unshipped lines: %w", err)
+		}
+		s.Lines = append(s.Lines, line)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	if len(s.Lines) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	s.ConfirmedAt = &now
+	if err := insertShipment(ctx, tx, &s); err != nil {
+		return err
+	}
+
+	return shipLines(ctx, tx, order, s.Lines)
+}
+
+// shipLines marks shipment lines of a locked order as shipped. Serialized
+// lines need a serial number assigned for every unit shipped so far. If the
+// order takes its stock on shipment, the goods leave the warehouse here,
+// like they would have when the order was placed, and the line's allocated
+// serial numbers are sold and linked to the outgoing operations.
+func shipLines(ctx context.Context, tx pgx.Tx, order *models.Order, lines []models.ShipmentLine) error {
+	sql := `SELECT
+		oi.product_id,
+		oi.quantity - oi.quantity_shipped,
+		oi.quantity_shipped,
+		p.is_serialized,
+		(SELECT COUNT(*) FROM serial_numbers s WHERE s.order_item_id = oi.order_item_id)
+		FROM order_items oi
+		JOIN products p ON p.product_id = oi.product_id
+		WHERE oi.order_item_id = $1 AND oi.order_id = $2
+		FOR UPDATE OF oi
+	`
+
+	serialized := make(map[int]bool, len(lines))
+	productIDs := make([]int, 0, len(lines))
+
+	for i := range lines {
+		line := &lines[i]
+
+		var left, shipped, assigned int
+		var isSerialized bool
+
+		err := tx.QueryRow(ctx, sql, line.OrderItemID, order.OrderID).
+			Scan(&line.ProductID, &left, &shipped, &isSerialized, &assigned)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: order item %d is not on order %d", ErrInvalidInput, line.OrderItemID, order.OrderID)
+			}
+			return fmt.Errorf("failed to lock order item %d: %w", line.OrderItemID, err)
+		}
+		if line.Quantity > left {
+			return fmt.Errorf("%w: only %d units of order item %d are left to ship", ErrInvalidInput, left, line.OrderItemID)
+		}
+		if isSerialized && assigned < shipped+line.Quantity {
+			return fmt.Errorf("%w: order item %d has %d of %d serial numbers", ErrSerialsRequired, line.OrderItemID, assigned, shipped+line.Quantity)
+		}
+
+		serialized[line.OrderItemID] = isSerialized
+		productIDs = append(productIDs, line.ProductID)
+	}
+
+	var bundles map[int][]models.BundleComponent
+	if order.StockTiming == models.StockOnShipment {
+		var err error
+		if bundles, err = bundleComponents(ctx, tx, productIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lines {
+		if order.StockTiming == models.StockOnShipment {
+			item := models.OrderItem{
+				OrderItemID: line.OrderItemID,
+				OrderID:     order.OrderID,
+				ProductID:   line.ProductID,
+				Quantity:    line.Quantity,
+			}
+
+			var err error
+			if components, ok := bundles[line.ProductID]; ok {
+				err = dispatchBundle(ctx, tx, order.WarehouseID, &item, components)
+			} else {
+				err = dispatchItem(ctx, tx, order.WarehouseID, &item)
+			}
+			if err != nil {
+				return err
+			}
+
+			if serialized[line.OrderItemID] {
+				if err := linkAssignedSerials(ctx, tx, order.OrderID, line); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := tx.Exec(ctx, `UPDATE order_items SET quantity_shipped = quantity_shipped + $1 WHERE order_item_id = $2`,
+			line.Quantity, line.OrderItemID)
+		if err != nil {
+			return fmt.Errorf("failed to update order item %d: %w", line.OrderItemID, err)
+		}
+	}
+
+	return nil
+}
+
+// linkAssignedSerials sells as many of a line's allocated serial numbers as
+// it ships and links them to its outgoing operations, oldest assignment
+// first.
+func linkAssignedSerials(ctx context.Context, tx pgx.Tx, orderID int, line models.ShipmentLine) error {
+	rows, err := tx.Query(ctx, `UPDATE serial_numbers SET status = $1
+		WHERE serial_id IN (
+			SELECT serial_id FROM serial_numbers
+			WHERE order_item_id = $2 AND status = $3
+			ORDER BY serial_id
+			LIMIT $4
+			FOR UPDATE
+		)
+		RETURNING serial_id`, models.SerialSold, line.OrderItemID, models.SerialAllocated, line.Quantity)
+	if err != nil {
+		return fmt.Errorf("failed to sell serials of order item %d: %w", line.OrderItemID, err)
+	}
+
+	serialIDs, err := scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, serialID := range serialIDs {
+		if err := linkShippedSerial(ctx, tx, orderID, line.ProductID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cancel drops a shipment that has not been confirmed.
+func (r *shipmentRepo) Cancel(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockShipment(ctx, tx, id); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE shipments SET status = $1 WHERE shipment_id = $2`, models.ShipmentCancelled, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel shipment %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// snapshotLag keeps snapshots behind the clock. Operations get created_at
+// before their transaction commits, so one still in flight when a snapshot
+// is taken could otherwise land before taken_at and never be counted.
+const snapshotLag = 5 * time.Minute
+
+// TakeSnapshot stores every product's ledger quantity per warehouse as of
+// snapshotLag ago, building on the previous snapshot. It is meant to be run
+// periodically, e.g. nightly.
+func (r *operationRepo) TakeSnapshot(ctx context.Context) (*models.StockSnapshot, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s := models.StockSnapshot{
+		TakenAt:   time.Now().Add(-snapshotLag),
+		CreatedAt: time.Now(),
+	}
+
+	var previousID *int
+	var previousAt *time.Time
+
+	err = tx.QueryRow(ctx, `SELECT snapshot_id, taken_at FROM stock_snapshots ORDER BY taken_at DESC LIMIT 1`).
+		Scan(&previousID, &previousAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get previous snapshot: %w", err)
+	}
+	if previousAt != nil && !previousAt.Before(s.TakenAt) {
+		return nil, fmt.Errorf("%w: a snapshot was taken less than %s ago", ErrDuplicate, snapshotLag)
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO stock_snapshots (taken_at, created_at) VALUES ($1, $2) RETURNING snapshot_id`,
+		s.TakenAt, s.CreatedAt).Scan(&s.SnapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	sql := `INSERT INTO stock_snapshot_lines (snapshot_id, product_id, warehouse_id, quantity)
+		SELECT $1, product_id, warehouse_id, SUM(quantity)
+		FROM (
+			SELECT product_id, warehouse_id, quantity
+			FROM stock_snapshot_lines
+			WHERE snapshot_id = $2
+			UNION ALL
+			SELECT product_id, warehouse_id, change_quant
+			FROM operations
+			WHERE operation_type IN ` + stockOperationTypes + `
+				AND created_at > COALESCE($3, '-infinity'::timestamptz)
+				AND created_at <= $4
+		) ledger
+		GROUP BY product_id, warehouse_id
+		HAVING SUM(quantity) <> 0
+	`
+
+	result, err := tx.Exec(ctx, sql, s.SnapshotID, previousID, previousAt, s.TakenAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fill snapshot: %w", err)
+	}
+	s.Lines = int(result.RowsAffected())
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &s, nil
+}
+
+// GetStockAsOf rebuilds a product's stock at a past moment. A parent's
+// stock is the sum over its variants.
+func (r *operationRepo) GetStockAsOf(ctx context.Context, productID int, at time.Time) (*models.StockAsOf, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE product_id = $1)`, productID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get product %d: %w", productID, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	stock, err := r.stockAsOf(ctx, at, `p.product_id = $2 OR p.parent_id = $2`, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.StockAsOf{ProductID: productID, At: at}
+	byWarehouse := make(map[int]int)
+
+	for _, s := range stock {
+		result.Quantity += s.Quantity
+		for _, w := range s.Warehouses {
+			i, ok := byWarehouse[w.WarehouseID]
+			if !ok {
+				i = len(result.Warehouses)
+				byWarehouse[w.WarehouseID] = i
+				result.Warehouses = append(result.Warehouses, models.WarehouseQuantity{WarehouseID: w.WarehouseID})
+			}
+			result.Warehouses[i].Quantity += w.Quantity
+		}
+	}
+
+	return &result, nil
+}
+
+// GetCategoryStockAsOf rebuilds the stock of every product in a category
+// at a past moment. Products are matched on their current category;
+// parents with variants are left out since the variants are listed.
+func (r *operationRepo) GetCategoryStockAsOf(ctx context.Context, category string, at time.Time) ([]models.StockAsOf, error) {
+	if category == "" {
+		return nil, fmt.Errorf("%w: category cannot be empty", ErrInvalidInput)
+	}
+
+	return r.stockAsOf(ctx, at,
+		`p.category = $2 AND NOT EXISTS (SELECT 1 FROM products v WHERE v.parent_id = p.product_id)`, category)
+}
+
+// stockAsOf sums, for the products matching where, the latest snapshot at
+// or before at and the stock operations between that snapshot and at.
+// Products created after at are not listed.
+func (r *operationRepo) stockAsOf(ctx context.Context, at time.Time, where string, arg any) ([]models.StockAsOf, error) {
+	sql := `WITH scope AS (
+			SELECT p.product_id FROM products p
+			WHERE (` + where + `) AND p.created_at <= $1
+		), snap AS (
+			SELECT snapshot_id, taken_at FROM stock_snapshots
+			WHERE taken_at <= $1
+			ORDER BY taken_at DESC
+			LIMIT 1
+		), ledger AS (
+			SELECT sl.product_id, sl.warehouse_id, sl.quantity
+			FROM stock_snapshot_lines sl
+			WHERE sl.snapshot_id = (SELECT snapshot_id FROM snap)
+				AND sl.product_id IN (SELECT product_id FROM scope)
+			UNION ALL
+			SELECT o.product_id, o.warehouse_id, o.change_quant
+			FROM operations o
+			WHERE o.operation_type IN ` + stockOperationTypes + `
+				AND o.created_at > COALESCE((SELECT taken_at FROM snap), '-infinity'::timestamptz)
+				AND o.created_at <= $1
+				AND o.product_id IN (SELECT product_id FROM scope)
+		)
+		SELECT s.product_id, l.warehouse_id, COALESCE(SUM(l.quantity), 0)::int
+		FROM scope s
+		LEFT JOIN ledger l ON l.product_id = s.product_id
+		GROUP BY s.product_id, l.warehouse_id
+		ORDER BY s.product_id, l.warehouse_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, at, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock as of %s: %w", at.Format(time.RFC3339), err)
+	}
+
+	defer rows.Close()
+
+	stock := []models.StockAsOf{}
+	index := make(map[int]int)
+
+	for rows.Next() {
+		var productID, quantity int
+		var warehouseID *int
+
+		if err := rows.Scan(&productID, &warehouseID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+
+		i, ok := index[productID]
+		if !ok {
+			i = len(stock)
+			index[productID] = i
+			stock = append(stock, models.StockAsOf{ProductID: productID, At: at})
+		}
+
+		if warehouseID == nil || quantity == 0 {
+			continue
+		}
+		stock[i].Quantity += quantity
+		stock[i].Warehouses = append(stock[i].Warehouses, models.WarehouseQuantity{
+			WarehouseID: *warehouseID,
+			Quantity:    quantity,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return stock, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type supplierRepo struct {
+	db *pgx.Conn
+}
+
+func NewSupplierRepository(db *pgx.Conn) SupplierRepository {
+	return &supplierRepo{db: db}
+}
+
+func (r *supplierRepo) Create(ctx context.Context, s *models.Supplier) error {
+	if s == nil {
+		return fmt.Errorf("%w: supplier cannot be nil", ErrInvalidInput)
+	}
+	s.Code = strings.ToUpper(strings.TrimSpace(s.Code))
+	if s.Code == "" {
+		return fmt.Errorf("%w: supplier code required", ErrInvalidInput)
+	}
+	if s.Name == "" {
+		return fmt.Errorf("%w: supplier name required", ErrInvalidInput)
+	}
+
+	sql := `
+		INSERT INTO suppliers (
+			code,
+			name,
+			email,
+			phone,
+			created_at
+	) VALUES ($1, $2, $3, $4, $5)
+	RETURNING supplier_id
+	`
+
+	s.CreatedAt = time.Now()
+
+	err := r.db.QueryRow(ctx, sql,
+		s.Code,
+		s.Name,
+		s.Email,
+		s.Phone,
+		s.CreatedAt,
+	).Scan(&s.SupplierID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: supplier code already exists", ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create supplier: %w", err)
+	}
+
+	return nil
+}
+
+func (r *supplierRepo) GetByID(ctx context.Context, id int) (*models.Supplier, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			supplier_id,
+			code,
+			name,
+			COALESCE(email, ''),
+			COALESCE(phone, ''),
+			created_at
+		FROM suppliers WHERE supplier_id = $1
+		`
+
+	var s models.Supplier
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&s.SupplierID,
+		&s.Code,
+		&s.Name,
+		&s.Email,
+		&s.Phone,
+		&s.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get supplier by id %d: %w", id, err)
+	}
+
+	return &s, nil
+}
+
+func (r *supplierRepo) GetAll(ctx context.Context) ([]models.Supplier, error) {
+	sql := `
+		SELECT
+			supplier_id,
+			code,
+			name,
+			COALESCE(email, ''),
+			COALESCE(phone, ''),
+			created_at
+		FROM suppliers
+		ORDER BY supplier_id
+		`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all suppliers: %w", err)
+	}
+
+	defer rows.Close()
+
+	var suppliers []models.Supplier
+
+	for rows.Next() {
+		var s models.Supplier
+
+		err := rows.Scan(&s.SupplierID,
+			&s.Code,
+			&s.Name,
+			&s.Email,
+			&s.Phone,
+			&s.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan suppliers: %w", err)
+		}
+		suppliers = append(suppliers, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return suppliers, nil
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type taxRepo struct {
+	db *pgx.Conn
+}
+
+func NewTaxRepository(db *pgx.Conn) TaxRepository {
+	return &taxRepo{db: db}
+}
+
+// normalizeJurisdiction makes jurisdiction codes case-insensitive.
+func normalizeJurisdiction(jurisdiction string) string {
+	return strings.ToUpper(strings.TrimSpace(jurisdiction))
+}
+
+func (r *taxRepo) SetTaxClass(ctx context.Context, productID int, taxClass string) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	taxClass = strings.TrimSpace(taxClass)
+	if taxClass == "" {
+		return fmt.Errorf("%w: tax class cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := r.db.Exec(ctx, `UPDATE products SET tax_class = $1 WHERE product_id = $2`, taxClass, productID)
+	if err != nil {
+		return fmt.Errorf("failed to set tax class of product %d: %w", productID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateRate adds a rate for a jurisdiction and tax class. Its period may
+// not overlap another rate for the same pair; to change a rate, end the
+// current one by giving it an EffectiveTo first.
+func (r *taxRepo) CreateRate(ctx context.Context, rate *models.TaxRate) error {
+	if rate == nil {
+		return fmt.Errorf("%w: tax rate cannot be nil", ErrInvalidInput)
+	}
+
+	rate.Jurisdiction = normalizeJurisdiction(rate.Jurisdiction)
+	rate.TaxClass = strings.TrimSpace(rate.TaxClass)
+
+	if rate.Jurisdiction == "" {
+		return fmt.Errorf("%w: jurisdiction cannot be empty", ErrInvalidInput)
+	}
+	if rate.TaxClass == "" {
+		return fmt.Errorf("%w: tax class cannot be empty", ErrInvalidInput)
+	}
+	if rate.Rate < 0 || rate.Rate > models.HundredPercent {
+		return fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidInput)
+	}
+	if rate.EffectiveFrom.IsZero() {
+		return fmt.Errorf("%w: effective from date is required", ErrInvalidInput)
+	}
+	if rate.EffectiveTo != nil && !rate.EffectiveTo.After(rate.EffectiveFrom) {
+		return fmt.Errorf("%w: rate must end after it starts", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var overlapping int
+	err = tx.QueryRow(ctx, `SELECT tax_rate_id FROM tax_rates
+		WHERE jurisdiction = $1 AND tax_class = $2
+		AND (effective_to IS NULL OR effective_to > $3::date)
+		AND ($4::date IS NULL OR effective_from < $4::date)
+		LIMIT 1
+		FOR UPDATE`, rate.Jurisdiction, rate.TaxClass, rate.EffectiveFrom, rate.EffectiveTo).Scan(&overlapping)
+	if err == nil {
+		return fmt.Errorf("%w: overlaps tax rate %d", ErrDuplicate, overlapping)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to check tax rates: %w", err)
+	}
+
+	insert := `INSERT INTO tax_rates (
+		jurisdiction,
+		tax_class,
+		rate,
+		effective_from,
+		effective_to,
+		created_at
+	) VALUES ($1, $2, $3, $4::date, $5::date, $6)
+	RETURNING tax_rate_id, effective_from, effective_to
+	`
+
+	rate.CreatedAt = time.Now()
+
+	err = tx.QueryRow(ctx, insert, rate.Jurisdiction, rate.TaxClass, rate.Rate, rate.EffectiveFrom, rate.EffectiveTo, rate.CreatedAt).
+		Scan(&rate.TaxRateID, &rate.EffectiveFrom, &rate.EffectiveTo)
+	if err != nil {
+		return fmt.Errorf("failed to create tax rate: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// EndRate ends an open-ended rate, so it last applies the day before
+// effectiveTo.
+func (r *taxRepo) EndRate(ctx context.Context, id int, effectiveTo time.Time) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	result, err := r.db.Exec(ctx, `UPDATE tax_rates SET effective_to = $1::date
+		WHERE tax_rate_id = $2 AND effective_to IS NULL AND effective_from < $1::date`, effectiveTo, id)
+	if err != nil {
+		return fmt.Errorf("failed to end tax rate %d: %w", id, err)
+	}
+	if result.RowsAffected() == 0 {
+		var exists bool
+		if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tax_rates WHERE tax_rate_id = $1)`, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to get tax rate %d: %w", id, err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return fmt.Errorf("%w: tax rate %d has already ended or starts after %s", ErrInvalidInput, id, effectiveTo.Format(time.DateOnly))
+	}
+
+	return nil
+}
+
+// GetRates lists the rates of a jurisdiction, past and future included.
+func (r *taxRepo) GetRates(ctx context.Context, jurisdiction string) ([]models.TaxRate, error) {
+	jurisdiction = normalizeJurisdiction(jurisdiction)
+	if jurisdiction == "" {
+		return nil, fmt.Errorf("%w: jurisdiction cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			tax_rate_id,
+			jurisdiction,
+			tax_class,
+			rate,
+			effective_from,
+			effective_to,
+			created_at
+		FROM tax_rates
+		WHERE jurisdiction = $1
+		ORDER BY tax_class, effective_from
+		`
+
+	rows, err := r.db.Query(ctx, sql, jurisdiction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax rates of %s: %w", jurisdiction, err)
+	}
+
+	defer rows.Close()
+
+	var rates []models.TaxRate
+
+	for rows.Next() {
+		var t models.TaxRate
+
+		err := rows.Scan(&t.TaxRateID,
+			&t.Jurisdiction,
+			&t.TaxClass,
+			&t.Rate,
+			&t.EffectiveFrom,
+			&t.EffectiveTo,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tax rates: %w", err)
+		}
+		rates = append(rates, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return rates, nil
+}
+
+// applyTax works out the net, tax and gross amounts of an order's lines
+// and of the order. A line is taxed on its value after its own discounts
+// and its share of the order-wide ones, which are spread over the lines in
+// proportion to their value. Tax is worked out and rounded to the cent,
+// half away from zero, once per line, and the order amounts are the sums
+// of the line amounts. With tax-inclusive prices the line value is the
+// gross amount and the net amount is worked back from it.
+func applyTax(ctx context.Context, tx pgx.Tx, order *models.Order, items []models.OrderItem, discounts []pendingDiscount) error {
+	values := make([]models.Money, len(items))
+	for i, item := range items {
+		values[i] = item.Price.Mul(item.Quantity)
+	}
+
+	var orderWide, total models.Money
+	for _, d := range discounts {
+		if d.item >= 0 {
+			values[d.item] -= d.discount.Amount
+		} else {
+			orderWide += d.discount.Amount
+		}
+	}
+	for _, v := range values {
+		total += v
+	}
+
+	if orderWide > 0 && total > 0 {
+		left := orderWide
+		for i, v := range values {
+			share := min(orderWide.Share(int64(v), int64(total)), v, left)
+			values[i] -= share
+			left -= share
+		}
+		// Rounding may leave a few cents over; they come off the first
+		// lines that still have value.
+		for i := range values {
+			share := min(values[i], left)
+			values[i] -= share
+			left -= share
+		}
+	}
+
+	rates := make(map[int]models.Percent)
+	if order.TaxJurisdiction != "" {
+		productIDs := make([]int, len(items))
+		for i, item := range items {
+			productIDs[i] = item.ProductID
+		}
+
+		sql := `SELECT p.product_id, p.tax_class, tr.rate
+			FROM products p
+			LEFT JOIN tax_rates tr ON tr.tax_class = p.tax_class
+				AND tr.jurisdiction = $2
+				AND tr.effective_from <= CURRENT_DATE
+				AND (tr.effective_to IS NULL OR tr.effective_to > CURRENT_DATE)
+			WHERE p.product_id = ANY($1::int[])
+		`
+
+		rows, err := tx.Query(ctx, sql, productIDs, order.TaxJurisdiction)
+		if err != nil {
+			return fmt.Errorf("failed to get tax rates: %w", err)
+		}
+
+		for rows.Next() {
+			var productID int
+			var taxClass string
+			var rate *models.Percent
+			if err := rows.Scan(&productID, &taxClass, &rate); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan tax rates: %w", err)
+			}
+			if rate == nil {
+				rows.Close()
+				return fmt.Errorf("%w: no %s tax rate in %s", ErrInvalidInput, taxClass, order.TaxJurisdiction)
+			}
+			rates[productID] = *rate
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to complete row iteration: %w", err)
+		}
+	}
+
+	var net, tax models.Money
+	for i := range items {
+		item := &items[i]
+		rate := rates[item.ProductID]
+
+		var lineNet, lineTax models.Money
+		if order.PricesIncludeTax {
+			lineNet = values[i].WithoutPercent(rate)
+			lineTax = values[i] - lineNet
+		} else {
+			lineNet = values[i]
+			lineTax = values[i].Percent(rate)
+		}
+
+		item.TaxRate = rate
+		item.NetAmount = lineNet
+		item.TaxAmount = lineTax
+		item.GrossAmount = lineNet + lineTax
+
+		net += lineNet
+		tax += lineTax
+	}
+
+	order.NetAmount = net
+	order.TaxAmount = tax
+	order.TotalAmount = net + tax
+
+	return nil
+}
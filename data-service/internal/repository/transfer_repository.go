@@ -0,0 +1,782 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var transferStatuses = map[string]bool{
+	models.TransferDraft:     true,
+	models.TransferInTransit: true,
+	models.TransferReceived:  true,
+	models.TransferCancelled: true,
+}
+
+type transferRepo struct {
+	db *pgx.Conn
+}
+
+func NewTransferRepository(db *pgx.Conn) TransferRepository {
+	return &transferRepo{db: db}
+}
+
+func (r *transferRepo) Create(ctx context.Context, t *models.Transfer) error {
+	if t == nil {
+		return fmt.Errorf("%w: transfer cannot be nil", ErrInvalidInput)
+	}
+	if t.FromWarehouseID <= 0 || t.ToWarehouseID <= 0 {
+		return fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if t.FromWarehouseID == t.ToWarehouseID {
+		return fmt.Errorf("%w: source and destination warehouse must differ", ErrInvalidInput)
+	}
+	if len(t.Lines) == 0 {
+		return fmt.Errorf("%w: transfer needs at least one line", ErrInvalidInput)
+	}
+
+	seen := make(map[int]bool, len(t.Lines))
+	for _, line := range t.Lines {
+		if line.ProductID <= 0 {
+			return fmt.Errorf("%w: product ID must be positive", ErrInvalidInput)
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidInput)
+		}
+		if seen[line.ProductID] {
+			return fmt.Errorf("%w: product %d listed twice", ErrInvalidInput, line.ProductID)
+		}
+		seen[line.ProductID] = true
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	insert := `INSERT INTO transfers (
+		from_warehouse_id,
+		to_warehouse_id,
+		status,
+		created_at
+	) VALUES ($1, $2, $3, $4)
+	RETURNING transfer_id
+	`
+
+	t.Status = models.TransferDraft
+	t.CreatedAt = time.Now()
+	t.DispatchedAt = nil
+	t.ReceivedAt = nil
+
+	err = tx.QueryRow(ctx, insert, t.FromWarehouseID, t.ToWarehouseID, t.Status, t.CreatedAt).Scan(&t.TransferID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%w: warehouse %d or %d not found", ErrInvalidInput, t.FromWarehouseID, t.ToWarehouseID)
+		}
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	insertLine := `INSERT INTO transfer_lines (transfer_id, product_id, quantity)
+		VALUES ($1, $2, $3)
+		RETURNING transfer_line_id
+	`
+
+	for i := range t.Lines {
+		line := &t.Lines[i]
+		line.TransferID = t.TransferID
+		line.QuantityReceived = nil
+		line.Discrepancy = nil
+
+		err := tx.QueryRow(ctx, insertLine, t.TransferID, line.ProductID, line.Quantity).Scan(&line.LineID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%w: product %d", ErrProductNotFound, line.ProductID)
+			}
+			return fmt.Errorf("failed to create transfer line: %w", err)
+		}
+
+		if err := addTransferSerials(ctx, tx, t.FromWarehouseID, line); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// addTransferSerials records the units a line of a serialized product
+// sends. They have to be in stock in the source warehouse, now and again
+// when the transfer is dispatched.
+func addTransferSerials(ctx context.Context, tx pgx.Tx, warehouseID int, line *models.TransferLine) error {
+	var serialized bool
+	err := tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1`, line.ProductID).Scan(&serialized)
+	if err != nil {
+		return fmt.Errorf("failed to get product %d: %w", line.ProductID, err)
+	}
+
+	rc := models.Receipt{ProductID: line.ProductID, Quantity: line.Quantity, Serials: line.Serials}
+	if err := checkReceiptSerials(&rc, serialized); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO transfer_line_serials (transfer_line_id, serial_id)
+		SELECT $1, serial_id FROM serial_numbers
+		WHERE product_id = $2 AND serial_number = $3 AND warehouse_id = $4 AND status = $5
+	`
+
+	for _, serial := range line.Serials {
+		result, err := tx.Exec(ctx, insert, line.LineID, line.ProductID, serial, warehouseID, models.SerialInStock)
+		if err != nil {
+			return fmt.Errorf("failed to add serial %s to transfer line: %w", serial, err)
+		}
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("%w: serial %s is not in stock in warehouse %d", ErrInvalidInput, serial, warehouseID)
+		}
+	}
+
+	return nil
+}
+
+func (r *transferRepo) GetByID(ctx context.Context, id int) (*models.Transfer, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			transfer_id,
+			from_warehouse_id,
+			to_warehouse_id,
+			status,
+			created_at,
+			dispatched_at,
+			received_at
+		FROM transfers WHERE transfer_id = $1
+		`
+
+	var t models.Transfer
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&t.TransferID,
+		&t.FromWarehouseID,
+		&t.ToWarehouseID,
+		&t.Status,
+		&t.CreatedAt,
+		&t.DispatchedAt,
+		&t.ReceivedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get transfer by id %d: %w", id, err)
+	}
+
+	t.Lines, err = getTransferLines(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetByStatus lists transfers in one status without their lines, e.g.
+// everything currently in transit.
+func (r *transferRepo) GetByStatus(ctx context.Context, status string) ([]models.Transfer, error) {
+	if !transferStatuses[status] {
+		return nil, fmt.Errorf("%w: invalid status '%s'", ErrInvalidInput, status)
+	}
+
+	sql := `
+		SELECT
+			transfer_id,
+			from_warehouse_id,
+			to_warehouse_id,
+			status,
+			created_at,
+			dispatched_at,
+			received_at
+		FROM transfers
+		WHERE status = $1
+		ORDER BY transfer_id DESC
+		`
+
+	rows, err := r.db.Query(ctx, sql, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s transfers: %w", status, err)
+	}
+
+	defer rows.Close()
+
+	var transfers []models.Transfer
+
+	for rows.Next() {
+		var t models.Transfer
+
+		err := rows.Scan(&t.TransferID,
+			&t.FromWarehouseID,
+			&t.ToWarehouseID,
+			&t.Status,
+			&t.CreatedAt,
+			&t.DispatchedAt,
+			&t.ReceivedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfers: %w", err)
+		}
+		transfers = append(transfers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return transfers, nil
+}
+
+func getTransferLines(ctx context.Context, q querier, transferID int) ([]models.TransferLine, error) {
+	sql := `
+		SELECT
+			tl.transfer_line_id,
+			tl.transfer_id,
+			tl.product_id,
+			tl.quantity,
+			ARRAY(
+				SELECT s.serial_number FROM transfer_line_serials tls
+				JOIN serial_numbers s ON s.serial_id = tls.serial_id
+				WHERE tls.transfer_line_id = tl.transfer_line_id
+				ORDER BY s.serial_number
+			),
+			tl.quantity_received,
+			tl.quantity_received - tl.quantity
+		FROM transfer_lines tl
+		WHERE tl.transfer_id = $1
+		ORDER BY tl.transfer_line_id
+		`
+
+	rows, err := q.Query(ctx, sql, transferID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lines of transfer %d: %w", transferID, err)
+	}
+
+	defer rows.Close()
+
+	var lines []models.TransferLine
+
+	for rows.Next() {
+		var line models.TransferLine
+
+		err := rows.Scan(&line.LineID,
+			&line.TransferID,
+			&line.ProductID,
+			&line.Quantity,
+			&line.Serials,
+			&line.QuantityReceived,
+			&line.Discrepancy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer lines: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return lines, nil
+}
+
+// lockTransfer locks a transfer and checks it is in the given status.
+func lockTransfer(ctx context.Context, tx pgx.Tx, id int, status string) (*models.Transfer, error) {
+	var t models.Transfer
+
+	err := tx.QueryRow(ctx, `SELECT transfer_id, from_warehouse_id, to_warehouse_id, status
+		FROM transfers WHERE transfer_id = $1 FOR UPDATE`, id).
+		Scan(&t.TransferID, &t.FromWarehouseID, &t.ToWarehouseID, &t.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock transfer %d: %w", id, err)
+	}
+	if t.Status != status {
+		return nil, fmt.Errorf("%w: transfer %d is %s", ErrInvalidInput, id, t.Status)
+	}
+
+	return &t, nil
+}
+
+// Dispatch ships a draft transfer: every line leaves the source warehouse
+// like an order line would, from bins and lots, with transfer_out
+// operations. Only stock an order could still take is sent, so nothing
+// held by reservations or committed to open orders leaves. The lots used
+// are kept for the receiving leg, and the serials of serialized lines go
+// in transit.
+func (r *transferRepo) Dispatch(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := lockTransfer(ctx, tx, id, models.TransferDraft)
+	if err != nil {
+		return err
+	}
+
+	lines, err := getTransferLines(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	productIDs := make([]int, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+	}
+
+	if err := lockProducts(ctx, tx, productIDs); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		var serialized bool
+		err := tx.QueryRow(ctx, `SELECT is_serialized FROM products WHERE product_id = $1`, line.ProductID).Scan(&serialized)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: product %d", ErrProductNotFound, line.ProductID)
+			}
+			return fmt.Errorf("failed to get product %d: %w", line.ProductID, err)
+		}
+
+		// Counted per line, as earlier lines of the same product have
+		// already left.
+		stock, err := availableStock(ctx, tx, t.FromWarehouseID, []int{line.ProductID}, 0, 0)
+		if err != nil {
+			return err
+		}
+		if available := stock[line.ProductID].available(); available < line.Quantity {
+			return fmt.Errorf("%w: product %d has %d available in warehouse %d, transfer needs %d",
+				ErrNotEnough, line.ProductID, max(available, 0), t.FromWarehouseID, line.Quantity)
+		}
+		draws, err := takeStock(ctx, tx, t.FromWarehouseID, line.ProductID, line.Quantity, models.Operation{
+			TransferID:    &id,
+			OperationType: "transfer_out",
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, draw := range draws {
+			if draw.lotID == nil {
+				continue
+			}
+
+			_, err := tx.Exec(ctx, `INSERT INTO transfer_line_lots (transfer_line_id, lot_id, quantity) VALUES ($1, $2, $3)`,
+				line.LineID, *draw.lotID, draw.quantity)
+			if err != nil {
+				return fmt.Errorf("failed to record lot %d for transfer line: %w", *draw.lotID, err)
+			}
+		}
+
+		if serialized {
+			if err := dispatchSerials(ctx, tx, t, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE transfers SET status = $1, dispatched_at = $2 WHERE transfer_id = $3`,
+		models.TransferInTransit, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// dispatchSerials puts the units of a line in transit and links them to
+// the line's transfer_out operations.
+func dispatchSerials(ctx context.Context, tx pgx.Tx, t *models.Transfer, line models.TransferLine) error {
+	rows, err := tx.Query(ctx, `UPDATE serial_numbers SET status = $1
+		WHERE serial_id IN (SELECT serial_id FROM transfer_line_serials WHERE transfer_line_id = $2)
+		AND warehouse_id = $3 AND status = $4
+		RETURNING serial_id`, models.SerialInTransit, line.LineID, t.FromWarehouseID, models.SerialInStock)
+	if err != nil {
+		return fmt.Errorf("failed to dispatch serials of product %d: %w", line.ProductID, err)
+	}
+
+	serialIDs, err := scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(serialIDs) != line.Quantity {
+		return fmt.Errorf("%w: not every serial of product %d on transfer %d is still in stock in warehouse %d",
+			ErrInvalidInput, line.ProductID, t.TransferID, t.FromWarehouseID)
+	}
+
+	findOperation := `SELECT o.operation_id
+		FROM operations o
+		WHERE o.transfer_id = $1 AND o.product_id = $2 AND o.operation_type = 'transfer_out'
+		AND (SELECT COUNT(*) FROM operation_serials os WHERE os.operation_id = o.operation_id) < -o.change_quant
+		ORDER BY o.operation_id
+		LIMIT 1
+	`
+
+	for _, serialID := range serialIDs {
+		var operationID int
+		if err := tx.QueryRow(ctx, findOperation, t.TransferID, line.ProductID).Scan(&operationID); err != nil {
+			return fmt.Errorf("failed to find transfer operation for serial %d: %w", serialID, err)
+		}
+		if err := linkSerial(ctx, tx, operationID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Receive books an in-transit transfer into the destination warehouse.
+// Receipts give the quantity that actually arrived per product; products
+// left out arrived complete, and no more than was dispatched can arrive.
+// Differences stay on the lines as discrepancies and the missing goods are
+// written off as a transit_loss operation: they already left the source on
+// dispatch, so stock only ever grows by what was received. Goods are filed
+// under the lots they were shipped from, earliest expiry first. A
+// serialized product that arrived short names the serials that arrived;
+// the others are lost.
+func (r *transferRepo) Receive(ctx context.Context, id int, receipts []models.TransferReceipt) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := lockTransfer(ctx, tx, id, models.TransferInTransit)
+	if err != nil {
+		return err
+	}
+
+	lines, err := getTransferLines(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	arrived := make(map[int]models.TransferReceipt, len(lines))
+	dispatched := make(map[int]int, len(lines))
+	sent := make(map[int][]string, len(lines))
+	for _, line := range lines {
+		arrived[line.ProductID] = models.TransferReceipt{ProductID: line.ProductID, Quantity: line.Quantity, Serials: line.Serials}
+		dispatched[line.ProductID] = line.Quantity
+		sent[line.ProductID] = line.Serials
+	}
+
+	seen := make(map[int]bool, len(receipts))
+	for _, rc := range receipts {
+		if _, ok := arrived[rc.ProductID]; !ok {
+			return fmt.Errorf("%w: product %d is not on transfer %d", ErrInvalidInput, rc.ProductID, id)
+		}
+		if rc.Quantity < 0 {
+			return fmt.Errorf("%w: received quantity cannot be negative", ErrInvalidInput)
+		}
+		if rc.Quantity > dispatched[rc.ProductID] {
+			return fmt.Errorf("%w: received %d of product %d but only %d were dispatched",
+				ErrInvalidInput, rc.Quantity, rc.ProductID, dispatched[rc.ProductID])
+		}
+		if seen[rc.ProductID] {
+			return fmt.Errorf("%w: product %d listed twice", ErrInvalidInput, rc.ProductID)
+		}
+		seen[rc.ProductID] = true
+
+		if err := checkArrivedSerials(&rc, sent[rc.ProductID]); err != nil {
+			return err
+		}
+		arrived[rc.ProductID] = rc
+	}
+
+	for _, line := range lines {
+		rc := arrived[line.ProductID]
+
+		if rc.Quantity > 0 {
+			if err := receiveTransferLine(ctx, tx, t, line.LineID, rc); err != nil {
+				return err
+			}
+		}
+		if rc.Quantity < line.Quantity {
+			lost := missingSerials(line.Serials, rc.Serials)
+			if err := writeOffTransitLoss(ctx, tx, t, line.ProductID, line.Quantity-rc.Quantity, lost); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `UPDATE transfer_lines SET quantity_received = $1 WHERE transfer_line_id = $2`,
+			rc.Quantity, line.LineID)
+		if err != nil {
+			return fmt.Errorf("failed to update transfer line %d: %w", line.LineID, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE transfers SET status = $1, received_at = $2 WHERE transfer_id = $3`,
+		models.TransferReceived, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkArrivedSerials checks the serials a receipt names against those the
+// line sent. A receipt for every unit sent needs none.
+func checkArrivedSerials(rc *models.TransferReceipt, sent []string) error {
+	if len(sent) == 0 {
+		if len(rc.Serials) > 0 {
+			return fmt.Errorf("%w: product %d is not serialized", ErrInvalidInput, rc.ProductID)
+		}
+		return nil
+	}
+
+	if len(rc.Serials) == 0 && rc.Quantity == len(sent) {
+		rc.Serials = sent
+		return nil
+	}
+	if len(rc.Serials) != rc.Quantity {
+		return fmt.Errorf("%w: %d serial numbers given for %d units of product %d",
+			ErrInvalidInput, len(rc.Serials), rc.Quantity, rc.ProductID)
+	}
+
+	onLine := make(map[string]bool, len(sent))
+	for _, serial := range sent {
+		onLine[serial] = true
+	}
+
+	seen := make(map[string]bool, len(rc.Serials))
+	for i, serial := range rc.Serials {
+		serial = strings.TrimSpace(serial)
+		if !onLine[serial] {
+			return fmt.Errorf("%w: serial %s was not sent on this transfer", ErrInvalidInput, serial)
+		}
+		if seen[serial] {
+			return fmt.Errorf("%w: serial number %s listed twice", ErrInvalidInput, serial)
+		}
+		seen[serial] = true
+		rc.Serials[i] = serial
+	}
+
+	return nil
+}
+
+// missingSerials is every sent serial that did not arrive.
+func missingSerials(sent, arrived []string) []string {
+	got := make(map[string]bool, len(arrived))
+	for _, serial := range arrived {
+		got[serial] = true
+	}
+
+	var missing []string
+	for _, serial := range sent {
+		if !got[serial] {
+			missing = append(missing, serial)
+		}
+	}
+
+	return missing
+}
+
+// receiveTransferLine splits what arrived of a line over the lots it was
+// shipped from and receives each part at the destination. Anything beyond
+// the shipped lots arrives without a lot. Arriving serials are handed out
+// over the parts in turn.
+func receiveTransferLine(ctx context.Context, tx pgx.Tx, t *models.Transfer, lineID int, rc models.TransferReceipt) error {
+	sql := `SELECT
+		l.lot_number,
+		l.manufactured_at,
+		l.expires_at,
+		tll.quantity
+		FROM transfer_line_lots tll
+		JOIN lots l ON l.lot_id = tll.lot_id
+		WHERE tll.transfer_line_id = $1
+		ORDER BY l.expires_at NULLS LAST, l.lot_id
+	`
+
+	rows, err := tx.Query(ctx, sql, lineID)
+	if err != nil {
+		return fmt.Errorf("failed to get lots of transfer line %d: %w", lineID, err)
+	}
+
+	var parts []models.Receipt
+	for rows.Next() {
+		var part models.Receipt
+		if err := rows.Scan(&part.LotNumber, &part.ManufacturedAt, &part.ExpiresAt, &part.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan transfer line lots: %w", err)
+		}
+		parts = append(parts, part)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	unitCost, err := transferUnitCost(ctx, tx, t.TransferID, rc.ProductID)
+	if err != nil {
+		return err
+	}
+
+	remaining := rc.Quantity
+	for i := range parts {
+		parts[i].Quantity = min(parts[i].Quantity, remaining)
+		remaining -= parts[i].Quantity
+	}
+	if remaining > 0 {
+		parts = append(parts, models.Receipt{Quantity: remaining})
+	}
+
+	serials := rc.Serials
+	for _, part := range parts {
+		if part.Quantity == 0 {
+			continue
+		}
+
+		if len(serials) > 0 {
+			part.Serials = serials[:part.Quantity]
+			serials = serials[part.Quantity:]
+		}
+
+		part.ProductID = rc.ProductID
+		part.WarehouseID = t.ToWarehouseID
+		part.ToLocationID = rc.ToLocationID
+		part.TransferID = &t.TransferID
+		part.UnitCost = unitCost
+
+		if err := receiveStock(ctx, tx, &part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transferUnitCost is what a product on a transfer cost when it left the
+// source; goods arrive, or are lost, at that cost.
+func transferUnitCost(ctx context.Context, tx pgx.Tx, transferID, productID int) (*models.Cost, error) {
+	var unitCost *models.Cost
+	err := tx.QueryRow(ctx, `SELECT SUM(cost_amount) / SUM(change_quant)
+		FROM operations
+		WHERE transfer_id = $1 AND product_id = $2 AND operation_type = 'transfer_out'`,
+		transferID, productID).Scan(&unitCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer cost of product %d: %w", productID, err)
+	}
+
+	return unitCost, nil
+}
+
+// writeOffTransitLoss records goods that were dispatched but never arrived,
+// and marks the serials among them lost. Stock is not touched and no cost
+// layer is consumed: both happened when the goods left the source.
+func writeOffTransitLoss(ctx context.Context, tx pgx.Tx, t *models.Transfer, productID, quantity int, serials []string) error {
+	unitCost, err := transferUnitCost(ctx, tx, t.TransferID, productID)
+	if err != nil {
+		return err
+	}
+
+	o := models.Operation{
+		ProductID:     productID,
+		WarehouseID:   t.ToWarehouseID,
+		OperationType: "transit_loss",
+		ChangeQuant:   -quantity,
+		TransferID:    &t.TransferID,
+		UnitCost:      unitCost,
+	}
+	if unitCost != nil {
+		amount := -unitCost.Mul(quantity)
+		o.CostAmount = &amount
+	}
+
+	if err := insertOperation(ctx, tx, &o); err != nil {
+		return fmt.Errorf("failed to create operation: %w", err)
+	}
+
+	if len(serials) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `UPDATE serial_numbers SET status = $1
+		WHERE product_id = $2 AND serial_number = ANY($3::text[]) AND status = $4
+		RETURNING serial_id`, models.SerialLost, productID, serials, models.SerialInTransit)
+	if err != nil {
+		return fmt.Errorf("failed to write off serials of product %d: %w", productID, err)
+	}
+
+	serialIDs, err := scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, serialID := range serialIDs {
+		if err := linkSerial(ctx, tx, o.OperationID, serialID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cancel drops a transfer that has not been dispatched yet.
+func (r *transferRepo) Cancel(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockTransfer(ctx, tx, id, models.TransferDraft); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE transfers SET status = $1 WHERE transfer_id = $2`, models.TransferCancelled, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel transfer %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
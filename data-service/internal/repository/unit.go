@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var unitRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,15}$`)
+
+func normalizeUnit(unit string) (string, error) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if !unitRe.MatchString(unit) {
+		return "", fmt.Errorf("%w: invalid unit '%s'", ErrInvalidInput, unit)
+	}
+	return unit, nil
+}
+
+// validateUnits checks a product's alternate units against its base unit.
+func validateUnits(baseUnit string, units []models.ProductUnit) error {
+	seen := make(map[string]bool, len(units))
+	for i := range units {
+		unit, err := normalizeUnit(units[i].Unit)
+		if err != nil {
+			return err
+		}
+		if unit == baseUnit {
+			return fmt.Errorf("%w: %s is already the base unit", ErrInvalidInput, unit)
+		}
+		if seen[unit] {
+			return fmt.Errorf("%w: unit %s listed twice", ErrInvalidInput, unit)
+		}
+		if units[i].Factor <= 0 {
+			return fmt.Errorf("%w: factor of unit %s must be positive", ErrInvalidInput, unit)
+		}
+		seen[unit] = true
+		units[i].Unit = unit
+	}
+
+	return nil
+}
+
+// unitFactor returns how many base units one unit of a product holds.
+func unitFactor(ctx context.Context, q rowQuerier, productID int, unit string) (int, error) {
+	sql := `SELECT p.base_unit, pu.factor
+		FROM products p
+		LEFT JOIN product_units pu ON pu.product_id = p.product_id AND pu.unit = $2
+		WHERE p.product_id = $1
+	`
+
+	var baseUnit string
+	var factor *int
+
+	err := q.QueryRow(ctx, sql, productID, unit).Scan(&baseUnit, &factor)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrProductNotFound
+		}
+		return 0, fmt.Errorf("failed to get units of product %d: %w", productID, err)
+	}
+
+	if unit == baseUnit {
+		return 1, nil
+	}
+	if factor == nil {
+		return 0, fmt.Errorf("%w: unit %s is not defined for product %d", ErrInvalidInput, unit, productID)
+	}
+
+	return *factor, nil
+}
+
+// toBaseQuantity converts a quantity entered in unit into the product's
+// base unit and stores it in *quantity. Without a unit *quantity is already
+// in the base unit and is left alone. A base quantity sent alongside a unit
+// must agree with the conversion.
+func toBaseQuantity(ctx context.Context, q rowQuerier, productID int, unit *string, unitQuantity int, quantity *int) error {
+	if strings.TrimSpace(*unit) == "" {
+		*unit = ""
+		if unitQuantity != 0 {
+			return fmt.Errorf("%w: unit quantity given without a unit", ErrInvalidInput)
+		}
+		return nil
+	}
+
+	normalized, err := normalizeUnit(*unit)
+	if err != nil {
+		return err
+	}
+	*unit = normalized
+
+	factor, err := unitFactor(ctx, q, productID, normalized)
+	if err != nil {
+		return err
+	}
+
+	if unitQuantity > math.MaxInt32/factor || unitQuantity < math.MinInt32/factor {
+		return fmt.Errorf("%w: %d %s is too large", ErrInvalidInput, unitQuantity, normalized)
+	}
+
+	base := unitQuantity * factor
+	if *quantity != 0 && *quantity != base {
+		return fmt.Errorf("%w: %d %s is %d in the base unit, not %d", ErrInvalidInput, unitQuantity, normalized, base, *quantity)
+	}
+	*quantity = base
+
+	return nil
+}
+
+func nullableUnit(unit string, quantity int) (interface{}, interface{}) {
+	if unit == "" {
+		return nil, nil
+	}
+	return unit, quantity
+}
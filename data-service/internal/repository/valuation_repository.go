@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type valuationRepo struct {
+	db *pgx.Conn
+}
+
+func NewValuationRepository(db *pgx.Conn) ValuationRepository {
+	return &valuationRepo{db: db}
+}
+
+// costOperation values a stock change before its operation is written and
+// fills in o.UnitCost and o.CostAmount. It runs after adjustStock, so
+// products.quantity already includes the change.
+//
+// Additions open a FIFO cost layer at o.UnitCost (the current average cost
+// if none is given) and update the moving average. Removals always consume
+// layers oldest first, so either method can be reported at any time; the
+// product's cost method decides which cost is recorded. Stock that predates
+// cost tracking has no layer and is costed at the average.
+func costOperation(ctx context.Context, tx pgx.Tx, o *models.Operation) error {
+	var method string
+	var quantity int
+	var average models.Cost
+
+	err := tx.QueryRow(ctx, `SELECT cost_method, quantity, average_cost FROM products WHERE product_id = $1`, o.ProductID).
+		Scan(&method, &quantity, &average)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to get cost of product %d: %w", o.ProductID, err)
+	}
+
+	if o.ChangeQuant > 0 {
+		unitCost := average
+		if o.UnitCost != nil {
+			unitCost = *o.UnitCost
+		}
+		if unitCost < 0 {
+			return fmt.Errorf("%w: unit cost cannot be negative", ErrInvalidInput)
+		}
+
+		newAverage := movingAverage(average, quantity-o.ChangeQuant, unitCost, o.ChangeQuant)
+
+		_, err := tx.Exec(ctx, `UPDATE products SET average_cost = $1 WHERE product_id = $2`, newAverage, o.ProductID)
+		if err != nil {
+			return fmt.Errorf("failed to update average cost of product %d: %w", o.ProductID, err)
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO cost_layers (product_id, quantity, remaining, unit_cost) VALUES ($1, $2, $2, $3)`,
+			o.ProductID, o.ChangeQuant, unitCost)
+		if err != nil {
+			return fmt.Errorf("failed to create cost layer for product %d: %w", o.ProductID, err)
+		}
+
+		amount := unitCost.Mul(o.ChangeQuant)
+		o.UnitCost = &unitCost
+		o.CostAmount = &amount
+		return nil
+	}
+
+	take := -o.ChangeQuant
+
+	fifoCost, err := consumeCostLayers(ctx, tx, o.ProductID, take, average)
+	if err != nil {
+		return err
+	}
+
+	cost := issueCost(method, fifoCost, average, take)
+
+	unitCost := cost.Per(take)
+	amount := -cost
+	o.UnitCost = &unitCost
+	o.CostAmount = &amount
+
+	return nil
+}
+
+// consumeCostLayers takes quantity off the oldest open layers and returns
+// their cost. Whatever the layers do not cover is costed at average.
+func consumeCostLayers(ctx context.Context, tx pgx.Tx, productID, quantity int, average models.Cost) (models.Cost, error) {
+	rows, err := tx.Query(ctx, `SELECT layer_id, remaining, unit_cost
+		FROM cost_layers
+		WHERE product_id = $1 AND remaining > 0
+		ORDER BY layer_id
+		FOR UPDATE`, productID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cost layers of product %d: %w", productID, err)
+	}
+
+	var layers []costLayer
+	for rows.Next() {
+		var l costLayer
+		if err := rows.Scan(&l.id, &l.remaining, &l.unitCost); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan cost layers: %w", err)
+		}
+		layers = append(layers, l)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	cost, taken := drawCostLayers(layers, quantity, average)
+
+	for i, take := range taken {
+		_, err := tx.Exec(ctx, `UPDATE cost_layers SET remaining = remaining - $1 WHERE layer_id = $2`, take, layers[i].id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update cost layer %d: %w", layers[i].id, err)
+		}
+	}
+
+	return cost, nil
+}
+
+type costLayer struct {
+	id        int
+	remaining int
+	unitCost  models.Cost
+}
+
+// drawCostLayers costs quantity off layers oldest first. taken holds what
+// comes off each layer, for as many layers as are touched; whatever the
+// layers do not cover is costed at average.
+func drawCostLayers(layers []costLayer, quantity int, average models.Cost) (cost models.Cost, taken []int) {
+	for _, l := range layers {
+		if quantity == 0 {
+			break
+		}
+
+		take := min(l.remaining, quantity)
+		taken = append(taken, take)
+
+		cost += l.unitCost.Mul(take)
+		quantity -= take
+	}
+
+	return cost + average.Mul(quantity), taken
+}
+
+// movingAverage is the average cost once added units at unitCost join
+// previous units at average. With no stock before, it is unitCost.
+func movingAverage(average models.Cost, previous int, unitCost models.Cost, added int) models.Cost {
+	if previous <= 0 {
+		return unitCost
+	}
+	return (average.Mul(previous) + unitCost.Mul(added)).Per(previous + added)
+}
+
+// issueCost is what quantity units leaving stock cost under a product's
+// cost method, given their cost off the FIFO layers.
+func issueCost(method string, fifoCost, average models.Cost, quantity int) models.Cost {
+	if method == models.CostAverage {
+		return average.Mul(quantity)
+	}
+	return fifoCost
+}
+
+func (r *valuationRepo) SetCostMethod(ctx context.Context, productID int, method string) error {
+	if productID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if method != models.CostFIFO && method != models.CostAverage {
+		return fmt.Errorf("%w: cost method must be %s or %s", ErrInvalidInput, models.CostFIFO, models.CostAverage)
+	}
+
+	result, err := r.db.Exec(ctx, `UPDATE products SET cost_method = $1 WHERE product_id = $2`, method, productID)
+	if err != nil {
+		return fmt.Errorf("failed to set cost method of product %d: %w", productID, err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *valuationRepo) GetProductValue(ctx context.Context, productID int) (*models.ProductValuation, error) {
+	if productID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	values, err := r.getValues(ctx, `WHERE p.product_id = $1`, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &values[0], nil
+}
+
+// GetValues values every product, or those of one category.
+func (r *valuationRepo) GetValues(ctx context.Context, category string) ([]models.ProductValuation, error) {
+	if category == "" {
+		return r.getValues(ctx, ``)
+	}
+	return r.getValues(ctx, `WHERE p.category = $1`, category)
+}
+
+func (r *valuationRepo) GetCategoryValues(ctx context.Context) ([]models.CategoryValuation, error) {
+	values, err := r.getValues(ctx, ``)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []models.CategoryValuation
+	index := make(map[string]int)
+
+	for _, v := range values {
+		i, ok := index[v.Category]
+		if !ok {
+			i = len(categories)
+			index[v.Category] = i
+			categories = append(categories, models.CategoryValuation{Category: v.Category})
+		}
+		categories[i].Quantity += v.Quantity
+		categories[i].Value += v.Value
+	}
+
+	return categories, nil
+}
+
+func (r *valuationRepo) getValues(ctx context.Context, where string, args ...any) ([]models.ProductValuation, error) {
+	sql := `SELECT
+		p.product_id,
+		p.category,
+		p.cost_method,
+		p.quantity,
+		p.average_cost,
+		COALESCE(SUM(cl.remaining), 0)::int,
+		COALESCE(SUM(cl.remaining * cl.unit_cost), 0)
+		FROM products p
+		LEFT JOIN cost_layers cl ON cl.product_id = p.product_id AND cl.remaining > 0
+		` + where + `
+		GROUP BY p.product_id
+		ORDER BY p.category, p.product_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory value: %w", err)
+	}
+
+	defer rows.Close()
+
+	var values []models.ProductValuation
+
+	for rows.Next() {
+		var v models.ProductValuation
+		var average, layerValue models.Cost
+		var layerQuantity int
+
+		err := rows.Scan(&v.ProductID,
+			&v.Category,
+			&v.CostMethod,
+			&v.Quantity,
+			&average,
+			&layerQuantity,
+			&layerValue,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory value: %w", err)
+		}
+
+		value := layerValue + average.Mul(max(v.Quantity-layerQuantity, 0))
+		if v.CostMethod == models.CostAverage {
+			value = average.Mul(v.Quantity)
+		}
+
+		v.Value = value.Money()
+		v.UnitCost = average
+		if v.Quantity > 0 {
+			v.UnitCost = value.Per(v.Quantity)
+		}
+
+		values = append(values, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return values, nil
+}
+
+// GetOrderCOGS sums the cost recorded on an order's outgoing operations,
+// less that of goods restocked from returns.
+func (r *valuationRepo) GetOrderCOGS(ctx context.Context, orderID int) (*models.OrderCOGS, error) {
+	if orderID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`, orderID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get order %d: %w", orderID, err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	sql := `SELECT
+		product_id,
+		-SUM(change_quant)::int,
+		-COALESCE(SUM(cost_amount), 0)
+		FROM operations
+		WHERE order_id = $1 AND operation_type IN ('outgoing', 'incoming')
+		GROUP BY product_id
+		ORDER BY product_id
+	`
+
+	rows, err := r.db.Query(ctx, sql, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cost of order %d: %w", orderID, err)
+	}
+
+	defer rows.Close()
+
+	cogs := models.OrderCOGS{OrderID: orderID, Products: []models.ProductCOGS{}}
+
+	for rows.Next() {
+		var p models.ProductCOGS
+
+		var cost models.Cost
+		if err := rows.Scan(&p.ProductID, &p.Quantity, &cost); err != nil {
+			return nil, fmt.Errorf("failed to scan order cost: %w", err)
+		}
+		p.Cost = cost.Money()
+		cogs.Products = append(cogs.Products, p)
+		cogs.Total += p.Cost
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return &cogs, nil
+}
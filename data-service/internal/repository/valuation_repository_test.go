@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"data-service/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestDrawCostLayers(t *testing.T) {
+	layers := []costLayer{
+		{id: 1, remaining: 5, unitCost: 100},
+		{id: 2, remaining: 5, unitCost: 200},
+	}
+
+	tests := []struct {
+		name      string
+		layers    []costLayer
+		quantity  int
+		average   models.Cost
+		wantCost  models.Cost
+		wantTaken []int
+	}{
+		{"oldest first", layers, 7, 150, 900, []int{5, 2}},
+		{"one layer exactly", layers, 5, 150, 500, []int{5}},
+		{"past the layers at average", layers, 12, 150, 1800, []int{5, 5}},
+		{"no layers", nil, 3, 150, 450, nil},
+		{"nothing taken", layers, 0, 150, 0, nil},
+	}
+
+	for _, tt := range tests {
+		cost, taken := drawCostLayers(tt.layers, tt.quantity, tt.average)
+		if cost != tt.wantCost {
+			t.Errorf("%s: drawCostLayers cost = %s, want %s", tt.name, cost, tt.wantCost)
+		}
+		if !reflect.DeepEqual(taken, tt.wantTaken) {
+			t.Errorf("%s: drawCostLayers took %v, want %v", tt.name, taken, tt.wantTaken)
+		}
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	tests := []struct {
+		average  models.Cost
+		previous int
+		unitCost models.Cost
+		added    int
+		want     models.Cost
+	}{
+		{100, 3, 200, 1, 125},
+		{100, 1, 101, 1, 101},
+		{100, 2, 0, 1, 67},
+		{100, 0, 300, 4, 300},
+		{100, -2, 300, 4, 300},
+	}
+
+	for _, tt := range tests {
+		got := movingAverage(tt.average, tt.previous, tt.unitCost, tt.added)
+		if got != tt.want {
+			t.Errorf("movingAverage(%s, %d, %s, %d) = %s, want %s",
+				tt.average, tt.previous, tt.unitCost, tt.added, got, tt.want)
+		}
+	}
+}
+
+func TestIssueCost(t *testing.T) {
+	tests := []struct {
+		method   string
+		fifoCost models.Cost
+		average  models.Cost
+		quantity int
+		want     models.Cost
+	}{
+		{models.CostFIFO, 900, 150, 7, 900},
+		{models.CostAverage, 900, 150, 7, 1050},
+	}
+
+	for _, tt := range tests {
+		got := issueCost(tt.method, tt.fifoCost, tt.average, tt.quantity)
+		if got != tt.want {
+			t.Errorf("issueCost(%s, %s, %s, %d) = %s, want %s",
+				tt.method, tt.fifoCost, tt.average, tt.quantity, got, tt.want)
+		}
+	}
+}
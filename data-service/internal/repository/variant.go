@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// productQuantitySQL reports a product's quantity with its variants' stock
+// added, for selects that alias products as p. Parents hold no stock of
+// their own, so for them this is the sum over the variants.
+const productQuantitySQL = `p.quantity + COALESCE((SELECT SUM(v.quantity) FROM products v WHERE v.parent_id = p.product_id), 0)`
+
+func validateAttributes(p *models.Product) error {
+	if p.ParentID == nil {
+		if len(p.Attributes) > 0 {
+			return fmt.Errorf("%w: attributes are only set on variants", ErrInvalidInput)
+		}
+		p.Attributes = map[string]string{}
+		return nil
+	}
+
+	if len(p.Attributes) == 0 {
+		return fmt.Errorf("%w: a variant needs at least one attribute", ErrInvalidInput)
+	}
+
+	attributes := make(map[string]string, len(p.Attributes))
+	for name, value := range p.Attributes {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" {
+			return fmt.Errorf("%w: variant attributes need a name and a value", ErrInvalidInput)
+		}
+		if _, ok := attributes[name]; ok {
+			return fmt.Errorf("%w: attribute %s listed twice", ErrInvalidInput, name)
+		}
+		attributes[name] = value
+	}
+	p.Attributes = attributes
+
+	return nil
+}
+
+// lockParent checks that a product can take a new variant and returns its
+// category, which variants always share. Products that are themselves
+// variants, hold stock of their own or have a reorder point cannot become
+// parents.
+func lockParent(ctx context.Context, tx pgx.Tx, parentID int) (string, error) {
+	var grandparentID, reorderPoint *int
+	var quantity int
+	var category string
+
+	err := tx.QueryRow(ctx, `SELECT parent_id, quantity, category, reorder_point FROM products WHERE product_id = $1 FOR UPDATE`, parentID).
+		Scan(&grandparentID, &quantity, &category, &reorderPoint)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%w: parent product %d not found", ErrInvalidInput, parentID)
+		}
+		return "", fmt.Errorf("failed to lock product %d: %w", parentID, err)
+	}
+
+	if grandparentID != nil {
+		return "", fmt.Errorf("%w: product %d is a variant and cannot have variants", ErrInvalidInput, parentID)
+	}
+	if quantity != 0 {
+		return "", fmt.Errorf("%w: product %d holds stock of its own and cannot have variants", ErrInvalidInput, parentID)
+	}
+	if reorderPoint != nil {
+		return "", fmt.Errorf("%w: product %d has a reorder point and cannot have variants", ErrInvalidInput, parentID)
+	}
+
+	return category, nil
+}
+
+// nestVariants turns a flat product list into top-level products with their
+// variants attached. Variants whose parent is not in the list are dropped.
+func nestVariants(products []models.Product) []models.Product {
+	index := make(map[int]int)
+	var nested []models.Product
+
+	for _, p := range products {
+		if p.ParentID == nil {
+			index[p.ProductID] = len(nested)
+			nested = append(nested, p)
+		}
+	}
+
+	for _, p := range products {
+		if p.ParentID == nil {
+			continue
+		}
+		if i, ok := index[*p.ParentID]; ok {
+			nested[i].Variants = append(nested[i].Variants, p)
+		}
+	}
+
+	return nested
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type warehouseRepo struct {
+	db *pgx.Conn
+}
+
+func NewWarehouseRepository(db *pgx.Conn) WarehouseRepository {
+	return &warehouseRepo{db: db}
+}
+
+func (r *warehouseRepo) Create(ctx context.Context, w *models.Warehouse) error {
+	if w == nil {
+		return fmt.Errorf("%w: warehouse cannot be nil", ErrInvalidInput)
+	}
+	w.Code = strings.TrimSpace(w.Code)
+	if w.Code == "" {
+		return fmt.Errorf("%w: warehouse code required", ErrInvalidInput)
+	}
+	if w.Name == "" {
+		return fmt.Errorf("%w: warehouse name required", ErrInvalidInput)
+	}
+
+	sql := `
+		INSERT INTO warehouses (
+			code,
+			name,
+			address,
+			created_at
+	) VALUES ($1, $2, $3, $4)
+	RETURNING warehouse_id
+	`
+
+	w.CreatedAt = time.Now()
+
+	err := r.db.QueryRow(ctx, sql,
+		w.Code,
+		w.Name,
+		w.Address,
+		w.CreatedAt,
+	).Scan(&w.WarehouseID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: warehouse code already exists", ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create warehouse: %w", err)
+	}
+
+	return nil
+}
+
+func (r *warehouseRepo) GetByID(ctx context.Context, id int) (*models.Warehouse, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			warehouse_id,
+			code,
+			name,
+			COALESCE(address, ''),
+			created_at
+		FROM warehouses WHERE warehouse_id = $1
+		`
+
+	var w models.Warehouse
+
+	err := r.db.QueryRow(ctx, sql, id).Scan(
+		&w.WarehouseID,
+		&w.Code,
+		&w.Name,
+		&w.Address,
+		&w.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get warehouse by id %d: %w", id, err)
+	}
+
+	return &w, nil
+}
+
+func (r *warehouseRepo) GetAll(ctx context.Context) ([]models.Warehouse, error) {
+	sql := `
+		SELECT
+			warehouse_id,
+			code,
+			name,
+			COALESCE(address, ''),
+			created_at
+		FROM warehouses
+		ORDER BY warehouse_id
+		`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all warehouses: %w", err)
+	}
+
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+
+	for rows.Next() {
+		var w models.Warehouse
+
+		err := rows.Scan(&w.WarehouseID,
+			&w.Code,
+			&w.Name,
+			&w.Address,
+			&w.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warehouses: %w", err)
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return warehouses, nil
+}
+
+func (r *warehouseRepo) GetStock(ctx context.Context, warehouseID int) ([]models.WarehouseStock, error) {
+	if warehouseID <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			warehouse_id,
+			product_id,
+			quantity,
+			updated_at
+		FROM warehouse_stock
+		WHERE warehouse_id = $1 AND quantity > 0
+		ORDER BY product_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock for warehouse %d: %w", warehouseID, err)
+	}
+
+	defer rows.Close()
+
+	return scanWarehouseStock(rows)
+}
+
+type querier interface {
+	rowQuerier
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// getProductStock lists a product's stock per warehouse. A parent's stock
+// is the sum over its variants.
+func getProductStock(ctx context.Context, q querier, productID int) ([]models.WarehouseStock, error) {
+	sql := `
+		SELECT
+			ws.warehouse_id,
+			$1::int,
+			SUM(ws.quantity)::int,
+			MAX(ws.updated_at)
+		FROM warehouse_stock ws
+		JOIN products p ON p.product_id = ws.product_id
+		WHERE p.product_id = $1 OR p.parent_id = $1
+		GROUP BY ws.warehouse_id
+		ORDER BY ws.warehouse_id
+		`
+
+	rows, err := q.Query(ctx, sql, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warehouse stock for product %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	return scanWarehouseStock(rows)
+}
+
+func scanWarehouseStock(rows pgx.Rows) ([]models.WarehouseStock, error) {
+	var stock []models.WarehouseStock
+
+	for rows.Next() {
+		var s models.WarehouseStock
+
+		err := rows.Scan(&s.WarehouseID,
+			&s.ProductID,
+			&s.Quantity,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan warehouse stock: %w", err)
+		}
+		stock = append(stock, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return stock, nil
+}
+
+// adjustStock applies change to a product's stock in one warehouse and to
+// the product total in products.quantity, raising or resolving the stock
+// alert if the total crosses the reorder point. It must run inside the
+// transaction that writes the matching operation.
+func adjustStock(ctx context.Context, tx pgx.Tx, warehouseID, productID, change int) error {
+	now := time.Now()
+
+	var total int
+	var hasVariants, isBundle bool
+	err := tx.QueryRow(ctx, `UPDATE products SET
+		quantity = quantity + $1,
+		updated_at = $2
+		WHERE product_id = $3
+		RETURNING quantity,
+			EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.product_id),
+			EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = products.product_id)`, change, now, productID).
+		Scan(&total, &hasVariants, &isBundle)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrProductNotFound
+		}
+		return fmt.Errorf("failed to update product quantity %d: %w", productID, err)
+	}
+	if hasVariants {
+		return fmt.Errorf("%w: product %d has variants, stock is kept per variant", ErrInvalidInput, productID)
+	}
+	if isBundle {
+		return fmt.Errorf("%w: product %d is a bundle, stock is kept per component", ErrInvalidInput, productID)
+	}
+
+	upsert := `INSERT INTO warehouse_stock (warehouse_id, product_id, quantity, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (warehouse_id, product_id) DO UPDATE SET
+			quantity = warehouse_stock.quantity + EXCLUDED.quantity,
+			updated_at = EXCLUDED.updated_at
+		RETURNING quantity
+	`
+
+	// A negative change on a missing row is inserted as a negative quantity
+	// and rejected by the CHECK constraint, same as an overdraw.
+	var quantity int
+	err = tx.QueryRow(ctx, upsert, warehouseID, productID, change, now).Scan(&quantity)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23514":
+				return fmt.Errorf("%w: insufficient quantity of product %d in warehouse %d, requested change: %d",
+					ErrNotEnough, productID, warehouseID, change)
+			case "23503":
+				return fmt.Errorf("%w: warehouse %d", ErrNotFound, warehouseID)
+			}
+		}
+		return fmt.Errorf("failed to update warehouse stock %d/%d: %w", warehouseID, productID, err)
+	}
+
+	return checkReorderPoint(ctx, tx, productID)
+}
@@ -0,0 +1,998 @@
+package repository
+
+import (
+	"context"
+	"data-service/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type waveRepo struct {
+	db *pgx.Conn
+}
+
+func NewWaveRepository(db *pgx.Conn) WaveRepository {
+	return &waveRepo{db: db}
+}
+
+// pickSource is stock a pick can come from; a nil locationID is stock that
+// has no bin.
+type pickSource struct {
+	locationID *int
+	quantity   int
+}
+
+type plannedPick struct {
+	orderItemID int
+	productID   int
+	locationID  *int
+	quantity    int
+	shipped     int
+}
+
+type waveOrder struct {
+	orderID     int
+	carrier     string
+	stockTiming string
+	picks       []plannedPick
+}
+
+// waveNeed is what an order line still has to ship of one stocked product;
+// bundle lines need their components.
+type waveNeed struct {
+	orderItemID int
+	productID   int
+	quantity    int
+	shipped     int
+}
+
+// Plan groups the open orders selected by the rule into waves and writes
+// a consolidated pick list for each. Open orders are paid or partially
+// shipped, not flagged for attention, not in an open wave and not waiting
+// to ship goods a completed wave picked for them. Orders that already took
+// their stock are picked from the bins it left; the others from bins in
+// the order goods leave the warehouse, less what other waves plan to take
+// or have picked and not shipped. Orders that cannot be picked in full, or
+// not within the rule's zone, are left for a later wave.
+func (r *waveRepo) Plan(ctx context.Context, rule *models.WaveRule) ([]models.Wave, error) {
+	if rule == nil {
+		return nil, fmt.Errorf("%w: rule cannot be nil", ErrInvalidInput)
+	}
+	if rule.WarehouseID <= 0 {
+		return nil, fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+	if rule.MaxOrders < 0 {
+		return nil, fmt.Errorf("%w: max orders cannot be negative", ErrInvalidInput)
+	}
+	rule.Carrier = strings.TrimSpace(rule.Carrier)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// One planner per warehouse at a time, so no order lands in two waves.
+	var warehouseID int
+	err = tx.QueryRow(ctx, `SELECT warehouse_id FROM warehouses WHERE warehouse_id = $1 FOR UPDATE`, rule.WarehouseID).
+		Scan(&warehouseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock warehouse %d: %w", rule.WarehouseID, err)
+	}
+
+	zones, err := locationZones(ctx, tx, rule.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if rule.ZoneID != nil && zones[*rule.ZoneID] != *rule.ZoneID {
+		return nil, fmt.Errorf("%w: location %d is not a zone of warehouse %d", ErrInvalidInput, *rule.ZoneID, rule.WarehouseID)
+	}
+
+	orders, err := waveCandidates(ctx, tx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	needs, err := waveNeeds(ctx, tx, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	type waveKey struct {
+		carrier string
+		zoneID  int
+	}
+
+	var keys []waveKey
+	groups := make(map[waveKey][]*waveOrder)
+	available := make(map[int][]pickSource)
+
+	for _, order := range orders {
+		var ok bool
+		var drawn map[int][]pickSource
+		if order.stockTiming == models.StockOnOrder {
+			ok, err = planTakenOrder(ctx, tx, order, needs[order.orderID])
+		} else {
+			drawn, ok, err = planOpenOrder(ctx, tx, rule.WarehouseID, order, needs[order.orderID], available)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		zoneID := 0
+		for i, pick := range order.picks {
+			z := 0
+			if pick.locationID != nil {
+				z = zones[*pick.locationID]
+			}
+			if i > 0 && z != zoneID {
+				zoneID = 0
+				break
+			}
+			zoneID = z
+		}
+		if rule.ZoneID != nil && zoneID != *rule.ZoneID {
+			continue
+		}
+
+		// Only an order that is kept uses up stock for the orders after it.
+		for productID, sources := range drawn {
+			available[productID] = sources
+		}
+
+		key := waveKey{carrier: order.carrier, zoneID: zoneID}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], order)
+	}
+
+	var waves []models.Wave
+
+	for _, key := range keys {
+		group := groups[key]
+		size := len(group)
+		if rule.MaxOrders > 0 {
+			size = rule.MaxOrders
+		}
+
+		for start := 0; start < len(group); start += size {
+			end := min(start+size, len(group))
+
+			w := models.Wave{
+				WarehouseID: rule.WarehouseID,
+				Carrier:     key.carrier,
+				Cutoff:      rule.Cutoff,
+			}
+			if key.zoneID != 0 {
+				zoneID := key.zoneID
+				w.ZoneID = &zoneID
+			}
+
+			waveID, err := insertWave(ctx, tx, &w, group[start:end])
+			if err != nil {
+				return nil, err
+			}
+
+			wave, err := loadWave(ctx, tx, waveID)
+			if err != nil {
+				return nil, err
+			}
+			waves = append(waves, *wave)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return waves, nil
+}
+
+// locationZones maps every location of a warehouse to the zone it is in.
+func locationZones(ctx context.Context, tx pgx.Tx, warehouseID int) (map[int]int, error) {
+	sql := `WITH RECURSIVE tree AS (
+		SELECT location_id, location_id AS zone_id
+		FROM locations
+		WHERE warehouse_id = $1 AND parent_id IS NULL
+		UNION ALL
+		SELECT l.location_id, t.zone_id
+		FROM locations l
+		JOIN tree t ON l.parent_id = t.location_id
+	)
+	SELECT location_id, zone_id FROM tree
+	`
+
+	rows, err := tx.Query(ctx, sql, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones of warehouse %d: %w", warehouseID, err)
+	}
+
+	defer rows.Close()
+
+	zones := make(map[int]int)
+	for rows.Next() {
+		var locationID, zoneID int
+		if err := rows.Scan(&locationID, &zoneID); err != nil {
+			return nil, fmt.Errorf("failed to scan zones: %w", err)
+		}
+		zones[locationID] = zoneID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return zones, nil
+}
+
+// pickedUnshipped lists, per allocation of a completed wave, the units
+// picked that have not shipped yet. What a line shipped since the wave was
+// planned is taken off its allocations in pick line order.
+const pickedUnshipped = `SELECT
+		w.warehouse_id,
+		oi.order_id,
+		o.stock_timing,
+		pl.product_id,
+		pl.location_id,
+		LEAST(pa.quantity_picked, GREATEST(
+			SUM(pa.quantity_picked) OVER (
+				PARTITION BY pa.order_item_id, pl.wave_id, pl.product_id
+				ORDER BY pl.pick_line_id
+			) - (oi.quantity_shipped * COALESCE(bc.quantity, 1) - pa.shipped_before),
+			0)) AS quantity
+		FROM pick_allocations pa
+		JOIN pick_lines pl ON pl.pick_line_id = pa.pick_line_id
+		JOIN waves w ON w.wave_id = pl.wave_id
+		JOIN order_items oi ON oi.order_item_id = pa.order_item_id
+		JOIN orders o ON o.order_id = oi.order_id
+		LEFT JOIN bundle_components bc ON bc.bundle_id = oi.product_id AND bc.component_id = pl.product_id
+		WHERE w.status = 'completed' AND o.status <> 'cancelled' AND pa.quantity_picked > 0`
+
+// waveCandidates locks the open orders a rule selects, most urgent first.
+// An order short picked in a completed wave comes back once its flag is
+// cleared and what was picked for it has shipped.
+func waveCandidates(ctx context.Context, tx pgx.Tx, rule *models.WaveRule) ([]*waveOrder, error) {
+	sql := `SELECT o.order_id, COALESCE(o.carrier, ''), o.stock_timing
+		FROM orders o
+		WHERE o.warehouse_id = $1
+		AND o.status = ANY($2)
+		AND NOT o.needs_attention
+		AND ($3 = '' OR o.carrier = $3)
+		AND ($4::timestamptz IS NULL OR o.ship_by <= $4)
+		AND EXISTS (
+			SELECT 1 FROM order_items oi
+			WHERE oi.order_id = o.order_id AND oi.quantity > oi.quantity_shipped
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM wave_orders wo
+			JOIN waves w ON w.wave_id = wo.wave_id
+			WHERE wo.order_id = o.order_id AND w.status = $5
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM (` + pickedUnshipped + `) pu
+			WHERE pu.order_id = o.order_id AND pu.quantity > 0
+		)
+		ORDER BY o.ship_by NULLS LAST, o.order_id
+		FOR UPDATE OF o
+	`
+
+	statuses := []string{models.OrderPaid, models.OrderPartiallyShipped}
+
+	rows, err := tx.Query(ctx, sql, rule.WarehouseID, statuses, rule.Carrier, rule.Cutoff, models.WaveOpen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open orders: %w", err)
+	}
+
+	defer rows.Close()
+
+	var orders []*waveOrder
+	for rows.Next() {
+		var o waveOrder
+		if err := rows.Scan(&o.orderID, &o.carrier, &o.stockTiming); err != nil {
+			return nil, fmt.Errorf("failed to scan open orders: %w", err)
+		}
+		orders = append(orders, &o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return orders, nil
+}
+
+// waveNeeds lists the stocked products each order's lines are made of,
+// with bundle lines expanded into their components.
+func waveNeeds(ctx context.Context, tx pgx.Tx, orders []*waveOrder) (map[int][]waveNeed, error) {
+	orderIDs := make([]int, len(orders))
+	for i, o := range orders {
+		orderIDs[i] = o.orderID
+	}
+
+	sql := `SELECT
+		oi.order_id,
+		oi.order_item_id,
+		COALESCE(bc.component_id, oi.product_id),
+		(oi.quantity - oi.quantity_shipped) * COALESCE(bc.quantity, 1),
+		oi.quantity_shipped * COALESCE(bc.quantity, 1)
+		FROM order_items oi
+		LEFT JOIN bundle_components bc ON bc.bundle_id = oi.product_id
+		WHERE oi.order_id = ANY($1::int[])
+		ORDER BY oi.order_id, oi.order_item_id, 3
+	`
+
+	rows, err := tx.Query(ctx, sql, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order lines: %w", err)
+	}
+
+	defer rows.Close()
+
+	needs := make(map[int][]waveNeed)
+	for rows.Next() {
+		var orderID int
+		var n waveNeed
+		if err := rows.Scan(&orderID, &n.orderItemID, &n.productID, &n.quantity, &n.shipped); err != nil {
+			return nil, fmt.Errorf("failed to scan order lines: %w", err)
+		}
+		needs[orderID] = append(needs[orderID], n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return needs, nil
+}
+
+// drawSources takes a quantity from the front of a source list.
+func drawSources(sources []pickSource, quantity int) []pickSource {
+	var taken []pickSource
+	for i := range sources {
+		if quantity == 0 {
+			break
+		}
+		take := min(sources[i].quantity, quantity)
+		if take == 0 {
+			continue
+		}
+		sources[i].quantity -= take
+		quantity -= take
+		taken = append(taken, pickSource{locationID: sources[i].locationID, quantity: take})
+	}
+
+	return taken
+}
+
+func sourceTotal(sources []pickSource) int {
+	total := 0
+	for _, s := range sources {
+		total += s.quantity
+	}
+	return total
+}
+
+// planTakenOrder picks an order that already took its stock from the bins
+// its outgoing operations name. Units shipped so far are assumed to have
+// come from the first of those.
+func planTakenOrder(ctx context.Context, tx pgx.Tx, order *waveOrder, needs []waveNeed) (bool, error) {
+	sql := `SELECT product_id, from_location_id, -SUM(change_quant)::int
+		FROM operations
+		WHERE order_id = $1 AND operation_type = 'outgoing'
+		GROUP BY product_id, from_location_id
+		ORDER BY MIN(operation_id)
+	`
+
+	rows, err := tx.Query(ctx, sql, order.orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get operations of order %d: %w", order.orderID, err)
+	}
+
+	defer rows.Close()
+
+	sources := make(map[int][]pickSource)
+	for rows.Next() {
+		var productID int
+		var s pickSource
+		if err := rows.Scan(&productID, &s.locationID, &s.quantity); err != nil {
+			return false, fmt.Errorf("failed to scan operations: %w", err)
+		}
+		sources[productID] = append(sources[productID], s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	for _, n := range needs {
+		drawSources(sources[n.productID], n.shipped)
+	}
+
+	for _, n := range needs {
+		if n.quantity == 0 {
+			continue
+		}
+		if sourceTotal(sources[n.productID]) < n.quantity {
+			return false, nil
+		}
+		for _, s := range drawSources(sources[n.productID], n.quantity) {
+			order.picks = append(order.picks, plannedPick{
+				orderItemID: n.orderItemID,
+				productID:   n.productID,
+				locationID:  s.locationID,
+				quantity:    s.quantity,
+				shipped:     n.shipped,
+			})
+		}
+	}
+
+	return true, nil
+}
+
+// planOpenOrder picks an order that takes its stock on shipment from what
+// is still available in the warehouse, loading a product's sources into
+// available the first time it is needed. The draw is made on copies, which
+// are returned for the caller to put into available if it keeps the order.
+func planOpenOrder(ctx context.Context, tx pgx.Tx, warehouseID int, order *waveOrder, needs []waveNeed, available map[int][]pickSource) (map[int][]pickSource, bool, error) {
+	wanted := make(map[int]int)
+	for _, n := range needs {
+		if _, ok := available[n.productID]; !ok {
+			sources, err := pickableStock(ctx, tx, warehouseID, n.productID)
+			if err != nil {
+				return nil, false, err
+			}
+			available[n.productID] = sources
+		}
+		wanted[n.productID] += n.quantity
+	}
+
+	for productID, quantity := range wanted {
+		if sourceTotal(available[productID]) < quantity {
+			return nil, false, nil
+		}
+	}
+
+	drawn := make(map[int][]pickSource, len(wanted))
+	for productID := range wanted {
+		drawn[productID] = append([]pickSource(nil), available[productID]...)
+	}
+
+	for _, n := range needs {
+		for _, s := range drawSources(drawn[n.productID], n.quantity) {
+			order.picks = append(order.picks, plannedPick{
+				orderItemID: n.orderItemID,
+				productID:   n.productID,
+				locationID:  s.locationID,
+				quantity:    s.quantity,
+				shipped:     n.shipped,
+			})
+		}
+	}
+
+	return drawn, true, nil
+}
+
+// pickableStock lists where a product can be picked for orders that take
+// their stock on shipment, in the order pickFromLocations takes it, less
+// what open waves already plan to pick for such orders and what completed
+// waves picked for them that has not shipped yet: that stock only leaves
+// the bins when it ships.
+func pickableStock(ctx context.Context, tx pgx.Tx, warehouseID, productID int) ([]pickSource, error) {
+	unlocated, err := unlocatedStock(ctx, tx, warehouseID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	held := `SELECT location_id, SUM(quantity)::int
+		FROM (
+			SELECT pl.location_id, pa.quantity
+			FROM pick_allocations pa
+			JOIN pick_lines pl ON pl.pick_line_id = pa.pick_line_id
+			JOIN waves w ON w.wave_id = pl.wave_id
+			JOIN order_items oi ON oi.order_item_id = pa.order_item_id
+			JOIN orders o ON o.order_id = oi.order_id
+			WHERE w.warehouse_id = $1 AND w.status = $2
+			AND pl.product_id = $3 AND o.stock_timing = $4
+			UNION ALL
+			SELECT pu.location_id, pu.quantity
+			FROM (` + pickedUnshipped + `) pu
+			WHERE pu.warehouse_id = $1 AND pu.product_id = $3 AND pu.stock_timing = $4
+		) held
+		GROUP BY location_id
+	`
+
+	rows, err := tx.Query(ctx, held, warehouseID, models.WaveOpen, productID, models.StockOnShipment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get planned picks of product %d: %w", productID, err)
+	}
+
+	planned := make(map[int]int)
+	for rows.Next() {
+		var locationID *int
+		var quantity int
+		if err := rows.Scan(&locationID, &quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan planned picks: %w", err)
+		}
+		key := 0
+		if locationID != nil {
+			key = *locationID
+		}
+		planned[key] = quantity
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	sources := []pickSource{{quantity: max(unlocated-planned[0], 0)}}
+
+	bins := `SELECT ls.location_id, ls.quantity
+		FROM location_stock ls
+		JOIN locations l ON l.location_id = ls.location_id
+		WHERE l.warehouse_id = $1 AND ls.product_id = $2 AND ls.quantity > 0
+		ORDER BY l.code
+	`
+
+	rows, err = tx.Query(ctx, bins, warehouseID, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bins for product %d: %w", productID, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var locationID, quantity int
+		if err := rows.Scan(&locationID, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan location stock: %w", err)
+		}
+		sources = append(sources, pickSource{locationID: &locationID, quantity: max(quantity-planned[locationID], 0)})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return sources, nil
+}
+
+// insertWave writes a wave for the given orders with one pick line per
+// product and bin.
+func insertWave(ctx context.Context, tx pgx.Tx, w *models.Wave, orders []*waveOrder) (int, error) {
+	insert := `INSERT INTO waves (
+		warehouse_id,
+		carrier,
+		zone_id,
+		cutoff,
+		status,
+		created_at
+	) VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+	RETURNING wave_id
+	`
+
+	err := tx.QueryRow(ctx, insert, w.WarehouseID, w.Carrier, nullableID(w.ZoneID), w.Cutoff, models.WaveOpen, time.Now()).
+		Scan(&w.WaveID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create wave: %w", err)
+	}
+
+	type lineKey struct {
+		productID  int
+		locationID int
+	}
+
+	var keys []lineKey
+	lines := make(map[lineKey][]plannedPick)
+
+	for _, order := range orders {
+		_, err := tx.Exec(ctx, `INSERT INTO wave_orders (wave_id, order_id) VALUES ($1, $2)`, w.WaveID, order.orderID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add order %d to wave: %w", order.orderID, err)
+		}
+
+		for _, pick := range order.picks {
+			key := lineKey{productID: pick.productID}
+			if pick.locationID != nil {
+				key.locationID = *pick.locationID
+			}
+			if _, ok := lines[key]; !ok {
+				keys = append(keys, key)
+			}
+			lines[key] = append(lines[key], pick)
+		}
+	}
+
+	insertLine := `INSERT INTO pick_lines (wave_id, product_id, location_id, quantity)
+		VALUES ($1, $2, $3, $4)
+		RETURNING pick_line_id
+	`
+
+	insertAllocation := `INSERT INTO pick_allocations (pick_line_id, order_item_id, quantity, shipped_before)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pick_line_id, order_item_id) DO UPDATE SET quantity = pick_allocations.quantity + EXCLUDED.quantity
+	`
+
+	for _, key := range keys {
+		picks := lines[key]
+
+		quantity := 0
+		for _, pick := range picks {
+			quantity += pick.quantity
+		}
+
+		var lineID int
+		err := tx.QueryRow(ctx, insertLine, w.WaveID, key.productID, picks[0].locationID, quantity).Scan(&lineID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create pick line: %w", err)
+		}
+
+		for _, pick := range picks {
+			if _, err := tx.Exec(ctx, insertAllocation, lineID, pick.orderItemID, pick.quantity, pick.shipped); err != nil {
+				return 0, fmt.Errorf("failed to allocate pick line: %w", err)
+			}
+		}
+	}
+
+	return w.WaveID, nil
+}
+
+func (r *waveRepo) GetByID(ctx context.Context, id int) (*models.Wave, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	return loadWave(ctx, r.db, id)
+}
+
+// loadWave reads a wave with its orders and its pick list in walking
+// order, by bin code with unlocated stock first.
+func loadWave(ctx context.Context, q querier, id int) (*models.Wave, error) {
+	sql := `
+		SELECT
+			wave_id,
+			warehouse_id,
+			COALESCE(carrier, ''),
+			zone_id,
+			cutoff,
+			status,
+			created_at,
+			completed_at
+		FROM waves WHERE wave_id = $1
+		`
+
+	var w models.Wave
+
+	err := q.QueryRow(ctx, sql, id).Scan(
+		&w.WaveID,
+		&w.WarehouseID,
+		&w.Carrier,
+		&w.ZoneID,
+		&w.Cutoff,
+		&w.Status,
+		&w.CreatedAt,
+		&w.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get wave by id %d: %w", id, err)
+	}
+
+	rows, err := q.Query(ctx, `SELECT order_id FROM wave_orders WHERE wave_id = $1 ORDER BY order_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders of wave %d: %w", id, err)
+	}
+
+	w.OrderIDs, err = scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := `
+		SELECT
+			pl.pick_line_id,
+			pl.wave_id,
+			pl.product_id,
+			pl.location_id,
+			COALESCE(l.code, ''),
+			pl.quantity,
+			pl.quantity_picked,
+			COALESCE(pl.picked_by, ''),
+			pl.picked_at
+		FROM pick_lines pl
+		LEFT JOIN locations l ON l.location_id = pl.location_id
+		WHERE pl.wave_id = $1
+		ORDER BY l.code NULLS FIRST, pl.product_id
+		`
+
+	rows, err = q.Query(ctx, lines, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pick lines of wave %d: %w", id, err)
+	}
+
+	index := make(map[int]int)
+	for rows.Next() {
+		var line models.PickLine
+
+		err := rows.Scan(&line.LineID,
+			&line.WaveID,
+			&line.ProductID,
+			&line.LocationID,
+			&line.LocationCode,
+			&line.Quantity,
+			&line.QuantityPicked,
+			&line.PickedBy,
+			&line.PickedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan pick lines: %w", err)
+		}
+		index[line.LineID] = len(w.Lines)
+		w.Lines = append(w.Lines, line)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	allocations := `
+		SELECT
+			pa.pick_line_id,
+			pa.order_item_id,
+			oi.order_id,
+			pa.quantity,
+			pa.quantity_picked
+		FROM pick_allocations pa
+		JOIN pick_lines pl ON pl.pick_line_id = pa.pick_line_id
+		JOIN order_items oi ON oi.order_item_id = pa.order_item_id
+		WHERE pl.wave_id = $1
+		ORDER BY oi.order_id, pa.order_item_id
+		`
+
+	rows, err = q.Query(ctx, allocations, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pick allocations of wave %d: %w", id, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var lineID int
+		var a models.PickAllocation
+
+		if err := rows.Scan(&lineID, &a.OrderItemID, &a.OrderID, &a.Quantity, &a.QuantityPicked); err != nil {
+			return nil, fmt.Errorf("failed to scan pick allocations: %w", err)
+		}
+
+		line := &w.Lines[index[lineID]]
+		line.Allocations = append(line.Allocations, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return &w, nil
+}
+
+// GetByWarehouse lists a warehouse's waves without their pick lists,
+// optionally only those in one status.
+func (r *waveRepo) GetByWarehouse(ctx context.Context, warehouseID int, status string) ([]models.Wave, error) {
+	if warehouseID <= 0 {
+		return nil, fmt.Errorf("%w: warehouse ID must be positive", ErrInvalidInput)
+	}
+
+	sql := `
+		SELECT
+			wave_id,
+			warehouse_id,
+			COALESCE(carrier, ''),
+			zone_id,
+			cutoff,
+			status,
+			created_at,
+			completed_at
+		FROM waves
+		WHERE warehouse_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY wave_id
+		`
+
+	rows, err := r.db.Query(ctx, sql, warehouseID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get waves of warehouse %d: %w", warehouseID, err)
+	}
+
+	defer rows.Close()
+
+	var waves []models.Wave
+
+	for rows.Next() {
+		var w models.Wave
+
+		err := rows.Scan(&w.WaveID,
+			&w.WarehouseID,
+			&w.Carrier,
+			&w.ZoneID,
+			&w.Cutoff,
+			&w.Status,
+			&w.CreatedAt,
+			&w.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan waves: %w", err)
+		}
+		waves = append(waves, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	return waves, nil
+}
+
+// ConfirmPick records how much of a pick line was actually picked. A short
+// pick is shared out over the line's orders most urgent first, and every
+// order that comes up short is flagged for attention. The wave completes
+// with its last line.
+func (r *waveRepo) ConfirmPick(ctx context.Context, lineID, picked int, pickedBy string) error {
+	if lineID <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+	if picked < 0 {
+		return fmt.Errorf("%w: picked quantity cannot be negative", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var waveID, quantity int
+	var quantityPicked *int
+	var status string
+
+	err = tx.QueryRow(ctx, `SELECT pl.wave_id, pl.quantity, pl.quantity_picked, w.status
+		FROM pick_lines pl
+		JOIN waves w ON w.wave_id = pl.wave_id
+		WHERE pl.pick_line_id = $1
+		FOR UPDATE`, lineID).Scan(&waveID, &quantity, &quantityPicked, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock pick line %d: %w", lineID, err)
+	}
+	if status != models.WaveOpen {
+		return fmt.Errorf("%w: wave %d is %s", ErrInvalidInput, waveID, status)
+	}
+	if quantityPicked != nil {
+		return fmt.Errorf("%w: pick line %d is already confirmed", ErrInvalidInput, lineID)
+	}
+	if picked > quantity {
+		return fmt.Errorf("%w: pick line %d is for %d units", ErrInvalidInput, lineID, quantity)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE pick_lines SET quantity_picked = $1, picked_by = NULLIF($2, ''), picked_at = $3
+		WHERE pick_line_id = $4`, picked, strings.TrimSpace(pickedBy), time.Now(), lineID)
+	if err != nil {
+		return fmt.Errorf("failed to update pick line %d: %w", lineID, err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT pa.order_item_id, oi.order_id, pa.quantity
+		FROM pick_allocations pa
+		JOIN order_items oi ON oi.order_item_id = pa.order_item_id
+		JOIN orders o ON o.order_id = oi.order_id
+		WHERE pa.pick_line_id = $1
+		ORDER BY o.ship_by NULLS LAST, o.order_id, pa.order_item_id`, lineID)
+	if err != nil {
+		return fmt.Errorf("failed to get allocations of pick line %d: %w", lineID, err)
+	}
+
+	var allocations []models.PickAllocation
+	for rows.Next() {
+		var a models.PickAllocation
+		if err := rows.Scan(&a.OrderItemID, &a.OrderID, &a.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan pick allocations: %w", err)
+		}
+		allocations = append(allocations, a)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to complete row iteration: %w", err)
+	}
+
+	var short []int
+	for _, a := range allocations {
+		got := min(a.Quantity, picked)
+		picked -= got
+
+		_, err := tx.Exec(ctx, `UPDATE pick_allocations SET quantity_picked = $1 WHERE pick_line_id = $2 AND order_item_id = $3`,
+			got, lineID, a.OrderItemID)
+		if err != nil {
+			return fmt.Errorf("failed to update pick allocation: %w", err)
+		}
+		if got < a.Quantity {
+			short = append(short, a.OrderID)
+		}
+	}
+
+	if len(short) > 0 {
+		_, err := tx.Exec(ctx, `UPDATE orders SET needs_attention = TRUE WHERE order_id = ANY($1::int[])`, short)
+		if err != nil {
+			return fmt.Errorf("failed to flag short picked orders: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE waves SET status = $1, completed_at = $2
+		WHERE wave_id = $3
+		AND NOT EXISTS (SELECT 1 FROM pick_lines WHERE wave_id = $3 AND quantity_picked IS NULL)`,
+		models.WaveCompleted, time.Now(), waveID)
+	if err != nil {
+		return fmt.Errorf("failed to complete wave %d: %w", waveID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Cancel drops an open wave; its orders can be planned again.
+func (r *waveRepo) Cancel(ctx context.Context, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: ID cannot be empty", ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM waves WHERE wave_id = $1 FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock wave %d: %w", id, err)
+	}
+	if status != models.WaveOpen {
+		return fmt.Errorf("%w: wave %d is %s", ErrInvalidInput, id, status)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE waves SET status = $1 WHERE wave_id = $2`, models.WaveCancelled, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel wave %d: %w", id, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDrawSources(t *testing.T) {
+	bin1, bin2 := 1, 2
+
+	tests := []struct {
+		name      string
+		sources   []pickSource
+		quantity  int
+		wantTaken []pickSource
+		wantLeft  []pickSource
+	}{
+		{
+			name:      "from the front",
+			sources:   []pickSource{{&bin1, 3}, {&bin2, 5}},
+			quantity:  4,
+			wantTaken: []pickSource{{&bin1, 3}, {&bin2, 1}},
+			wantLeft:  []pickSource{{&bin1, 0}, {&bin2, 4}},
+		},
+		{
+			name:      "skips empty sources",
+			sources:   []pickSource{{nil, 0}, {&bin1, 2}},
+			quantity:  2,
+			wantTaken: []pickSource{{&bin1, 2}},
+			wantLeft:  []pickSource{{nil, 0}, {&bin1, 0}},
+		},
+		{
+			name:      "unlocated stock",
+			sources:   []pickSource{{nil, 2}, {&bin1, 2}},
+			quantity:  3,
+			wantTaken: []pickSource{{nil, 2}, {&bin1, 1}},
+			wantLeft:  []pickSource{{nil, 0}, {&bin1, 1}},
+		},
+		{
+			name:      "nothing wanted",
+			sources:   []pickSource{{&bin1, 3}},
+			quantity:  0,
+			wantTaken: nil,
+			wantLeft:  []pickSource{{&bin1, 3}},
+		},
+		{
+			name:      "more than there is",
+			sources:   []pickSource{{&bin1, 1}, {&bin2, 1}},
+			quantity:  5,
+			wantTaken: []pickSource{{&bin1, 1}, {&bin2, 1}},
+			wantLeft:  []pickSource{{&bin1, 0}, {&bin2, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		taken := drawSources(tt.sources, tt.quantity)
+		if !reflect.DeepEqual(taken, tt.wantTaken) {
+			t.Errorf("%s: drawSources took %v, want %v", tt.name, taken, tt.wantTaken)
+		}
+		if !reflect.DeepEqual(tt.sources, tt.wantLeft) {
+			t.Errorf("%s: drawSources left %v, want %v", tt.name, tt.sources, tt.wantLeft)
+		}
+	}
+}
+
+func TestPlanOpenOrder(t *testing.T) {
+	bin1, bin2 := 1, 2
+
+	tests := []struct {
+		name      string
+		available map[int][]pickSource
+		needs     []waveNeed
+		wantOK    bool
+		wantPicks []plannedPick
+		wantDrawn map[int][]pickSource
+	}{
+		{
+			name:      "lines of one product share its sources",
+			available: map[int][]pickSource{10: {{&bin1, 3}, {&bin2, 4}}},
+			needs: []waveNeed{
+				{orderItemID: 1, productID: 10, quantity: 2},
+				{orderItemID: 2, productID: 10, quantity: 4, shipped: 1},
+			},
+			wantOK: true,
+			wantPicks: []plannedPick{
+				{orderItemID: 1, productID: 10, locationID: &bin1, quantity: 2},
+				{orderItemID: 2, productID: 10, locationID: &bin1, quantity: 1, shipped: 1},
+				{orderItemID: 2, productID: 10, locationID: &bin2, quantity: 3, shipped: 1},
+			},
+			wantDrawn: map[int][]pickSource{10: {{&bin1, 0}, {&bin2, 1}}},
+		},
+		{
+			name:      "lines together want more than there is",
+			available: map[int][]pickSource{10: {{&bin1, 3}, {&bin2, 4}}},
+			needs: []waveNeed{
+				{orderItemID: 1, productID: 10, quantity: 4},
+				{orderItemID: 2, productID: 10, quantity: 4},
+			},
+		},
+		{
+			name: "one product short",
+			available: map[int][]pickSource{
+				10: {{&bin1, 5}},
+				20: {{nil, 1}},
+			},
+			needs: []waveNeed{
+				{orderItemID: 1, productID: 10, quantity: 5},
+				{orderItemID: 2, productID: 20, quantity: 2},
+			},
+		},
+		{
+			name: "several products",
+			available: map[int][]pickSource{
+				10: {{&bin1, 5}},
+				20: {{nil, 1}, {&bin2, 1}},
+			},
+			needs: []waveNeed{
+				{orderItemID: 1, productID: 10, quantity: 5},
+				{orderItemID: 2, productID: 20, quantity: 2},
+			},
+			wantOK: true,
+			wantPicks: []plannedPick{
+				{orderItemID: 1, productID: 10, locationID: &bin1, quantity: 5},
+				{orderItemID: 2, productID: 20, locationID: nil, quantity: 1},
+				{orderItemID: 2, productID: 20, locationID: &bin2, quantity: 1},
+			},
+			wantDrawn: map[int][]pickSource{
+				10: {{&bin1, 0}},
+				20: {{nil, 0}, {&bin2, 0}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		before := make(map[int][]pickSource, len(tt.available))
+		for productID, sources := range tt.available {
+			before[productID] = append([]pickSource(nil), sources...)
+		}
+
+		// Every product is already in available, so nothing is loaded
+		// and no transaction is needed.
+		order := waveOrder{orderID: 1}
+		drawn, ok, err := planOpenOrder(context.Background(), nil, 1, &order, tt.needs, tt.available)
+		if err != nil {
+			t.Errorf("%s: planOpenOrder failed: %v", tt.name, err)
+			continue
+		}
+		if ok != tt.wantOK {
+			t.Errorf("%s: planOpenOrder ok = %t, want %t", tt.name, ok, tt.wantOK)
+		}
+		if !reflect.DeepEqual(order.picks, tt.wantPicks) {
+			t.Errorf("%s: planOpenOrder picked %v, want %v", tt.name, order.picks, tt.wantPicks)
+		}
+		if !reflect.DeepEqual(drawn, tt.wantDrawn) {
+			t.Errorf("%s: planOpenOrder drew %v, want %v", tt.name, drawn, tt.wantDrawn)
+		}
+		if !reflect.DeepEqual(tt.available, before) {
+			t.Errorf("%s: planOpenOrder changed available to %v", tt.name, tt.available)
+		}
+	}
+}